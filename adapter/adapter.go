@@ -159,6 +159,10 @@ func RunRouterContract(t *testing.T, factory func() router.Router) {
 		testHandleAndHandleFunc(t, factory())
 	})
 
+	t.Run("Unmatched Routes Render Problem Details", func(t *testing.T) {
+		testUnmatchedRoutes(t, factory())
+	})
+
 }
 
 // RunAdvancedRouterContract executes a comprehensive test suite for high-level router features,
@@ -196,6 +200,18 @@ func RunAdvancedRouterContract(t *testing.T, factory func() router.Router) {
 		testAmbiguity(t, factory())
 	})
 
+	t.Run("Middleware Writer Replacement", func(t *testing.T) {
+		testWriterReplacement(t, factory())
+	})
+
+	t.Run("Mount Root with Catch-All", func(t *testing.T) {
+		testMountRoot(t, factory())
+	})
+
+	t.Run("Matched Route Pattern", func(t *testing.T) {
+		testRoutePattern(t, factory())
+	})
+
 }
 
 func testParametersAndExtensions(t *testing.T, adp router.Router) {
@@ -442,6 +458,86 @@ func testWildcardRoutes(t *testing.T, adp router.Router) {
 	}
 }
 
+// testMountRoot checks that a prefix and a catch-all below it can be registered
+// together, as mounted sub-applications such as transwarp.Docs do.
+func testMountRoot(t *testing.T, adp router.Router) {
+	adp.GET("/docs", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("root"))
+	})
+	adp.GET("/docs/*file", func(w http.ResponseWriter, r *http.Request) {
+		val := adp.Param(r, "file")
+		if val == "" {
+			val = adp.Param(r, "*")
+		}
+		_, _ = w.Write([]byte("file:" + val))
+	})
+
+	cases := map[string]string{
+		"/docs":                "root",
+		"/docs/openapi.json":   "file:openapi.json",
+		"/docs/assets/main.js": "file:assets/main.js",
+	}
+	for path, want := range cases {
+		rec := httptest.NewRecorder()
+		adp.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("GET %s: expected 200 %q, got %d %q", path, want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// testRoutePattern checks that the matched route pattern (in Transwarp syntax)
+// and its group prefix reach middlewares and handlers through the request state
+// and router.RouteKey, including routes served by shadow routers.
+func testRoutePattern(t *testing.T, adp router.Router) {
+	var seenByMiddleware string
+	api := adp.Group("/api")
+	api.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if state, ok := r.Context().Value(router.StateKey).(*TranswarpState); ok {
+				seenByMiddleware = state.Route
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		state, ok := r.Context().Value(router.StateKey).(*TranswarpState)
+		if !ok {
+			http.Error(w, "no state", http.StatusInternalServerError)
+			return
+		}
+		route, _ := r.Context().Value(router.RouteKey).(string)
+		_, _ = w.Write([]byte(state.Route + "|" + state.Prefix + "|" + route))
+	}
+	api.GET("/users/:id", handler)
+	api.GET("/files/:name", handler)
+	api.GET("/files/*path", handler)
+	adp.GET("/health", handler)
+
+	cases := []struct {
+		path, route, prefix string
+	}{
+		{"/api/users/7", "/api/users/:id", "/api"},
+		{"/api/files/a.txt", "/api/files/:name", "/api"},
+		{"/api/files/docs/a.txt", "/api/files/*path", "/api"},
+		{"/health", "/health", ""},
+	}
+	for _, tc := range cases {
+		seenByMiddleware = ""
+		rec := httptest.NewRecorder()
+		adp.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		want := tc.route + "|" + tc.prefix + "|" + tc.route
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("GET %s: expected %q, got %d %q", tc.path, want, rec.Code, rec.Body.String())
+		}
+		if tc.prefix != "" && seenByMiddleware != tc.route {
+			t.Errorf("GET %s: middleware saw route %q, expected %q", tc.path, seenByMiddleware, tc.route)
+		}
+	}
+}
+
 func testHeaderSync(t *testing.T, adp router.Router) {
 	mw := func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -467,6 +563,45 @@ func testHeaderSync(t *testing.T, adp router.Router) {
 	}
 }
 
+// capturingWriter buffers the body written by the rest of the chain.
+type capturingWriter struct {
+	http.ResponseWriter
+	body []byte
+}
+
+func (c *capturingWriter) Write(p []byte) (int, error) {
+	c.body = append(c.body, p...)
+	return len(p), nil
+}
+
+func testWriterReplacement(t *testing.T, adp router.Router) {
+	// Middlewares such as response validation or compression hand a different
+	// writer to next; the handler must write through it, not around it.
+	wrap := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cw := &capturingWriter{ResponseWriter: w}
+			next.ServeHTTP(cw, r)
+			_, _ = w.Write([]byte("wrapped:" + string(cw.body)))
+		})
+	}
+	passthrough := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	adp.GET("/wrapped/:id", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(adp.Param(r, "id")))
+	}, wrap, passthrough)
+
+	rec := httptest.NewRecorder()
+	adp.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wrapped/42", nil))
+
+	if rec.Body.String() != "wrapped:42" {
+		t.Errorf("Replacement writer bypassed. Expected 'wrapped:42', got %q", rec.Body.String())
+	}
+}
+
 func testConcurrency(t *testing.T, adp router.Router) {
 	adp.GET("/worker/:id", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte(adp.Param(r, "id")))
@@ -573,3 +708,28 @@ func testHandleAndHandleFunc(t *testing.T, adp router.Router) {
 			rec2.Body.String(), rec2.Header().Get("X-Handle"))
 	}
 }
+
+func testUnmatchedRoutes(t *testing.T, adp router.Router) {
+	adp.GET("/only-get", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	rec1 := httptest.NewRecorder()
+	adp.ServeHTTP(rec1, httptest.NewRequest(http.MethodGet, "/does-not-exist", nil))
+	if rec1.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown route, got %d", rec1.Code)
+	}
+	if ct := rec1.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected problem+json for 404, got %q (body: %s)", ct, rec1.Body.String())
+	}
+
+	// Engines differ on whether a wrong method is a 404 or a 405, but both must be problems.
+	rec2 := httptest.NewRecorder()
+	adp.ServeHTTP(rec2, httptest.NewRequest(http.MethodPut, "/only-get", nil))
+	if rec2.Code != http.StatusMethodNotAllowed && rec2.Code != http.StatusNotFound {
+		t.Errorf("Expected 405 or 404 for wrong method, got %d", rec2.Code)
+	}
+	if ct := rec2.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected problem+json for wrong method, got %q (body: %s)", ct, rec2.Body.String())
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/problem"
 	"github.com/iaconlabs/transwarp/router"
 )
 
@@ -23,10 +24,39 @@ type ChiAdapter struct {
 }
 
 // NewChiAdapter initializes a new adapter with an empty chi router.
+// Unmatched routes are answered with RFC 9457 problem details.
 func NewChiAdapter() *ChiAdapter {
-	return &ChiAdapter{
+	a := &ChiAdapter{
 		mux: chi.NewRouter(),
 	}
+	a.mux.NotFound(problem.NotFound)
+	a.mux.MethodNotAllowed(a.methodNotAllowed)
+	return a
+}
+
+// methodNotAllowed replies with a 405 problem and the Allow header. chi does not
+// provide the allowed methods to custom handlers, so they are resolved here.
+func (a *ChiAdapter) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	methods := []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+		http.MethodOptions,
+	}
+
+	var allowed []string
+	for _, method := range methods {
+		if a.mux.Match(chi.NewRouteContext(), method, r.URL.Path) {
+			allowed = append(allowed, method)
+		}
+	}
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+	}
+	problem.MethodNotAllowed(w, r)
 }
 
 // Param extracts parameters from the request context provided by chi.
@@ -161,7 +191,9 @@ func (a *ChiAdapter) transformPathForChi(path string) (string, string) {
 	return strings.Join(segments, "/"), wildcardName
 }
 
-func (a *ChiAdapter) wrapState(onion http.Handler, wildcardName string) http.Handler {
+// wrapState builds the request state of a matched route: its parameters, body
+// and Transwarp-style pattern (route) and group prefix.
+func (a *ChiAdapter) wrapState(onion http.Handler, wildcardName, route, prefix string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		state, ok := r.Context().Value(router.StateKey).(*adapter.TranswarpState)
 		if !ok {
@@ -195,9 +227,8 @@ func (a *ChiAdapter) wrapState(onion http.Handler, wildcardName string) http.Han
 			r.Body = io.NopCloser(bytes.NewReader(body))
 		}
 
-		newState := &adapter.TranswarpState{Params: newParams, Body: body}
-		ctx := context.WithValue(r.Context(), router.StateKey, newState)
-		onion.ServeHTTP(w, r.WithContext(ctx))
+		newState := &adapter.TranswarpState{Params: newParams, Body: body, Route: route, Prefix: prefix}
+		onion.ServeHTTP(w, r.WithContext(adapter.WithState(r.Context(), newState)))
 	})
 }
 
@@ -220,5 +251,6 @@ func (a *ChiAdapter) register(method, path string, h http.HandlerFunc, routeMws
 		finalHandler = a.middlewares[i](finalHandler)
 	}
 
-	a.mux.Method(method, fullPath, a.wrapState(finalHandler, wildcardName))
+	route := a.joinPaths(a.prefix, path)
+	a.mux.Method(method, fullPath, a.wrapState(finalHandler, wildcardName, route, strings.TrimSuffix(a.prefix, "/")))
 }
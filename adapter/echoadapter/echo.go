@@ -4,6 +4,7 @@ package echoadapter
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +15,7 @@ import (
 	"sync/atomic"
 
 	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/problem"
 	"github.com/iaconlabs/transwarp/router"
 	"github.com/labstack/echo/v5"
 )
@@ -23,6 +25,7 @@ const defaultMaxShadowCacheSize = 10000
 type routeEntry struct {
 	method       string
 	path         string
+	prefix       string
 	h            http.HandlerFunc
 	mws          []func(http.Handler) http.Handler
 	regex        *regexp.Regexp
@@ -44,8 +47,10 @@ type EchoAdapter struct {
 }
 
 // NewEchoAdapter initializes a new adapter with an internal Echo v5 instance.
+// Unmatched routes are answered with RFC 9457 problem details.
 func NewEchoAdapter() *EchoAdapter {
 	e := echo.New()
+	e.HTTPErrorHandler = problemErrorHandler(e.HTTPErrorHandler)
 	return &EchoAdapter{
 		instance:     e,
 		prefix:       "",
@@ -169,6 +174,24 @@ func (a *EchoAdapter) ANY(path string, h http.HandlerFunc, mws ...func(http.Hand
 
 func (a *EchoAdapter) Engine() any { return a.instance }
 
+// problemErrorHandler renders Echo's routing errors (404/405) as problem details
+// and delegates every other error to the previous handler.
+func problemErrorHandler(fallback echo.HTTPErrorHandler) echo.HTTPErrorHandler {
+	return func(c *echo.Context, err error) {
+		switch {
+		case errors.Is(err, echo.ErrNotFound):
+			problem.NotFound(c.Response(), c.Request())
+		case errors.Is(err, echo.ErrMethodNotAllowed):
+			// Echo has already set the Allow header at this point.
+			problem.MethodNotAllowed(c.Response(), c.Request())
+		case fallback != nil:
+			fallback(c, err)
+		default:
+			problem.Write(c.Response(), c.Request(), problem.From(err))
+		}
+	}
+}
+
 func (a *EchoAdapter) registerAll() {
 	shadowZones := make(map[string][]*routeEntry)
 	conflictingPrefixes := make(map[string]bool)
@@ -294,8 +317,10 @@ func (a *EchoAdapter) wrap(re *routeEntry) echo.HandlerFunc {
 		newState := &adapter.TranswarpState{
 			Params: newParams,
 			Body:   state.Body,
+			Route:  re.path,
+			Prefix: re.prefix,
 		}
-		ctx := context.WithValue(r.Context(), router.StateKey, newState)
+		ctx := adapter.WithState(r.Context(), newState)
 
 		// 4. Ejecución de la "Cebolla" (Manual Onion)
 		// Construimos la cadena de middlewares y el handler final
@@ -375,6 +400,7 @@ func (a *EchoAdapter) register(m, p string, h http.HandlerFunc, mws ...func(http
 	*a.routes = append(*a.routes, &routeEntry{
 		method: m,
 		path:   full,
+		prefix: strings.TrimSuffix(a.prefix, "/"),
 		h:      h,
 		mws:    append(a.middlewares, mws...),
 	})
@@ -3,6 +3,7 @@ package fiberadapter
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"sort"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/problem"
 	"github.com/iaconlabs/transwarp/router"
 	"github.com/valyala/fasthttp"
 )
@@ -18,6 +20,7 @@ import (
 type routeEntry struct {
 	method      string
 	fullPath    string
+	prefix      string
 	h           http.HandlerFunc
 	allHandlers []func(http.Handler) http.Handler
 }
@@ -31,8 +34,10 @@ type FiberAdapter struct {
 	fastHandler fasthttp.RequestHandler
 }
 
+// NewFiberAdapter initializes a new adapter with an internal Fiber v3 app.
+// Unmatched routes are answered with RFC 9457 problem details.
 func NewFiberAdapter() *FiberAdapter {
-	app := fiber.New(fiber.Config{Immutable: true})
+	app := fiber.New(fiber.Config{Immutable: true, ErrorHandler: problemErrorHandler})
 	return &FiberAdapter{
 		app:         app,
 		prefix:      "",
@@ -68,7 +73,7 @@ func (a *FiberAdapter) registerAll() {
 		for i := len(r.allHandlers) - 1; i >= 0; i-- {
 			finalHandler = r.allHandlers[i](finalHandler)
 		}
-		a.app.Add([]string{r.method}, fiberPath, a.wrapAtomic(finalHandler))
+		a.app.Add([]string{r.method}, fiberPath, a.wrapAtomic(finalHandler, r.fullPath, r.prefix))
 	}
 	a.fastHandler = a.app.Handler()
 }
@@ -114,7 +119,9 @@ func (a *FiberAdapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	a.fastHandler(fctx)
 }
 
-func (a *FiberAdapter) wrapAtomic(onion http.Handler) fiber.Handler {
+// wrapAtomic bridges a matched Fiber route to the net/http onion, building the
+// request state with the route's Transwarp-style pattern and group prefix.
+func (a *FiberAdapter) wrapAtomic(onion http.Handler, route, prefix string) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		ctxVal := c.Locals("tw_ctx")
 		ctx, ok := ctxVal.(context.Context)
@@ -127,10 +134,12 @@ func (a *FiberAdapter) wrapAtomic(onion http.Handler) fiber.Handler {
 		state := &adapter.TranswarpState{
 			Params: syncParams(c, ctx),
 			Body:   c.Body(),
+			Route:  route,
+			Prefix: prefix,
 		}
 
-		// 2. Un solo WithValue para toda la petición
-		ctx = context.WithValue(ctx, router.StateKey, state)
+		// 2. Un solo contexto derivado para toda la petición
+		ctx = adapter.WithState(ctx, state)
 
 		req, _ := http.NewRequestWithContext(
 			ctx,
@@ -149,6 +158,32 @@ func (a *FiberAdapter) wrapAtomic(onion http.Handler) fiber.Handler {
 	}
 }
 
+// problemErrorHandler renders Fiber errors, such as unmatched routes, as problem
+// details on the net/http writer that originated the request.
+func problemErrorHandler(c fiber.Ctx, err error) error {
+	w, ok := c.Locals("tw_writer").(http.ResponseWriter)
+	if !ok || w == nil {
+		return fiber.DefaultErrorHandler(c, err)
+	}
+
+	p := problem.From(err)
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		p = problem.New(fe.Code)
+	}
+	if allow := c.Response().Header.Peek("Allow"); len(allow) > 0 {
+		w.Header().Set("Allow", clone(string(allow)))
+	}
+
+	ctx, ok := c.Locals("tw_ctx").(context.Context)
+	if !ok || ctx == nil {
+		ctx = context.Background()
+	}
+	req, _ := http.NewRequestWithContext(ctx, clone(c.Method()), clone(c.OriginalURL()), http.NoBody)
+	problem.Write(w, req, p)
+	return nil
+}
+
 type directResponseWriter struct {
 	w http.ResponseWriter
 }
@@ -264,6 +299,6 @@ func (a *FiberAdapter) register(m, p string, h http.HandlerFunc, mws ...func(htt
 	stack := make([]func(http.Handler) http.Handler, len(a.middlewares))
 	copy(stack, a.middlewares)
 	stack = append(stack, mws...)
-	*a.routes = append(*a.routes, &routeEntry{method: m, fullPath: fullPath, h: h, allHandlers: stack})
+	*a.routes = append(*a.routes, &routeEntry{method: m, fullPath: fullPath, prefix: a.prefix, h: h, allHandlers: stack})
 }
 func (a *FiberAdapter) Engine() any { return a.app }
@@ -53,8 +53,8 @@ func FromFiber(fiberMw fiber.Handler) func(http.Handler) http.Handler {
 			}
 		}
 
-		newState := &adapter.TranswarpState{Params: newParams, Body: state.Body}
-		newCtx := context.WithValue(r.Context(), router.StateKey, newState)
+		newState := &adapter.TranswarpState{Params: newParams, Body: state.Body, Route: state.Route, Prefix: state.Prefix}
+		newCtx := adapter.WithState(r.Context(), newState)
 
 		newReq, _ := http.NewRequestWithContext(newCtx, clone(c.Method()), clone(c.OriginalURL()), r.Body)
 		newReq.Header = r.Header
@@ -15,6 +15,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/problem"
 	"github.com/iaconlabs/transwarp/router"
 )
 
@@ -23,6 +24,7 @@ const defaultMaxShadowCacheSize = 10000
 type routeEntry struct {
 	method       string
 	path         string
+	prefix       string
 	h            http.HandlerFunc
 	mws          []func(http.Handler) http.Handler
 	regex        *regexp.Regexp
@@ -43,9 +45,18 @@ type GinAdapter struct {
 }
 
 // NewGinAdapter initializes a new adapter with an internal Gin engine in release mode.
+// Unmatched routes are answered with RFC 9457 problem details.
 func NewGinAdapter() *GinAdapter {
 	gin.SetMode(gin.ReleaseMode)
 	e := gin.New()
+	e.HandleMethodNotAllowed = true
+	e.NoRoute(func(c *gin.Context) {
+		problem.NotFound(c.Writer, c.Request)
+	})
+	e.NoMethod(func(c *gin.Context) {
+		// Gin has already set the Allow header at this point.
+		problem.MethodNotAllowed(c.Writer, c.Request)
+	})
 	return &GinAdapter{
 		engine:       e,
 		prefix:       "",
@@ -188,7 +199,7 @@ func (a *GinAdapter) Engine() any { return a.engine }
 func (a *GinAdapter) register(m, p string, h http.HandlerFunc, mws ...func(http.Handler) http.Handler) {
 	full := a.prefix + "/" + strings.TrimPrefix(p, "/")
 	full = strings.ReplaceAll(full, "//", "/")
-	*a.routes = append(*a.routes, &routeEntry{method: m, path: full, h: h, mws: append(a.middlewares, mws...)})
+	*a.routes = append(*a.routes, &routeEntry{method: m, path: full, prefix: a.prefix, h: h, mws: append(a.middlewares, mws...)})
 }
 
 func (a *GinAdapter) preparePath(path string) (string, string) {
@@ -255,12 +266,13 @@ func (a *GinAdapter) deployShadowRouter(prefix string, routes []*routeEntry) {
 				return
 			}
 		}
-		c.Status(http.StatusNotFound)
+		problem.NotFound(c.Writer, c.Request)
 	})
 }
 
 func (a *GinAdapter) dispatchWithParams(c *gin.Context, r *routeEntry, path string) {
 	state, _ := c.Request.Context().Value(router.StateKey).(*adapter.TranswarpState)
+	state.Route, state.Prefix = r.path, r.prefix
 	matches := r.regex.FindStringSubmatch(path)
 
 	newParams := make(map[string]string)
@@ -282,8 +294,8 @@ func (a *GinAdapter) dispatchWithParams(c *gin.Context, r *routeEntry, path stri
 		newParams["path"] = val
 	}
 
-	newState := &adapter.TranswarpState{Params: newParams, Body: state.Body}
-	ctx := context.WithValue(c.Request.Context(), router.StateKey, newState)
+	newState := &adapter.TranswarpState{Params: newParams, Body: state.Body, Route: r.path, Prefix: r.prefix}
+	ctx := adapter.WithState(c.Request.Context(), newState)
 
 	var finalHandler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		r.h(w, req)
@@ -298,20 +310,38 @@ func (a *GinAdapter) dispatchWithParams(c *gin.Context, r *routeEntry, path stri
 
 func (a *GinAdapter) registerInGin(r *routeEntry) {
 	ginPath, wcName := a.preparePath(r.path)
-	handlers := a.createGinStack(r.h, r.mws, wcName)
+	handlers := a.createGinStack(r, wcName)
 	a.engine.Handle(r.method, ginPath, handlers...)
 }
 
-func (a *GinAdapter) createGinStack(h http.HandlerFunc, mws []func(http.Handler) http.Handler, wcName string) []gin.HandlerFunc {
-	var stack []gin.HandlerFunc
+func (a *GinAdapter) createGinStack(r *routeEntry, wcName string) []gin.HandlerFunc {
+	h := r.h
 
-	for _, mw := range mws {
+	// Middlewares run before the final handler rebuilds the state, so the
+	// matched route is recorded on the request state up front.
+	stack := []gin.HandlerFunc{func(c *gin.Context) {
+		state, ok := c.Request.Context().Value(router.StateKey).(*adapter.TranswarpState)
+		if !ok {
+			state = &adapter.TranswarpState{Params: make(map[string]string)}
+		}
+		state.Route, state.Prefix = r.path, r.prefix
+		c.Request = c.Request.WithContext(adapter.WithState(c.Request.Context(), state))
+	}}
+
+	for _, mw := range r.mws {
 		currentMw := mw
 		stack = append(stack, func(c *gin.Context) {
 			calledNext := false
 			stdNext := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				calledNext = true
 				c.Request = r
+				// The middleware may hand a different writer to next (buffering,
+				// compression...): the rest of the stack must write through it.
+				if w != http.ResponseWriter(c.Writer) {
+					original := c.Writer
+					c.Writer = newStdWriter(w)
+					defer func() { c.Writer = original }()
+				}
 				c.Next()
 			})
 			currentMw(stdNext).ServeHTTP(c.Writer, c.Request)
@@ -337,14 +367,15 @@ func (a *GinAdapter) createGinStack(h http.HandlerFunc, mws []func(http.Handler)
 		}
 
 		if wcName != "" {
-			val := c.Param(wcName)
+			// Gin keeps the leading slash of catch-all values; other adapters do not.
+			val := strings.TrimPrefix(c.Param(wcName), "/")
+			newParams[wcName] = val
 			newParams["*"] = val
 			newParams["path"] = val
 		}
 
-		newState := &adapter.TranswarpState{Params: newParams, Body: state.Body}
-		ctx := context.WithValue(c.Request.Context(), router.StateKey, newState)
-		h(c.Writer, c.Request.WithContext(ctx))
+		newState := &adapter.TranswarpState{Params: newParams, Body: state.Body, Route: r.path, Prefix: r.prefix}
+		h(c.Writer, c.Request.WithContext(adapter.WithState(c.Request.Context(), newState)))
 	})
 
 	return stack
@@ -0,0 +1,89 @@
+package ginadapter
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stdWriter exposes a net/http ResponseWriter provided by a middleware as a
+// gin.ResponseWriter, so the remaining Gin handlers write through it.
+type stdWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+var _ gin.ResponseWriter = (*stdWriter)(nil)
+
+func newStdWriter(w http.ResponseWriter) *stdWriter {
+	return &stdWriter{ResponseWriter: w, status: http.StatusOK, size: -1}
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (s *stdWriter) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+func (s *stdWriter) WriteHeader(code int) {
+	if s.Written() {
+		return
+	}
+	s.status = code
+	s.size = 0
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *stdWriter) WriteHeaderNow() {
+	if !s.Written() {
+		s.WriteHeader(s.status)
+	}
+}
+
+func (s *stdWriter) Write(p []byte) (int, error) {
+	s.WriteHeaderNow()
+	n, err := s.ResponseWriter.Write(p)
+	s.size += n
+	return n, err
+}
+
+func (s *stdWriter) WriteString(str string) (int, error) {
+	s.WriteHeaderNow()
+	n, err := io.WriteString(s.ResponseWriter, str)
+	s.size += n
+	return n, err
+}
+
+func (s *stdWriter) Status() int { return s.status }
+
+func (s *stdWriter) Size() int { return s.size }
+
+func (s *stdWriter) Written() bool { return s.size != -1 }
+
+func (s *stdWriter) Flush() {
+	s.WriteHeaderNow()
+	_ = http.NewResponseController(s.ResponseWriter).Flush()
+}
+
+func (s *stdWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if s.size < 0 {
+		s.size = 0
+	}
+	return http.NewResponseController(s.ResponseWriter).Hijack()
+}
+
+//nolint:staticcheck // Required by gin.ResponseWriter.
+func (s *stdWriter) CloseNotify() <-chan bool {
+	if cn, ok := s.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (s *stdWriter) Pusher() http.Pusher {
+	if p, ok := s.ResponseWriter.(http.Pusher); ok {
+		return p
+	}
+	return nil
+}
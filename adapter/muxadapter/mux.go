@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/problem"
 	"github.com/iaconlabs/transwarp/router"
 )
 
@@ -162,7 +163,39 @@ func (a *MuxAdapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Params: make(map[string]string),
 	}
 	ctx := context.WithValue(r.Context(), router.StateKey, state)
-	a.mux.ServeHTTP(w, r.WithContext(ctx))
+	r = r.WithContext(ctx)
+
+	// ServeMux answers unmatched requests with plain-text 404/405 replies.
+	// The interceptor turns those into problem details; matched routes receive
+	// the original writer (see wrapState).
+	uw := &unmatchedWriter{ResponseWriter: w}
+	a.mux.ServeHTTP(uw, r)
+	if uw.status != 0 {
+		problem.Write(w, r, problem.New(uw.status))
+	}
+}
+
+// unmatchedWriter captures the 404/405 replies ServeMux generates when no
+// registered pattern matches the request.
+type unmatchedWriter struct {
+	http.ResponseWriter
+	matched bool
+	status  int
+}
+
+func (u *unmatchedWriter) WriteHeader(code int) {
+	if !u.matched && (code == http.StatusNotFound || code == http.StatusMethodNotAllowed) {
+		u.status = code
+		return
+	}
+	u.ResponseWriter.WriteHeader(code)
+}
+
+func (u *unmatchedWriter) Write(b []byte) (int, error) {
+	if u.status != 0 {
+		return len(b), nil
+	}
+	return u.ResponseWriter.Write(b)
 }
 
 func (a *MuxAdapter) register(method, path string, h http.HandlerFunc, routeMws ...func(http.Handler) http.Handler) {
@@ -181,11 +214,20 @@ func (a *MuxAdapter) register(method, path string, h http.HandlerFunc, routeMws
 		finalHandler = a.middlewares[i](finalHandler)
 	}
 
-	a.mux.Handle(pattern, a.wrapState(finalHandler, keys))
+	route := a.joinPaths(a.prefix, path)
+	a.mux.Handle(pattern, a.wrapState(finalHandler, keys, route, strings.TrimSuffix(a.prefix, "/")))
 }
 
-func (a *MuxAdapter) wrapState(onion http.Handler, keys []string) http.Handler {
+// wrapState builds the request state of a matched route: its parameters, body
+// and Transwarp-style pattern (route) and group prefix.
+func (a *MuxAdapter) wrapState(onion http.Handler, keys []string, route, prefix string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A route matched: hand the original writer to the middleware chain.
+		if uw, ok := w.(*unmatchedWriter); ok {
+			uw.matched = true
+			w = uw.ResponseWriter
+		}
+
 		state, _ := r.Context().Value(router.StateKey).(*adapter.TranswarpState)
 		if state == nil {
 			state = &adapter.TranswarpState{Params: make(map[string]string)}
@@ -208,9 +250,8 @@ func (a *MuxAdapter) wrapState(onion http.Handler, keys []string) http.Handler {
 			r.Body = io.NopCloser(bytes.NewReader(body))
 		}
 
-		newState := &adapter.TranswarpState{Params: newParams, Body: body}
-		ctx := context.WithValue(r.Context(), router.StateKey, newState)
-		onion.ServeHTTP(w, r.WithContext(ctx))
+		newState := &adapter.TranswarpState{Params: newParams, Body: body, Route: route, Prefix: prefix}
+		onion.ServeHTTP(w, r.WithContext(adapter.WithState(r.Context(), newState)))
 	})
 }
 
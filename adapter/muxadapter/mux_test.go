@@ -1,10 +1,14 @@
 package muxadapter_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/iaconlabs/transwarp"
 	"github.com/iaconlabs/transwarp/adapter"
 	"github.com/iaconlabs/transwarp/adapter/muxadapter"
+	"github.com/iaconlabs/transwarp/middleware/auth"
 	"github.com/iaconlabs/transwarp/router"
 )
 
@@ -29,3 +33,34 @@ func TestChiAdapter_Advanced(t *testing.T) {
 		return muxadapter.NewMuxAdapter(muxadapter.SimpleCleanerMuxConfig())
 	})
 }
+
+// TestDenyByDefault_Routes verifica que las rutas registradas por Transwarp
+// (con grupos anidados y barra final) coinciden con el patrón que el adaptador
+// guarda en el estado, de modo que DenyByDefault solo rechaza las rutas sin
+// política.
+func TestDenyByDefault_Routes(t *testing.T) {
+	app := transwarp.New(muxadapter.NewMuxAdapter(muxadapter.SimpleCleanerMuxConfig()))
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+	api := app.Group("/api")
+	api.Use(auth.DenyByDefault(app))
+	api.GET("/status", ok, auth.Public())
+	api.GET("/orders/:id", ok, auth.Public())
+	api.GET("/forgotten", ok)
+	v1 := api.Group("/v1/")
+	v1.GET("/items/", ok, auth.Public())
+
+	cases := map[string]int{
+		"/api/status":    http.StatusNoContent,
+		"/api/orders/7":  http.StatusNoContent,
+		"/api/v1/items/": http.StatusNoContent,
+		"/api/forgotten": http.StatusForbidden,
+	}
+	for path, want := range cases {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s: se esperaba %d, obtenido %d", path, want, rec.Code)
+		}
+	}
+}
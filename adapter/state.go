@@ -2,7 +2,13 @@
 // standard net/http behavior with third-party web frameworks.
 package adapter
 
-import "strings"
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/iaconlabs/transwarp/router"
+)
 
 // TranswarpState centralizes request metadata such as route parameters and
 // the request body to avoid redundant context allocations.
@@ -11,6 +17,35 @@ type TranswarpState struct {
 	Params map[string]string
 	// Body stores a cached version of the request body for multiple reads.
 	Body []byte
+	// Route is the pattern of the matched route in Transwarp syntax, including
+	// group prefixes (e.g. "/api/users/:id"). It is empty until a route matches.
+	Route string
+	// Prefix is the group prefix the matched route was registered under
+	// (e.g. "/api"), or empty for routes registered on the root router.
+	Prefix string
+}
+
+// WithState returns a copy of ctx carrying state under router.StateKey and,
+// when a route has matched, its pattern under router.RouteKey.
+func WithState(ctx context.Context, state *TranswarpState) context.Context {
+	ctx = context.WithValue(ctx, router.StateKey, state)
+	if state.Route != "" {
+		ctx = context.WithValue(ctx, router.RouteKey, state.Route)
+	}
+	return ctx
+}
+
+// RoutePattern returns the pattern of the route that matched r, in Transwarp
+// syntax and including group prefixes (e.g. "/api/users/:id"), or "" when no
+// route matched. Adapters store a new request state once a route matches, so
+// the pattern is only visible to middlewares running after routing (installed
+// with Use rather than Transwarp.Pre) and to handlers.
+func RoutePattern(r *http.Request) string {
+	if state, ok := r.Context().Value(router.StateKey).(*TranswarpState); ok && state != nil && state.Route != "" {
+		return state.Route
+	}
+	route, _ := r.Context().Value(router.RouteKey).(string)
+	return route
 }
 
 // Clone creates a new string instance from the input to prevent race conditions
@@ -0,0 +1,187 @@
+// Command transwarp provides development tools for Transwarp applications.
+//
+// Usage:
+//
+//	transwarp gen client [flags]
+//
+// "gen client" generates typed HTTP clients from the application's route
+// table. It builds a temporary program that calls the registration function
+// of the given package (func RegisterRoutes(*transwarp.Transwarp) by default)
+// on a recording router, and writes a Go client and, optionally, a TypeScript
+// client from the registered routes and their DTO types.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+const usage = `Usage: transwarp gen client [flags]
+
+Generates typed API clients from the routes registered by a package.
+
+Flags:
+`
+
+// run executes the command and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) < 2 || args[0] != "gen" || args[1] != "client" {
+		fmt.Fprint(stderr, usage)
+		newClientFlags(stderr).PrintDefaults()
+		return 2
+	}
+
+	fs := newClientFlags(stderr)
+	if err := fs.Parse(args[2:]); err != nil {
+		return 2
+	}
+	cfg := clientConfig{
+		Pkg:     fs.Lookup("pkg").Value.String(),
+		Func:    fs.Lookup("func").Value.String(),
+		Out:     fs.Lookup("out").Value.String(),
+		Package: fs.Lookup("package").Value.String(),
+		Lang:    fs.Lookup("lang").Value.String(),
+	}
+	if err := genClient(cfg, stdout, stderr); err != nil {
+		fmt.Fprintln(stderr, "transwarp:", err)
+		return 1
+	}
+	return 0
+}
+
+func newClientFlags(output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("transwarp gen client", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.String("pkg", ".", "package that registers the routes (directory or import path); it cannot be package main")
+	fs.String("func", "RegisterRoutes", "exported function of -pkg that registers the routes on a *transwarp.Transwarp")
+	fs.String("out", "client", "output directory")
+	fs.String("package", "", "name of the generated Go package (default: base name of -out)")
+	fs.String("lang", "go", "comma-separated client languages: go, ts")
+	return fs
+}
+
+// clientConfig holds the flags of "gen client".
+type clientConfig struct {
+	Pkg     string
+	Func    string
+	Out     string
+	Package string
+	Lang    string
+
+	ImportPath string
+	Go         bool
+	TypeScript bool
+}
+
+// genClient builds and runs the generator program inside the current module.
+func genClient(cfg clientConfig, stdout, stderr io.Writer) error {
+	for _, lang := range strings.Split(cfg.Lang, ",") {
+		switch strings.TrimSpace(lang) {
+		case "go":
+			cfg.Go = true
+		case "ts", "typescript":
+			cfg.TypeScript = true
+		default:
+			return fmt.Errorf("unknown client language %q", lang)
+		}
+	}
+
+	importPath, name, err := resolvePackage(cfg.Pkg)
+	if err != nil {
+		return err
+	}
+	if name == "main" {
+		return fmt.Errorf("%s is package main and cannot be imported: move %s to a library package", cfg.Pkg, cfg.Func)
+	}
+	cfg.ImportPath = importPath
+
+	out, err := filepath.Abs(cfg.Out)
+	if err != nil {
+		return err
+	}
+	cfg.Out = out
+
+	// The program must live inside the module to import the application. The
+	// underscore keeps it out of "./..." patterns while it exists.
+	dir, err := os.MkdirTemp(".", "_transwarp_gen")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var src bytes.Buffer
+	if err := generatorTemplate.Execute(&src, cfg); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), src.Bytes(), 0o600); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("go", "run", "./"+filepath.ToSlash(dir))
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running the generator: %w", err)
+	}
+	return nil
+}
+
+// resolvePackage returns the import path and name of a package pattern.
+func resolvePackage(pkg string) (string, string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", "list", "-f", "{{.ImportPath}} {{.Name}}", pkg)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("resolving %s: %w: %s", pkg, err, strings.TrimSpace(stderr.String()))
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return "", "", errors.New("resolving " + pkg + ": expected a single package")
+	}
+	return fields[0], fields[1], nil
+}
+
+var generatorTemplate = template.Must(template.New("main").Parse(`// Code generated by transwarp gen client. DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/iaconlabs/transwarp"
+	"github.com/iaconlabs/transwarp/codegen"
+
+	app "{{.ImportPath}}"
+)
+
+func main() {
+	t := transwarp.New(codegen.NewRecorder())
+	app.{{.Func}}(t)
+
+	err := codegen.Write(t.Routes(), codegen.Options{
+		Dir:        {{printf "%q" .Out}},
+		Package:    {{printf "%q" .Package}},
+		Go:         {{.Go}},
+		TypeScript: {{.TypeScript}},
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("generated clients for %d routes in %s\n", len(t.Routes()), {{printf "%q" .Out}})
+}
+`))
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestRun_Usage verifies that unknown commands print the usage and exit with 2.
+func TestRun_Usage(t *testing.T) {
+	for _, args := range [][]string{nil, {"gen"}, {"gen", "server"}} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("run(%q): expected exit code 2, got %d", args, code)
+		}
+		if !strings.Contains(stderr.String(), "Usage: transwarp gen client") {
+			t.Errorf("run(%q): expected the usage, got %q", args, stderr.String())
+		}
+	}
+}
+
+// TestRun_UnknownLanguage verifies that -lang is checked before building anything.
+func TestRun_UnknownLanguage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"gen", "client", "-lang", "go,rust"}, &stdout, &stderr)
+	if code != 1 {
+		t.Errorf("Expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown client language "rust"`) {
+		t.Errorf("Unexpected error output: %q", stderr.String())
+	}
+}
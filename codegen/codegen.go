@@ -0,0 +1,270 @@
+// Package codegen generates typed HTTP clients from the route table of a
+// Transwarp application: one method per route, path parameters from ":name"
+// segments, and request and response types from the DTOs attached by
+// documented middlewares such as middleware.Validate.
+//
+// It is usually driven by the transwarp command ("transwarp gen client"),
+// which builds a small program that registers the application's routes on a
+// Recorder and calls Write with the result.
+package codegen
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// Options configures Write.
+type Options struct {
+	// Dir is the output directory, created if needed.
+	Dir string
+	// Package is the name of the generated Go package. Defaults to the base name of Dir.
+	Package string
+	// Go emits client.go.
+	Go bool
+	// TypeScript emits client.ts.
+	TypeScript bool
+}
+
+// Write generates the requested clients for routes into opts.Dir.
+func Write(routes []router.Route, opts Options) error {
+	if !opts.Go && !opts.TypeScript {
+		return errors.New("codegen: no client language selected")
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return err
+	}
+
+	if opts.Go {
+		pkg := opts.Package
+		if pkg == "" {
+			abs, err := filepath.Abs(opts.Dir)
+			if err != nil {
+				return err
+			}
+			pkg = strings.ToLower(identifier(filepath.Base(abs), false))
+		}
+		src, err := GoClient(routes, pkg)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(opts.Dir, "client.go"), src, 0o644); err != nil {
+			return err
+		}
+	}
+	if opts.TypeScript {
+		if err := os.WriteFile(filepath.Join(opts.Dir, "client.ts"), TypeScriptClient(routes), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endpoint is a route prepared for code generation.
+type endpoint struct {
+	name        string // exported method name, e.g. GetUsersById
+	method      string
+	path        string
+	params      []string // path parameter names in order
+	request     reflect.Type
+	contentType string
+	response    reflect.Type
+}
+
+// endpoints converts routes into endpoints with unique method names. OPTIONS
+// routes (CORS preflight) are skipped.
+func endpoints(routes []router.Route) []endpoint {
+	var out []endpoint
+	used := make(map[string]int)
+	seen := make(map[string]bool)
+
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		if route.Method == http.MethodOptions || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		desc := route.Describe()
+		ep := endpoint{
+			name:     methodName(route.Method, route.Path),
+			method:   route.Method,
+			path:     route.Path,
+			params:   pathParams(route.Path),
+			response: desc.Response,
+		}
+		if used[ep.name]++; used[ep.name] > 1 {
+			ep.name += strconv.Itoa(used[ep.name])
+		}
+		if desc.Request != nil && hasBody(route.Method) {
+			ep.request = desc.Request
+			ep.contentType = "application/json"
+			if len(desc.RequestContentTypes) > 0 {
+				ep.contentType = desc.RequestContentTypes[0]
+			}
+		}
+		out = append(out, ep)
+	}
+	return out
+}
+
+// isPatch reports whether the endpoint takes a patch document rather than the resource.
+func (ep endpoint) isPatch() bool {
+	return strings.HasSuffix(ep.contentType, "patch+json")
+}
+
+// pathParams returns the names of the ":name" and "*name" segments of path.
+func pathParams(path string) []string {
+	var params []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" || (seg[0] != ':' && seg[0] != '*') {
+			continue
+		}
+		name := seg[1:]
+		if seg[0] == ':' {
+			name, _, _ = strings.Cut(name, ".")
+		}
+		if name == "" {
+			name = "path"
+		}
+		params = append(params, name)
+	}
+	return params
+}
+
+// methodName builds an exported name such as "GetUsersById" from a route.
+func methodName(method, path string) string {
+	var b strings.Builder
+	b.WriteString(identifier(strings.ToLower(method), true))
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" {
+			continue
+		}
+		if seg[0] == ':' || seg[0] == '*' {
+			b.WriteString("By")
+			seg = seg[1:]
+		}
+		b.WriteString(identifier(seg, true))
+	}
+	return b.String()
+}
+
+// identifier converts s into a camel-case identifier ("org_id" becomes "orgId",
+// or "OrgId" when exported).
+func identifier(s string, exported bool) string {
+	var b strings.Builder
+	upper := exported
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = b.Len() > 0 || exported
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteByte('_')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		} else if b.Len() == 0 {
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func hasBody(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	default:
+		return false
+	}
+}
+
+// typeNames assigns unique names to the named types emitted by a generator,
+// qualifying them with their package when two packages use the same name.
+type typeNames struct {
+	names map[reflect.Type]string
+	taken map[string]bool
+	order []reflect.Type
+}
+
+func newTypeNames() *typeNames {
+	return &typeNames{names: make(map[reflect.Type]string), taken: make(map[string]bool)}
+}
+
+// name returns the name of t and whether it was seen for the first time.
+func (n *typeNames) name(t reflect.Type) (string, bool) {
+	if name, ok := n.names[t]; ok {
+		return name, false
+	}
+	name := identifier(t.Name(), true)
+	if n.taken[name] {
+		pkg := t.PkgPath()
+		if idx := strings.LastIndexByte(pkg, '/'); idx != -1 {
+			pkg = pkg[idx+1:]
+		}
+		name = identifier(pkg, true) + name
+		for i := 2; n.taken[name]; i++ {
+			name = fmt.Sprintf("%s%d", strings.TrimRight(name, "0123456789"), i)
+		}
+	}
+	n.names[t] = name
+	n.taken[name] = true
+	n.order = append(n.order, t)
+	return name, true
+}
+
+// jsonField describes a struct field as encoded by encoding/json.
+type jsonField struct {
+	goName   string
+	jsonName string
+	tag      string
+	typ      reflect.Type
+	optional bool
+}
+
+// jsonFields lists the members of a struct body, flattening embedded structs
+// and skipping fields bound only from the path ("param" tag without "json").
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if sf.Anonymous && name == "" {
+			et := sf.Type
+			if et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				fields = append(fields, jsonFields(et)...)
+				continue
+			}
+		}
+		if !sf.IsExported() || (tag == "" && (sf.Tag.Get("param") != "" || sf.Tag.Get("claim") != "")) {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		optional := strings.Contains(","+opts+",", ",omitempty,") || strings.Contains(","+opts+",", ",omitzero,")
+		fields = append(fields, jsonField{goName: sf.Name, jsonName: name, tag: tag, typ: sf.Type, optional: optional})
+	}
+	return fields
+}
@@ -0,0 +1,160 @@
+package codegen_test
+
+import (
+	"go/parser"
+	"go/token"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iaconlabs/transwarp"
+	"github.com/iaconlabs/transwarp/codegen"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+type Status string
+
+type CreateCustomer struct {
+	OrgID  int      `param:"org_id" validate:"required,min=1"`
+	Name   string   `json:"name" validate:"required"`
+	Email  string   `json:"email,omitempty"`
+	Status Status   `json:"status"`
+	Tags   []string `json:"tags"`
+}
+
+type Customer struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Referrer  *Customer `json:"referrer,omitempty"`
+}
+
+func loadCustomer(*http.Request) (*Customer, error) {
+	return &Customer{}, nil
+}
+
+// registerRoutes mimics an application's route registration function.
+func registerRoutes(t *transwarp.Transwarp) {
+	noop := func(http.ResponseWriter, *http.Request) {}
+	api := t.Group("/orgs/:org_id")
+	api.POST("/customers", noop,
+		middleware.Validate(CreateCustomer{}),
+		middleware.ValidateResponse(Customer{}))
+	api.GET("/customers/:id", noop, middleware.ValidateResponse(Customer{}))
+	api.HandleFunc(http.MethodPatch, "/customers/:id", noop, middleware.ValidatePatch(loadCustomer))
+	api.DELETE("/customers/:id", noop)
+	t.GET("/files/*path", noop)
+}
+
+func routes() []router.Route {
+	t := transwarp.New(codegen.NewRecorder())
+	registerRoutes(t)
+	return t.Routes()
+}
+
+// TestGoClient verifies the generated methods, their signatures and the mirrored DTOs.
+func TestGoClient(t *testing.T) {
+	src, err := codegen.GoClient(routes(), "api")
+	if err != nil {
+		t.Fatalf("GoClient failed: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "client.go", src, 0); err != nil {
+		t.Fatalf("Generated client does not parse: %v\n%s", err, src)
+	}
+
+	code := string(src)
+	for _, want := range []string{
+		"package api",
+		"func (c *Client) PostOrgsByOrgIdCustomers(ctx context.Context, orgId string, body *CreateCustomer) (*Customer, error)",
+		"func (c *Client) GetOrgsByOrgIdCustomersById(ctx context.Context, orgId string, id string) (*Customer, error)",
+		"func (c *Client) PatchOrgsByOrgIdCustomersById(ctx context.Context, orgId string, id string, body any) (json.RawMessage, error)",
+		"func (c *Client) DeleteOrgsByOrgIdCustomersById(ctx context.Context, orgId string, id string) (json.RawMessage, error)",
+		"func (c *Client) GetFilesByPath(ctx context.Context, path string) (json.RawMessage, error)",
+		`"/orgs/"+url.PathEscape(orgId)+"/customers/"+url.PathEscape(id)`,
+		`"/files/"+escapeWildcard(path)`,
+		"type Status string",
+		"CreatedAt time.Time `json:\"created_at\"`",
+		"Referrer  *Customer `json:\"referrer,omitempty\"`",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected the client to contain %q", want)
+		}
+	}
+	if strings.Contains(code, "OrgID") {
+		t.Error("Path-only fields must not be part of the request body")
+	}
+}
+
+// TestGoClient_Compiles builds the generated client in a scratch module.
+func TestGoClient_Compiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping compilation in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not available")
+	}
+
+	dir := t.TempDir()
+	if err := codegen.Write(routes(), codegen.Options{Dir: dir, Package: "api", Go: true}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/api\n\ngo 1.22\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Generated client does not compile: %v\n%s", err, out)
+	}
+}
+
+// TestTypeScriptClient verifies the generated interfaces and methods.
+func TestTypeScriptClient(t *testing.T) {
+	code := string(codegen.TypeScriptClient(routes()))
+	for _, want := range []string{
+		"export interface CreateCustomer {",
+		`"email"?: string;`,
+		`"status": Status;`,
+		"export type Status = string;",
+		`"referrer"?: Customer | null;`,
+		"postOrgsByOrgIdCustomers(orgId: string, body: CreateCustomer, init?: RequestInit): Promise<Customer>",
+		"patchOrgsByOrgIdCustomersById(orgId: string, id: string, body: unknown, init?: RequestInit): Promise<unknown>",
+		"`/orgs/${encodeURIComponent(orgId)}/customers/${encodeURIComponent(id)}`",
+		`"application/merge-patch+json"`,
+		"export class ApiError extends Error",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected the client to contain %q", want)
+		}
+	}
+}
+
+// TestWrite verifies the output files and the default package name.
+func TestWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my-client")
+	if err := codegen.Write(routes(), codegen.Options{Dir: dir, Go: true, TypeScript: true}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	src, err := os.ReadFile(filepath.Join(dir, "client.go"))
+	if err != nil {
+		t.Fatalf("client.go not written: %v", err)
+	}
+	if !strings.Contains(string(src), "package myclient\n") {
+		t.Errorf("Expected the package name derived from the directory, got:\n%.200s", src)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "client.ts")); err != nil {
+		t.Errorf("client.ts not written: %v", err)
+	}
+
+	if err := codegen.Write(nil, codegen.Options{Dir: dir}); err == nil {
+		t.Error("Expected an error when no language is selected")
+	}
+}
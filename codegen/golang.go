@@ -0,0 +1,353 @@
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/iaconlabs/transwarp/router"
+)
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// goGen accumulates the type declarations of a Go client.
+type goGen struct {
+	names    *typeNames
+	decls    strings.Builder
+	usesTime bool
+}
+
+// GoClient returns the source of a Go client package for routes. Each route
+// becomes a method of Client taking a context, its path parameters and, for
+// POST, PUT and PATCH routes with a request DTO, the body. Methods return the
+// response DTO declared with middleware.ValidateResponse, or the raw JSON body.
+func GoClient(routes []router.Route, pkg string) ([]byte, error) {
+	g := &goGen{names: newTypeNames()}
+	var methods strings.Builder
+	for _, ep := range endpoints(routes) {
+		g.method(&methods, ep)
+	}
+
+	var b strings.Builder
+	b.WriteString("// Code generated by transwarp gen client. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "// Package %s is a typed client for the API.\n", pkg)
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	b.WriteString("import (\n\t\"bytes\"\n\t\"context\"\n\t\"encoding/json\"\n\t\"fmt\"\n\t\"io\"\n\t\"net/http\"\n\t\"net/url\"\n\t\"strings\"\n")
+	if g.usesTime {
+		b.WriteString("\t\"time\"\n")
+	}
+	b.WriteString(")\n")
+	b.WriteString(goRuntime)
+	b.WriteString(g.decls.String())
+	b.WriteString(methods.String())
+
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("codegen: formatting Go client: %w", err)
+	}
+	return src, nil
+}
+
+// method writes the client method of an endpoint.
+func (g *goGen) method(b *strings.Builder, ep endpoint) {
+	args := []string{"ctx context.Context"}
+	used := map[string]bool{"ctx": true, "body": true, "c": true, "out": true, "err": true}
+	names := make(map[string]string, len(ep.params))
+	for _, p := range ep.params {
+		name := identifier(p, false)
+		if used[name] || token.IsKeyword(name) {
+			name += "Param"
+		}
+		used[name] = true
+		names[p] = name
+		args = append(args, name+" string")
+	}
+
+	bodyArg := "nil"
+	if ep.request != nil {
+		bodyArg = "body"
+		if ep.isPatch() {
+			args = append(args, "body any")
+		} else {
+			args = append(args, "body *"+g.typeExpr(indirect(ep.request)))
+		}
+	}
+
+	fmt.Fprintf(b, "\n// %s calls %s %s.\n", ep.name, ep.method, ep.path)
+	if ep.isPatch() {
+		fmt.Fprintf(b, "// The body is sent as %s.\n", ep.contentType)
+	}
+
+	path := g.pathExpr(ep.path, names)
+	if ep.response != nil {
+		out := g.typeExpr(indirect(ep.response))
+		fmt.Fprintf(b, "func (c *Client) %s(%s) (*%s, error) {\n", ep.name, strings.Join(args, ", "), out)
+		fmt.Fprintf(b, "\tvar out %s\n", out)
+		fmt.Fprintf(b, "\tif err := c.do(ctx, %q, %s, %s, %q, &out); err != nil {\n\t\treturn nil, err\n\t}\n", ep.method, path, bodyArg, ep.contentType)
+		b.WriteString("\treturn &out, nil\n}\n")
+		return
+	}
+	fmt.Fprintf(b, "func (c *Client) %s(%s) (json.RawMessage, error) {\n", ep.name, strings.Join(args, ", "))
+	b.WriteString("\tvar out json.RawMessage\n")
+	fmt.Fprintf(b, "\terr := c.do(ctx, %q, %s, %s, %q, &out)\n", ep.method, path, bodyArg, ep.contentType)
+	b.WriteString("\treturn out, err\n}\n")
+}
+
+// pathExpr builds the Go expression of a request path, escaping parameters.
+func (g *goGen) pathExpr(path string, names map[string]string) string {
+	var parts []string
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, fmt.Sprintf("%q", literal.String()))
+			literal.Reset()
+		}
+	}
+
+	for i, seg := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if i > 0 || strings.HasPrefix(path, "/") {
+			literal.WriteByte('/')
+		}
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			name, suffix, _ := strings.Cut(seg[1:], ".")
+			flush()
+			parts = append(parts, "url.PathEscape("+names[name]+")")
+			if suffix != "" {
+				literal.WriteString("." + suffix)
+			}
+		case strings.HasPrefix(seg, "*"):
+			name := seg[1:]
+			if name == "" {
+				name = "path"
+			}
+			flush()
+			parts = append(parts, "escapeWildcard("+names[name]+")")
+		default:
+			literal.WriteString(seg)
+		}
+	}
+	flush()
+	if len(parts) == 0 {
+		return `"/"`
+	}
+	return strings.Join(parts, " + ")
+}
+
+// typeExpr returns the Go type expression of t, declaring named types.
+func (g *goGen) typeExpr(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		g.usesTime = true
+		return "time.Time"
+	case t == rawMessageType:
+		return "json.RawMessage"
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + g.typeExpr(t.Elem())
+	case reflect.Struct:
+		if t.Name() == "" {
+			return "struct {\n" + g.fields(t) + "}"
+		}
+		name, first := g.names.name(t)
+		if first {
+			body := g.fields(t)
+			fmt.Fprintf(&g.decls, "\n// %s mirrors %s.\ntype %s struct {\n%s}\n", name, t.String(), name, body)
+		}
+		return name
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "[]byte"
+		}
+		return "[]" + g.typeExpr(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), g.typeExpr(t.Elem()))
+	case reflect.Map:
+		return "map[" + g.typeExpr(t.Key()) + "]" + g.typeExpr(t.Elem())
+	case reflect.Interface, reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Invalid:
+		return "any"
+	}
+
+	// Basic kinds: named types (type Status string) are declared as well.
+	basic := t.Kind().String()
+	if t.Name() == "" || t.PkgPath() == "" {
+		return basic
+	}
+	name, first := g.names.name(t)
+	if first {
+		fmt.Fprintf(&g.decls, "\n// %s mirrors %s.\ntype %s %s\n", name, t.String(), name, basic)
+	}
+	return name
+}
+
+// fields returns the field declarations of a struct body.
+func (g *goGen) fields(t reflect.Type) string {
+	var b strings.Builder
+	var names []string
+	for _, f := range jsonFields(t) {
+		if slices.Contains(names, f.goName) {
+			continue
+		}
+		names = append(names, f.goName)
+		typ := g.typeExpr(f.typ)
+		if f.tag != "" {
+			fmt.Fprintf(&b, "\t%s %s `json:%q`\n", f.goName, typ, f.tag)
+		} else {
+			fmt.Fprintf(&b, "\t%s %s\n", f.goName, typ)
+		}
+	}
+	return b.String()
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// goRuntime is the part of the Go client that does not depend on the routes.
+const goRuntime = `
+// Client calls the API. Create it with New.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	editors    []func(*http.Request) error
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the *http.Client used to send requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithRequestEditor registers a function that can modify every request before
+// it is sent, e.g. to add authentication headers.
+func WithRequestEditor(fn func(*http.Request) error) Option {
+	return func(c *Client) {
+		c.editors = append(c.editors, fn)
+	}
+}
+
+// New returns a Client for the API served at baseURL.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{baseURL: strings.TrimSuffix(baseURL, "/"), httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// FieldError is a validation failure reported in the "errors" member of a problem.
+type FieldError struct {
+	Field   string ` + "`json:\"field\"`" + `
+	Rule    string ` + "`json:\"rule\"`" + `
+	Param   string ` + "`json:\"param,omitempty\"`" + `
+	Value   any    ` + "`json:\"value,omitempty\"`" + `
+	Message string ` + "`json:\"message\"`" + `
+}
+
+// Problem is an RFC 9457 problem details response.
+type Problem struct {
+	Type     string       ` + "`json:\"type,omitempty\"`" + `
+	Title    string       ` + "`json:\"title,omitempty\"`" + `
+	Status   int          ` + "`json:\"status,omitempty\"`" + `
+	Detail   string       ` + "`json:\"detail,omitempty\"`" + `
+	Instance string       ` + "`json:\"instance,omitempty\"`" + `
+	Errors   []FieldError ` + "`json:\"errors,omitempty\"`" + `
+}
+
+// Error is returned for responses outside the 2xx range. Problem is set when
+// the server responded with problem details.
+type Error struct {
+	StatusCode int
+	Problem    *Problem
+	Body       []byte
+}
+
+func (e *Error) Error() string {
+	if e.Problem != nil && e.Problem.Detail != "" {
+		return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Problem.Detail)
+	}
+	return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// do sends a request and decodes a successful JSON response into out.
+func (c *Client) do(ctx context.Context, method, path string, body any, contentType string, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, edit := range c.editors {
+		if err := edit(req); err != nil {
+			return err
+		}
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		apiErr := &Error{StatusCode: res.StatusCode, Body: data}
+		if strings.HasPrefix(res.Header.Get("Content-Type"), "application/problem+json") {
+			var p Problem
+			if json.Unmarshal(data, &p) == nil {
+				apiErr.Problem = &p
+			}
+		}
+		return apiErr
+	}
+
+	if raw, ok := out.(*json.RawMessage); ok {
+		*raw = data
+		return nil
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// escapeWildcard escapes each segment of a catch-all parameter.
+func escapeWildcard(value string) string {
+	segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+`
@@ -0,0 +1,61 @@
+package codegen
+
+import (
+	"net/http"
+
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// Ensure Recorder implements the Router interface.
+var _ router.Router = Recorder{}
+
+// Recorder is a router.Router that registers nothing. Wrapped by
+// transwarp.New, it lets a program collect an application's route table
+// (Transwarp.Routes) without a web framework or a listening server.
+type Recorder struct{}
+
+// NewRecorder returns a Recorder.
+func NewRecorder() Recorder {
+	return Recorder{}
+}
+
+// ServeHTTP responds 404 to every request.
+func (Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	http.NotFound(w, r)
+}
+
+// GET does nothing.
+func (Recorder) GET(string, http.HandlerFunc, ...func(http.Handler) http.Handler) {}
+
+// POST does nothing.
+func (Recorder) POST(string, http.HandlerFunc, ...func(http.Handler) http.Handler) {}
+
+// PUT does nothing.
+func (Recorder) PUT(string, http.HandlerFunc, ...func(http.Handler) http.Handler) {}
+
+// DELETE does nothing.
+func (Recorder) DELETE(string, http.HandlerFunc, ...func(http.Handler) http.Handler) {}
+
+// OPTIONS does nothing.
+func (Recorder) OPTIONS(string, http.HandlerFunc, ...func(http.Handler) http.Handler) {}
+
+// ANY does nothing.
+func (Recorder) ANY(string, http.HandlerFunc, ...func(http.Handler) http.Handler) {}
+
+// Handle does nothing.
+func (Recorder) Handle(string, string, http.Handler, ...func(http.Handler) http.Handler) {}
+
+// HandleFunc does nothing.
+func (Recorder) HandleFunc(string, string, http.HandlerFunc, ...func(http.Handler) http.Handler) {}
+
+// Use does nothing.
+func (Recorder) Use(...func(http.Handler) http.Handler) {}
+
+// Param always returns an empty string.
+func (Recorder) Param(*http.Request, string) string { return "" }
+
+// Group returns the same Recorder.
+func (r Recorder) Group(string) router.Router { return r }
+
+// Engine returns nil.
+func (Recorder) Engine() any { return nil }
@@ -0,0 +1,268 @@
+package codegen
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// tsGen accumulates the type declarations of a TypeScript client.
+type tsGen struct {
+	names *typeNames
+	decls strings.Builder
+}
+
+// TypeScriptClient returns the source of a TypeScript client for routes, using
+// the Fetch API. Each route becomes an async method of Client whose name is the
+// camel-case form of the Go method name (e.g. getUsersById).
+func TypeScriptClient(routes []router.Route) []byte {
+	g := &tsGen{names: newTypeNames()}
+	var methods strings.Builder
+	for _, ep := range endpoints(routes) {
+		g.method(&methods, ep)
+	}
+
+	var b strings.Builder
+	b.WriteString("// Code generated by transwarp gen client. DO NOT EDIT.\n")
+	b.WriteString(tsRuntimeTypes)
+	b.WriteString(g.decls.String())
+	b.WriteString(tsClientHead)
+	b.WriteString(methods.String())
+	b.WriteString(tsClientTail)
+	return []byte(b.String())
+}
+
+// method writes the client method of an endpoint.
+func (g *tsGen) method(b *strings.Builder, ep endpoint) {
+	var args []string
+	used := map[string]bool{"body": true, "init": true}
+	names := make(map[string]string, len(ep.params))
+	for _, p := range ep.params {
+		name := identifier(p, false)
+		if used[name] {
+			name += "Param"
+		}
+		used[name] = true
+		names[p] = name
+		args = append(args, name+": string")
+	}
+
+	bodyArg := "undefined"
+	if ep.request != nil {
+		bodyArg = "body"
+		if ep.isPatch() {
+			args = append(args, "body: unknown")
+		} else {
+			args = append(args, "body: "+g.typeExpr(indirect(ep.request)))
+		}
+	}
+	args = append(args, "init?: RequestInit")
+
+	result := "unknown"
+	if ep.response != nil {
+		result = g.typeExpr(indirect(ep.response))
+	}
+
+	name := identifier(ep.name, false)
+	fmt.Fprintf(b, "\n  /** %s %s */\n", ep.method, ep.path)
+	fmt.Fprintf(b, "  %s(%s): Promise<%s> {\n", name, strings.Join(args, ", "), result)
+	fmt.Fprintf(b, "    return this.request(%q, %s, %s, %q, init) as Promise<%s>;\n  }\n",
+		ep.method, tsPathExpr(ep.path, names), bodyArg, ep.contentType, result)
+}
+
+// tsPathExpr builds the template literal of a request path.
+func tsPathExpr(path string, names map[string]string) string {
+	var b strings.Builder
+	b.WriteByte('`')
+	for i, seg := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if i > 0 || strings.HasPrefix(path, "/") {
+			b.WriteByte('/')
+		}
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			name, suffix, _ := strings.Cut(seg[1:], ".")
+			b.WriteString("${encodeURIComponent(" + names[name] + ")}")
+			if suffix != "" {
+				b.WriteString("." + suffix)
+			}
+		case strings.HasPrefix(seg, "*"):
+			name := seg[1:]
+			if name == "" {
+				name = "path"
+			}
+			b.WriteString("${" + names[name] + `.split("/").map(encodeURIComponent).join("/")}`)
+		default:
+			b.WriteString(strings.ReplaceAll(seg, "`", "\\`"))
+		}
+	}
+	b.WriteByte('`')
+	return b.String()
+}
+
+// typeExpr returns the TypeScript type of t, declaring named types.
+func (g *tsGen) typeExpr(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "string"
+	case t == rawMessageType:
+		return "unknown"
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.typeExpr(t.Elem()) + " | null"
+	case reflect.Struct:
+		if t.Name() == "" {
+			return "{\n" + g.fields(t, "    ") + "  }"
+		}
+		name, first := g.names.name(t)
+		if first {
+			body := g.fields(t, "  ")
+			fmt.Fprintf(&g.decls, "\n/** Mirrors %s. */\nexport interface %s {\n%s}\n", t.String(), name, body)
+		}
+		return name
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json encodes byte slices as base64 strings.
+			return "string"
+		}
+		elem := g.typeExpr(t.Elem())
+		if strings.Contains(elem, "|") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case reflect.Map:
+		return "Record<string, " + g.typeExpr(t.Elem()) + ">"
+	case reflect.Bool:
+		return g.named(t, "boolean")
+	case reflect.String:
+		return g.named(t, "string")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return g.named(t, "number")
+	default:
+		return "unknown"
+	}
+}
+
+// named declares a type alias for named basic types (type Status string).
+func (g *tsGen) named(t reflect.Type, basic string) string {
+	if t.Name() == "" || t.PkgPath() == "" {
+		return basic
+	}
+	name, first := g.names.name(t)
+	if first {
+		fmt.Fprintf(&g.decls, "\n/** Mirrors %s. */\nexport type %s = %s;\n", t.String(), name, basic)
+	}
+	return name
+}
+
+// fields returns the member declarations of an object type.
+func (g *tsGen) fields(t reflect.Type, indent string) string {
+	var b strings.Builder
+	seen := make(map[string]bool)
+	for _, f := range jsonFields(t) {
+		if seen[f.jsonName] {
+			continue
+		}
+		seen[f.jsonName] = true
+		optional := ""
+		if f.optional {
+			optional = "?"
+		}
+		fmt.Fprintf(&b, "%s%q%s: %s;\n", indent, f.jsonName, optional, g.typeExpr(f.typ))
+	}
+	return b.String()
+}
+
+// tsRuntimeTypes declares the error types shared by every client.
+const tsRuntimeTypes = `
+/** A validation failure reported in the "errors" member of a problem. */
+export interface FieldError {
+  field: string;
+  rule: string;
+  param?: string;
+  value?: unknown;
+  message: string;
+}
+
+/** An RFC 9457 problem details response. */
+export interface Problem {
+  type?: string;
+  title?: string;
+  status?: number;
+  detail?: string;
+  instance?: string;
+  errors?: FieldError[];
+  [extension: string]: unknown;
+}
+
+/** Thrown for responses outside the 2xx range. */
+export class ApiError extends Error {
+  constructor(
+    public readonly status: number,
+    public readonly problem: Problem | undefined,
+    public readonly body: string,
+  ) {
+    super(problem?.detail ?? problem?.title ?? ` + "`HTTP ${status}`" + `);
+    this.name = "ApiError";
+  }
+}
+
+export interface ClientOptions {
+  /** Base URL of the API, e.g. "https://api.example.com". */
+  baseURL: string;
+  /** Fetch implementation; defaults to the global fetch. */
+  fetch?: typeof fetch;
+  /** Headers sent with every request, e.g. authentication. */
+  headers?: Record<string, string> | (() => Record<string, string> | Promise<Record<string, string>>);
+}
+`
+
+const tsClientHead = `
+export class Client {
+  private readonly baseURL: string;
+
+  constructor(private readonly options: ClientOptions) {
+    this.baseURL = options.baseURL.replace(/\/+$/, "");
+  }
+`
+
+const tsClientTail = `
+  private async request(method: string, path: string, body: unknown, contentType: string, init?: RequestInit): Promise<unknown> {
+    const shared = typeof this.options.headers === "function" ? await this.options.headers() : this.options.headers;
+    const headers = new Headers(init?.headers);
+    for (const [key, value] of Object.entries(shared ?? {})) {
+      headers.set(key, value);
+    }
+    headers.set("Accept", "application/json");
+    if (body !== undefined) {
+      headers.set("Content-Type", contentType);
+    }
+
+    const doFetch = this.options.fetch ?? fetch;
+    const res = await doFetch(this.baseURL + path, {
+      ...init,
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const text = await res.text();
+    if (!res.ok) {
+      let problem: Problem | undefined;
+      if ((res.headers.get("Content-Type") ?? "").startsWith("application/problem+json")) {
+        try {
+          problem = JSON.parse(text) as Problem;
+        } catch {
+          problem = undefined;
+        }
+      }
+      throw new ApiError(res.status, problem, text);
+    }
+    return text === "" ? undefined : JSON.parse(text);
+  }
+}
+`
@@ -0,0 +1,86 @@
+package transwarp
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/iaconlabs/transwarp/openapi"
+	"github.com/iaconlabs/transwarp/problem"
+)
+
+//go:embed docsui
+var docsFS embed.FS
+
+var docsIndex = template.Must(template.ParseFS(docsFS, "docsui/index.html"))
+
+// docsPolicy only allows resources served by Docs itself and requests to the API.
+const docsPolicy = "default-src 'self'; img-src 'self' data:; frame-ancestors 'self'"
+
+// DocsOption configures Docs.
+type DocsOption func(*docsConfig)
+
+type docsConfig struct {
+	info openapi.Info
+}
+
+// DocsInfo sets the title, version and description of the served document.
+func DocsInfo(info openapi.Info) DocsOption {
+	return func(c *docsConfig) {
+		c.info = info
+	}
+}
+
+// Docs mounts the API documentation under prefix (e.g. "/docs"): the prefix
+// serves an interactive viewer and prefix + "/openapi.json" the OpenAPI
+// document of every route registered through t. The viewer is embedded in the
+// binary and loads nothing from external hosts, so it works offline.
+//
+// It registers two GET routes, prefix and prefix + "/*file", and works with
+// every adapter.
+func Docs(t *Transwarp, prefix string, opts ...DocsOption) {
+	cfg := docsConfig{info: openapi.Info{Title: "API", Version: "1.0.0"}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	prefix = "/" + strings.Trim(prefix, "/")
+	base := t.fullPath(prefix)
+
+	var index bytes.Buffer
+	if err := docsIndex.Execute(&index, struct{ Title, Base string }{cfg.info.Title, base}); err != nil {
+		panic("transwarp: rendering docs index: " + err.Error())
+	}
+	assets, _ := fs.Sub(docsFS, "docsui")
+	spec := t.openAPIHandler(cfg.info, base, base+"/*file")
+
+	serveIndex := func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", docsPolicy)
+		_, _ = w.Write(index.Bytes())
+	}
+
+	t.GET(prefix, serveIndex)
+	t.GET(prefix+"/*file", func(w http.ResponseWriter, r *http.Request) {
+		file := t.Param(r, "file")
+		if file == "" {
+			file = t.Param(r, "*")
+		}
+
+		switch file = strings.TrimPrefix(file, "/"); file {
+		case "", "index.html":
+			serveIndex(w, r)
+		case "openapi.json":
+			spec(w, r)
+		default:
+			if _, err := fs.Stat(assets, file); err != nil {
+				problem.NotFound(w, r)
+				return
+			}
+			http.ServeFileFS(w, r, assets, file)
+		}
+	})
+}
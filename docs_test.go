@@ -0,0 +1,68 @@
+package transwarp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/openapi"
+)
+
+// TestDocs verifica que Docs sirva el visor, sus recursos embebidos y el
+// documento OpenAPI bajo el prefijo de un grupo.
+func TestDocs(t *testing.T) {
+	tw := transwarp.New(newStubRouter())
+	api := tw.Group("/api")
+	transwarp.Docs(tw.Group("/internal").(*transwarp.Transwarp), "/docs/", transwarp.DocsInfo(openapi.Info{Title: "Pedidos", Version: "2.0.0"}))
+	api.POST("/orders", func(http.ResponseWriter, *http.Request) {}, middleware.Validate(CreateOrder{}))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		tw.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	index := get("/internal/docs")
+	if index.Code != http.StatusOK || !strings.HasPrefix(index.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("El índice debería ser HTML, obtenido %d %s", index.Code, index.Header().Get("Content-Type"))
+	}
+	body := index.Body.String()
+	for _, want := range []string{"<title>Pedidos</title>", `src="/internal/docs/viewer.js"`, `data-spec="/internal/docs/openapi.json"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("El índice no contiene %q", want)
+		}
+	}
+	if strings.Contains(body, "https://") {
+		t.Error("El visor no debe cargar recursos externos")
+	}
+	if csp := index.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "default-src 'self'") {
+		t.Errorf("CSP inesperada: %q", csp)
+	}
+
+	if rr := get("/internal/docs/"); rr.Body.String() != body {
+		t.Error("La ruta con barra final debería servir el mismo índice")
+	}
+
+	js := get("/internal/docs/viewer.js")
+	if js.Code != http.StatusOK || !strings.Contains(js.Header().Get("Content-Type"), "javascript") {
+		t.Errorf("Recurso JS inesperado: %d %s", js.Code, js.Header().Get("Content-Type"))
+	}
+
+	spec := get("/internal/docs/openapi.json")
+	if spec.Code != http.StatusOK || !strings.Contains(spec.Body.String(), `"/api/orders"`) {
+		t.Fatalf("El documento debería incluir /api/orders: %d %s", spec.Code, spec.Body.String())
+	}
+	if strings.Contains(spec.Body.String(), "/internal/docs") {
+		t.Error("Las rutas de la documentación no deberían aparecer en el documento")
+	}
+	if !strings.Contains(spec.Body.String(), `"title":"Pedidos"`) {
+		t.Error("El documento debería usar la información de DocsInfo")
+	}
+
+	if rr := get("/internal/docs/missing.css"); rr.Code != http.StatusNotFound {
+		t.Errorf("Esperado 404 para un recurso inexistente, obtenido %d", rr.Code)
+	}
+}
@@ -0,0 +1,321 @@
+// Transwarp API viewer: renders an OpenAPI 3.1 document without external
+// dependencies so it works in air-gapped environments.
+(function () {
+  "use strict";
+
+  var METHODS = ["get", "post", "put", "patch", "delete", "options", "head"];
+  var main = document.getElementById("operations");
+  var spec = null;
+
+  // el creates an element; children may be strings, nodes or arrays of them.
+  function el(tag, attrs) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (key) {
+      if (key === "text") {
+        node.textContent = attrs[key];
+      } else {
+        node.setAttribute(key, attrs[key]);
+      }
+    });
+    for (var i = 2; i < arguments.length; i++) {
+      append(node, arguments[i]);
+    }
+    return node;
+  }
+
+  function append(node, child) {
+    if (child === null || child === undefined) {
+      return;
+    }
+    if (Array.isArray(child)) {
+      child.forEach(function (c) { append(node, c); });
+      return;
+    }
+    node.appendChild(typeof child === "string" ? document.createTextNode(child) : child);
+  }
+
+  // resolve follows local "$ref" pointers such as "#/components/schemas/User".
+  function resolve(schema) {
+    var seen = 0;
+    while (schema && schema.$ref && seen < 32) {
+      var node = spec;
+      schema.$ref.replace(/^#\//, "").split("/").forEach(function (part) {
+        node = node ? node[part.replace(/~1/g, "/").replace(/~0/g, "~")] : undefined;
+      });
+      schema = node;
+      seen++;
+    }
+    return schema || {};
+  }
+
+  function refName(schema) {
+    return schema && schema.$ref ? schema.$ref.split("/").pop() : "";
+  }
+
+  // merged combines allOf members into a single object schema.
+  function merged(schema) {
+    schema = resolve(schema);
+    if (!schema.allOf) {
+      return schema;
+    }
+    var out = { type: "object", properties: {}, required: [] };
+    schema.allOf.forEach(function (part) {
+      part = merged(part);
+      Object.assign(out.properties, part.properties || {});
+      out.required = out.required.concat(part.required || []);
+    });
+    return out;
+  }
+
+  function typeLabel(schema) {
+    var name = refName(schema);
+    schema = merged(schema);
+    var type = schema.type || (schema.properties ? "object" : "any");
+    if (type === "array") {
+      type = typeLabel(schema.items || {}) + "[]";
+    }
+    if (schema.format) {
+      type += " <" + schema.format + ">";
+    }
+    return name ? name + " (" + type + ")" : type;
+  }
+
+  function constraints(schema) {
+    var parts = [];
+    var pairs = [
+      ["minLength", "min length"], ["maxLength", "max length"],
+      ["minimum", "≥"], ["maximum", "≤"],
+      ["exclusiveMinimum", ">"], ["exclusiveMaximum", "<"],
+      ["minItems", "min items"], ["maxItems", "max items"]
+    ];
+    pairs.forEach(function (pair) {
+      if (schema[pair[0]] !== undefined) {
+        parts.push(pair[1] + " " + schema[pair[0]]);
+      }
+    });
+    if (schema.enum) {
+      parts.push("one of " + schema.enum.map(function (v) { return JSON.stringify(v); }).join(", "));
+    }
+    return parts.join(" · ");
+  }
+
+  // renderSchema lists the members of a schema, expanding nested objects.
+  function renderSchema(schema, depth, seen) {
+    seen = seen || {};
+    var name = refName(schema);
+    if (name && seen[name]) {
+      return el("span", { "class": "type", text: typeLabel(schema) + " ↺" });
+    }
+    if (name) {
+      seen = Object.assign({}, seen);
+      seen[name] = true;
+    }
+
+    var resolved = merged(schema);
+    if (resolved.type === "array" && resolved.items) {
+      return el("div", {}, el("span", { "class": "type", text: typeLabel(schema) }),
+        depth < 8 ? renderSchema(resolved.items, depth + 1, seen) : null);
+    }
+    if (!resolved.properties) {
+      var c = constraints(resolved);
+      return el("span", {}, el("span", { "class": "type", text: typeLabel(schema) }),
+        c ? el("span", { "class": "constraint", text: " " + c }) : null);
+    }
+
+    var required = resolved.required || [];
+    var list = el("ul", { "class": "schema" });
+    Object.keys(resolved.properties).forEach(function (prop) {
+      var child = resolved.properties[prop];
+      var childResolved = merged(child);
+      var nested = childResolved.properties || (childResolved.items && merged(childResolved.items).properties);
+      var c = constraints(childResolved);
+      list.appendChild(el("li", {},
+        el("code", { text: prop }), " ",
+        el("span", { "class": "type", text: typeLabel(child) }),
+        required.indexOf(prop) !== -1 ? el("span", { "class": "required", text: " required" }) : null,
+        c ? el("span", { "class": "constraint", text: " " + c }) : null,
+        nested && depth < 8 ? renderSchema(child, depth + 1, seen) : null));
+    });
+    return list;
+  }
+
+  // example builds a sample value used to prefill request bodies.
+  function example(schema, depth) {
+    schema = merged(schema);
+    if (depth > 6) {
+      return null;
+    }
+    if (schema.enum) {
+      return schema.enum[0];
+    }
+    switch (schema.type) {
+      case "object":
+        var out = {};
+        Object.keys(schema.properties || {}).forEach(function (prop) {
+          out[prop] = example(schema.properties[prop], depth + 1);
+        });
+        return out;
+      case "array":
+        return schema.items ? [example(schema.items, depth + 1)] : [];
+      case "integer":
+      case "number":
+        return schema.minimum !== undefined ? schema.minimum : 0;
+      case "boolean":
+        return false;
+      case "string":
+        return schema.format === "email" ? "user@example.com" : "string";
+      default:
+        return schema.properties ? example(Object.assign({ type: "object" }, schema), depth) : null;
+    }
+  }
+
+  function renderParameters(params) {
+    var rows = params.map(function (p) {
+      return el("tr", {},
+        el("td", {}, el("code", { text: p.name })),
+        el("td", { text: p.in }),
+        el("td", {}, renderSchema(p.schema || {}, 0)));
+    });
+    return [el("h4", { text: "Parameters" }),
+      el("table", {}, el("tr", {}, el("th", { text: "Name" }), el("th", { text: "In" }), el("th", { text: "Schema" })), rows)];
+  }
+
+  function renderContent(content) {
+    return Object.keys(content || {}).map(function (type) {
+      return el("div", {}, el("p", {}, el("code", { text: type })), renderSchema(content[type].schema || {}, 0));
+    });
+  }
+
+  function renderResponses(responses) {
+    return [el("h4", { text: "Responses" })].concat(Object.keys(responses).sort().map(function (status) {
+      var res = responses[status];
+      return el("div", {},
+        el("p", {}, el("span", { "class": "status", text: status }), " ", res.description || ""),
+        renderContent(res.content));
+    }));
+  }
+
+  // renderTry builds a form that sends the operation to the API.
+  function renderTry(method, path, op) {
+    var params = (op.parameters || []).filter(function (p) { return p.in === "path"; });
+    var inputs = {};
+    var form = el("div", { "class": "try" }, el("h4", { text: "Try it" }));
+
+    params.forEach(function (p) {
+      inputs[p.name] = el("input", { placeholder: p.name, "aria-label": p.name });
+      form.appendChild(el("label", {}, el("code", { text: p.name }), inputs[p.name]));
+    });
+
+    var body = null;
+    var contentType = "";
+    if (op.requestBody) {
+      contentType = Object.keys(op.requestBody.content)[0];
+      body = el("textarea", { "aria-label": "Request body" });
+      body.value = JSON.stringify(example(op.requestBody.content[contentType].schema || {}, 0), null, 2);
+      form.appendChild(body);
+    }
+
+    var output = el("pre", { hidden: "hidden" });
+    var button = el("button", { type: "button", text: "Send " + method.toUpperCase() });
+    button.addEventListener("click", function () {
+      var url = path.replace(/\{([^}]+)\}/g, function (_, name) {
+        return encodeURIComponent(inputs[name] ? inputs[name].value : "");
+      });
+      var init = { method: method.toUpperCase(), headers: {} };
+      if (body) {
+        init.headers["Content-Type"] = contentType;
+        init.body = body.value;
+      }
+      output.hidden = false;
+      output.textContent = "…";
+      fetch(url, init).then(function (res) {
+        return res.text().then(function (text) {
+          try {
+            text = JSON.stringify(JSON.parse(text), null, 2);
+          } catch (e) {
+            // Not JSON: show it as is.
+          }
+          output.textContent = res.status + " " + res.statusText + "\n\n" + text;
+        });
+      }).catch(function (err) {
+        output.textContent = String(err);
+      });
+    });
+    form.appendChild(button);
+    form.appendChild(output);
+    return form;
+  }
+
+  function renderOperation(method, path, op) {
+    var summary = el("summary", {},
+      el("span", { "class": "method " + (METHODS.indexOf(method) < 5 ? method : "other"), text: method.toUpperCase() }),
+      el("span", { "class": "path", text: path }),
+      el("span", { "class": "muted", text: op.summary || op.operationId || "" }));
+
+    var body = el("div", { "class": "operation-body" });
+    if (op.description) {
+      body.appendChild(el("p", { text: op.description }));
+    }
+    if (op.parameters && op.parameters.length) {
+      append(body, renderParameters(op.parameters));
+    }
+    if (op.requestBody) {
+      append(body, [el("h4", { text: "Request body" }), renderContent(op.requestBody.content)]);
+    }
+    append(body, renderResponses(op.responses || {}));
+    body.appendChild(renderTry(method, path, op));
+
+    var details = el("details", { "class": "operation" }, summary, body);
+    details.dataset.search = (method + " " + path + " " + (op.operationId || "")).toLowerCase();
+    return details;
+  }
+
+  function render() {
+    var info = spec.info || {};
+    document.title = info.title || document.title;
+    document.getElementById("title").textContent = info.title || "";
+    document.getElementById("meta").textContent =
+      ["Version " + (info.version || "?"), "OpenAPI " + spec.openapi, info.description || ""].filter(Boolean).join(" · ");
+
+    main.textContent = "";
+    var groups = {};
+    Object.keys(spec.paths || {}).sort().forEach(function (path) {
+      var group = path.split("/")[1] || "/";
+      groups[group] = groups[group] || [];
+      METHODS.forEach(function (method) {
+        if (spec.paths[path][method]) {
+          groups[group].push(renderOperation(method, path, spec.paths[path][method]));
+        }
+      });
+    });
+
+    Object.keys(groups).forEach(function (group) {
+      if (groups[group].length) {
+        main.appendChild(el("section", {}, el("h2", { text: group }), groups[group]));
+      }
+    });
+    if (!main.children.length) {
+      main.appendChild(el("p", { "class": "muted", text: "No operations documented." }));
+    }
+  }
+
+  document.getElementById("filter").addEventListener("input", function (event) {
+    var query = event.target.value.toLowerCase();
+    Array.prototype.forEach.call(main.querySelectorAll("details.operation"), function (op) {
+      op.hidden = query !== "" && op.dataset.search.indexOf(query) === -1;
+    });
+  });
+
+  fetch(main.dataset.spec).then(function (res) {
+    if (!res.ok) {
+      throw new Error(res.status + " " + res.statusText);
+    }
+    return res.json();
+  }).then(function (doc) {
+    spec = doc;
+    render();
+  }).catch(function (err) {
+    main.textContent = "";
+    main.appendChild(el("p", { "class": "error", text: "Could not load the specification: " + err.message }));
+  });
+})();
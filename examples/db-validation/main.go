@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/iaconlabs/transwarp/adapter/muxadapter"
@@ -22,29 +23,37 @@ type MockDatabase struct {
 }
 
 // IsEmailTaken checks if the email already exists in our mock store.
-func (db *MockDatabase) IsEmailTaken(email string) bool {
+// Like a real driver call (e.g. QueryRowContext), it gives up when ctx is done.
+func (db *MockDatabase) IsEmailTaken(ctx context.Context, email string) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-time.After(5 * time.Millisecond): // Simulated query latency.
+	}
 	for _, e := range db.ExistingEmails {
 		if strings.EqualFold(e, email) {
-			return true
+			return true, nil
 		}
 	}
-	return false
+	return false, nil
 }
 
 // UserRegistrationDTO defines the input for a new user.
 type UserRegistrationDTO struct {
-	// The "unique_email" tag is our custom database-backed rule.
-	Email    string `json:"email" validate:"required,email,unique_email"`
-	Username string `json:"username" validate:"required,min=3"`
+	// The "unique_email" tag is our custom database-backed rule. The "mod" tags
+	// normalize the input first, so " Admin@Transwarp.io " is also caught.
+	Email    string `json:"email" mod:"trim,lower" validate:"required,email,unique_email"`
+	Username string `json:"username" mod:"trim" validate:"required,min=3"`
 }
 
-// EmailUniqueValidator creates a validator function with access to the DB.
-// This is the "Dependency Injection" pattern for validators.
-func EmailUniqueValidator(db *MockDatabase) validator.Func {
-	return func(fl validator.FieldLevel) bool {
-		email := fl.Field().String()
-		// If the email is taken, validation fails (returns false).
-		return !db.IsEmailTaken(email)
+// EmailUniqueValidator creates a context-aware validator function with access to the DB.
+// This is the "Dependency Injection" pattern for validators. The request context is
+// forwarded to the query, so a client disconnect or the validation timeout stops it.
+func EmailUniqueValidator(db *MockDatabase) validator.FuncCtx {
+	return func(ctx context.Context, fl validator.FieldLevel) bool {
+		taken, err := db.IsEmailTaken(ctx, fl.Field().String())
+		// If the email is taken (or the lookup failed), validation fails (returns false).
+		return err == nil && !taken
 	}
 }
 
@@ -54,15 +63,18 @@ func main() {
 		ExistingEmails: []string{"admin@transwarp.io", "user@test.com"},
 	}
 
-	// 2. Register the custom validator with the injected DB.
-	v := middleware.GetValidator()
-	v.RegisterValidation("unique_email", EmailUniqueValidator(db))
+	// 2. Create an isolated validator carrying the DB-backed rule.
+	// The rule is not registered globally, so it cannot leak into other route groups.
+	v := middleware.NewValidator(
+		middleware.WithRuleCtx("unique_email", EmailUniqueValidator(db)),
+		middleware.WithValidationTimeout(2*time.Second),
+	)
 
 	// 3. Setup Transwarp with Mux Adapter
 	adp := muxadapter.NewMuxAdapter(nil)
 
 	// 4. Inject the validation middleware into the route
-	adp.POST("/register", handleRegister, middleware.Validate(UserRegistrationDTO{}))
+	adp.POST("/register", handleRegister, v.Validate(UserRegistrationDTO{}))
 
 	// 5. Start the server
 	srv := server.New(server.Config{Addr: ":8080"}, adp)
@@ -0,0 +1,124 @@
+// Package payload holds the request payload helpers shared by the middleware
+// and openapi packages: reading the request body, comparing decoded JSON
+// values and redacting sensitive values in validation errors.
+package payload
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// Body returns the body of r. It comes from the request state when the
+// adapter has cached it; otherwise it is read and restored, so handlers can
+// still read it.
+func Body(r *http.Request) ([]byte, error) {
+	if state, ok := r.Context().Value(router.StateKey).(*adapter.TranswarpState); ok && state != nil && state.Body != nil {
+		return state.Body, nil
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, err
+}
+
+// Equal compares decoded JSON values; numbers are equal when numerically equal.
+func Equal(a, b any) bool {
+	switch x := a.(type) {
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			if w, ok := y[k]; !ok || !Equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !Equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	default:
+		return a == b
+	}
+}
+
+// Redacted replaces the rejected value of sensitive fields.
+const Redacted = "[REDACTED]"
+
+// defaultSensitive lists field names whose rejected values are never echoed back.
+var defaultSensitive = []string{
+	"password", "passwd", "secret", "token", "api_key", "authorization",
+	"credit_card", "card_number", "cvv", "ssn", "pin",
+}
+
+// Sensitive is a set of normalized field names whose values are redacted.
+type Sensitive map[string]struct{}
+
+// DefaultSensitive returns a set holding the common credential names.
+func DefaultSensitive() Sensitive {
+	s := make(Sensitive, len(defaultSensitive))
+	s.Add(defaultSensitive...)
+	return s
+}
+
+// Add adds names to the set, ignoring case, underscores and hyphens.
+func (s Sensitive) Add(names ...string) {
+	for _, name := range names {
+		if name = normalize(name); name != "" {
+			s[name] = struct{}{}
+		}
+	}
+}
+
+// Match reports whether the field name is sensitive: it contains a name of the
+// set, ignoring case, underscores and hyphens, so "new_password" and "apiKey"
+// match "password" and "api_key". Names of up to three characters, such as
+// "pin", must match the whole field name, so "shipping" is not redacted.
+func (s Sensitive) Match(name string) bool {
+	name = normalize(name)
+	for sensitive := range s {
+		if name == sensitive || len(sensitive) > 3 && strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize lowercases name and drops underscores and hyphens.
+func normalize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
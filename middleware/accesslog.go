@@ -0,0 +1,276 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iaconlabs/transwarp/adapter"
+)
+
+// AccessLogOption configures AccessLog.
+type AccessLogOption func(*accessLogConfig)
+
+// accessLogConfig holds the settings of an AccessLog middleware.
+type accessLogConfig struct {
+	skip       []string
+	sampleRate float64
+	combined   io.Writer
+	proxyHops  int
+	mu         sync.Mutex // serializes writes to combined
+}
+
+// WithAccessLogSkip disables logging for the given paths, e.g. health checks.
+// A path ending in "*" matches every path with that prefix ("/debug/*").
+func WithAccessLogSkip(paths ...string) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.skip = append(c.skip, paths...)
+	}
+}
+
+// WithAccessLogSampling logs only a fraction (0 to 1) of the requests that
+// succeed. Requests answered with a 4xx or 5xx status are always logged.
+func WithAccessLogSampling(rate float64) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.sampleRate = min(max(rate, 0), 1)
+	}
+}
+
+// WithAccessLogCombined writes one Apache Combined Log Format line per request
+// to w instead of emitting slog records.
+func WithAccessLogCombined(w io.Writer) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.combined = w
+	}
+}
+
+// WithAccessLogTrustProxy takes the client IP from X-Forwarded-For (or
+// X-Real-IP) instead of the connection address, for an application behind hops
+// reverse proxies that append to X-Forwarded-For. Entries the client could
+// have forged are ignored.
+func WithAccessLogTrustProxy(hops int) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.proxyHops = max(hops, 0)
+	}
+}
+
+// AccessLog returns a middleware that logs one record per request with the
+// method, path, route pattern, status, response size, latency, client IP and
+// request ID (see RequestID). Records use the Info level, Warn for 4xx and
+// Error for 5xx responses. A nil logger uses slog.Default.
+//
+// The route pattern is only known to middlewares that run after routing, so
+// the "route" attribute is present when AccessLog is installed with Use (on
+// the application or a group). Installed with Transwarp.Pre, it also logs
+// requests that match no route (404, 405) but without their route.
+func AccessLog(logger *slog.Logger, opts ...AccessLogOption) func(http.Handler) http.Handler {
+	cfg := &accessLogConfig{sampleRate: 1}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.skipped(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			latency := time.Since(start)
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status < 400 && cfg.sampleRate < 1 && rand.Float64() >= cfg.sampleRate {
+				return
+			}
+
+			if cfg.combined != nil {
+				cfg.writeCombined(r, start, status, sw.bytes)
+				return
+			}
+
+			requestID := GetRequestID(r.Context())
+			if requestID == "" {
+				// RequestID may run inside AccessLog: it echoes the ID in the response.
+				requestID = w.Header().Get(RequestIDHeader)
+			}
+
+			l := logger
+			if l == nil {
+				l = slog.Default()
+			}
+			level := slog.LevelInfo
+			switch {
+			case status >= 500:
+				level = slog.LevelError
+			case status >= 400:
+				level = slog.LevelWarn
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+			}
+			if route := adapter.RoutePattern(r); route != "" {
+				attrs = append(attrs, slog.String("route", route))
+			}
+			attrs = append(attrs,
+				slog.Int("status", status),
+				slog.Int64("bytes", sw.bytes),
+				slog.Duration("latency", latency),
+				slog.String("client_ip", clientIP(r, cfg.proxyHops)),
+			)
+			if requestID != "" {
+				attrs = append(attrs, slog.String("request_id", requestID))
+			}
+			l.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
+
+func (c *accessLogConfig) skipped(path string) bool {
+	return slices.ContainsFunc(c.skip, func(p string) bool {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			return strings.HasPrefix(path, prefix)
+		}
+		return p == path
+	})
+}
+
+// clientIP returns the address of the client without the port. Behind hops
+// trusted proxies it is the hops-th X-Forwarded-For entry from the right, the
+// one appended by the outermost proxy: proxies append to the header, so the
+// entries before it come from the client and can be forged. X-Real-IP is used
+// when X-Forwarded-For is absent.
+func clientIP(r *http.Request, hops int) string {
+	if hops > 0 {
+		var entries []string
+		for _, fwd := range r.Header.Values("X-Forwarded-For") {
+			for entry := range strings.SplitSeq(fwd, ",") {
+				if entry = strings.TrimSpace(entry); entry != "" {
+					entries = append(entries, entry)
+				}
+			}
+		}
+		if len(entries) > 0 {
+			return entries[max(len(entries)-hops, 0)]
+		}
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return strings.TrimSpace(ip)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// writeCombined writes an Apache Combined Log Format line:
+//
+//	host - user [time] "request line" status bytes "referer" "user-agent"
+func (c *accessLogConfig) writeCombined(r *http.Request, start time.Time, status int, n int64) {
+	user := "-"
+	if name, _, ok := r.BasicAuth(); ok && name != "" {
+		user = name
+	}
+	size := "-"
+	if n > 0 {
+		size = strconv.FormatInt(n, 10)
+	}
+	line := fmt.Sprintf("%s - %s [%s] %s %d %s %s %s\n",
+		clientIP(r, c.proxyHops), quoteLog(user, false),
+		start.Format("02/Jan/2006:15:04:05 -0700"),
+		quoteLog(r.Method+" "+r.URL.RequestURI()+" "+r.Proto, true),
+		status, size,
+		quoteLog(r.Referer(), true), quoteLog(r.UserAgent(), true))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, _ = io.WriteString(c.combined, line)
+}
+
+// quoteLog escapes a log field so client input cannot forge lines, optionally
+// wrapping it in double quotes. Empty values are written as "-".
+func quoteLog(s string, quoted bool) string {
+	if s == "" {
+		s = "-"
+	}
+	var b strings.Builder
+	for i := range len(s) {
+		switch ch := s[i]; {
+		case ch == '"' || ch == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case ch < 0x20 || ch == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", ch)
+		case !quoted && ch == ' ':
+			b.WriteString("\\x20")
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	if quoted {
+		return `"` + b.String() + `"`
+	}
+	return b.String()
+}
+
+// statusWriter records the status code and the number of body bytes written,
+// forwarding everything to the wrapped writer.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusWriter) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusWriter) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher so streaming handlers keep working.
+func (s *statusWriter) Flush() {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	_ = http.NewResponseController(s.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker for WebSocket upgrades.
+func (s *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if s.status == 0 {
+		s.status = http.StatusSwitchingProtocols
+	}
+	return http.NewResponseController(s.ResponseWriter).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
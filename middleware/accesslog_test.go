@@ -0,0 +1,99 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/middleware"
+)
+
+// serveAccessLog sends a request through mw to a handler answering status.
+func serveAccessLog(mw func(http.Handler) http.Handler, req *http.Request, status int) {
+	mw(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("hello"))
+	})).ServeHTTP(httptest.NewRecorder(), req)
+}
+
+// TestAccessLog verifies the attributes and level of the structured records.
+func TestAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	mw := middleware.RequestID()
+	access := middleware.AccessLog(logger)
+
+	req := httptest.NewRequest(http.MethodPost, "/users?x=1", nil)
+	req.RemoteAddr = "10.0.0.7:5123"
+	req.Header.Set("X-Request-ID", "req-9")
+	serveAccessLog(func(h http.Handler) http.Handler { return mw(access(h)) }, req, http.StatusCreated)
+
+	var rec map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &rec); err != nil {
+		t.Fatalf("Invalid log record %q: %v", logs.String(), err)
+	}
+	want := map[string]any{
+		"level": "INFO", "msg": "request", "method": "POST", "path": "/users",
+		"status": float64(201), "bytes": float64(5), "client_ip": "10.0.0.7", "request_id": "req-9",
+	}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, rec[k])
+		}
+	}
+	if _, ok := rec["latency"]; !ok {
+		t.Error("Expected a latency attribute")
+	}
+
+	logs.Reset()
+	serveAccessLog(access, httptest.NewRequest(http.MethodGet, "/boom", nil), http.StatusBadGateway)
+	if !strings.Contains(logs.String(), `"level":"ERROR"`) {
+		t.Errorf("Expected 5xx responses at the error level: %s", logs.String())
+	}
+}
+
+// TestAccessLog_SkipAndSampling verifies skipped paths and that sampling never
+// drops failed requests.
+func TestAccessLog_SkipAndSampling(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	mw := middleware.AccessLog(logger,
+		middleware.WithAccessLogSkip("/healthz", "/debug/*"),
+		middleware.WithAccessLogSampling(0))
+
+	serveAccessLog(mw, httptest.NewRequest(http.MethodGet, "/healthz", nil), http.StatusInternalServerError)
+	serveAccessLog(mw, httptest.NewRequest(http.MethodGet, "/debug/pprof", nil), http.StatusInternalServerError)
+	serveAccessLog(mw, httptest.NewRequest(http.MethodGet, "/users", nil), http.StatusOK)
+	if logs.Len() != 0 {
+		t.Fatalf("Expected no records, got %s", logs.String())
+	}
+
+	serveAccessLog(mw, httptest.NewRequest(http.MethodGet, "/users", nil), http.StatusNotFound)
+	if !strings.Contains(logs.String(), `"status":404`) {
+		t.Errorf("Expected failed requests to bypass sampling: %s", logs.String())
+	}
+}
+
+// TestAccessLog_Combined verifies the Apache Combined Log Format output.
+func TestAccessLog_Combined(t *testing.T) {
+	var out bytes.Buffer
+	mw := middleware.AccessLog(nil, middleware.WithAccessLogCombined(&out), middleware.WithAccessLogTrustProxy(2))
+
+	req := httptest.NewRequest(http.MethodGet, "/files/a.txt?v=2", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.66, 203.0.113.9, 10.0.0.1")
+	req.Header.Set("Referer", "https://example.com/")
+	req.Header.Set("User-Agent", `curl/8.0 "evil"`)
+	req.SetBasicAuth("frank", "secret")
+	serveAccessLog(mw, req, http.StatusOK)
+
+	pattern := `^203\.0\.113\.9 - frank \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] ` +
+		`"GET /files/a\.txt\?v=2 HTTP/1\.1" 200 5 "https://example\.com/" "curl/8\.0 \\"evil\\""\n$`
+	if !regexp.MustCompile(pattern).MatchString(out.String()) {
+		t.Errorf("Unexpected combined line: %q", out.String())
+	}
+}
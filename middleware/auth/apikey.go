@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"maps"
+	"net/http"
+
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// APIKeyHeader is the default header carrying API keys.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyLookup resolves an API key to the claims of its owner. It returns nil
+// claims for unknown keys and an error only when the lookup itself failed,
+// e.g. because the database is unreachable.
+type APIKeyLookup func(ctx context.Context, key string) (Claims, error)
+
+// APIKeyOption configures APIKey.
+type APIKeyOption func(*apiKeyConfig)
+
+// apiKeyConfig holds the settings of an APIKey middleware.
+type apiKeyConfig struct {
+	header string
+	query  string
+}
+
+// WithAPIKeyHeader sets the header carrying the key (X-API-Key by default).
+func WithAPIKeyHeader(name string) APIKeyOption {
+	return func(c *apiKeyConfig) {
+		if name != "" {
+			c.header = http.CanonicalHeaderKey(name)
+		}
+	}
+}
+
+// WithAPIKeyQuery also accepts the key from the named query parameter when the
+// header is absent. Query strings end up in access logs and browser history,
+// so prefer the header.
+func WithAPIKeyQuery(name string) APIKeyOption {
+	return func(c *apiKeyConfig) {
+		c.query = name
+	}
+}
+
+// APIKey returns a middleware that requires an API key resolved by lookup (see
+// StaticAPIKeys). Requests without a key or with an unknown one get 401
+// Unauthorized; lookup errors are logged and answered with 503 Service
+// Unavailable. The claims of the key owner are stored in the request context.
+func APIKey(lookup APIKeyLookup, opts ...APIKeyOption) func(http.Handler) http.Handler {
+	cfg := &apiKeyConfig{header: APIKeyHeader}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(cfg.header)
+			if key == "" && cfg.query != "" {
+				key = r.URL.Query().Get(cfg.query)
+			}
+			if key == "" {
+				unauthorized(w, r, "APIKey", "An API key is required")
+				return
+			}
+
+			claims, err := lookup(r.Context(), key)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "transwarp: API key lookup failed", "error", err)
+				problem.Write(w, r, problem.New(http.StatusServiceUnavailable))
+				return
+			}
+			if claims == nil {
+				unauthorized(w, r, "APIKey", "Invalid API key")
+				return
+			}
+			authenticated(next, w, r, claims)
+		})
+		scheme := router.SecurityScheme{Name: "apiKeyAuth", Type: "apiKey", In: "header", Param: cfg.header}
+		return &describedHandler{
+			Handler:  handler,
+			describe: describeAuthentication(scheme, http.StatusUnauthorized, http.StatusServiceUnavailable),
+		}
+	}
+}
+
+// StaticAPIKeys returns an APIKeyLookup for a fixed set of keys and the claims
+// of their owners. Keys are looked up by their SHA-256 hash, so lookups do not
+// leak how much of a key matched.
+func StaticAPIKeys(keys map[string]Claims) APIKeyLookup {
+	hashed := make(map[[sha256.Size]byte]Claims, len(keys))
+	for key, claims := range keys {
+		if claims == nil {
+			claims = Claims{}
+		}
+		hashed[sha256.Sum256([]byte(key))] = claims
+	}
+	return func(_ context.Context, key string) (Claims, error) {
+		claims, ok := hashed[sha256.Sum256([]byte(key))]
+		if !ok {
+			return nil, nil
+		}
+		return maps.Clone(claims), nil
+	}
+}
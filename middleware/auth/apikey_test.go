@@ -0,0 +1,58 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/middleware/auth"
+)
+
+func serveAPIKey(h http.Handler, target string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+// TestAPIKey verifies header and query keys.
+func TestAPIKey(t *testing.T) {
+	keys := auth.StaticAPIKeys(map[string]auth.Claims{
+		"key-1": {"sub": "billing-service"},
+	})
+
+	h := auth.APIKey(keys)(subjectHandler)
+	if rr := serveAPIKey(h, "/", "X-API-Key", "key-1"); rr.Code != http.StatusOK || rr.Body.String() != "billing-service" {
+		t.Errorf("Expected the key to be accepted, got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr := serveAPIKey(h, "/", "X-API-Key", "key-2"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unknown key, got %d", rr.Code)
+	}
+	if rr := serveAPIKey(h, "/?api_key=key-1"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Query keys must be disabled by default, got %d", rr.Code)
+	}
+
+	h = auth.APIKey(keys, auth.WithAPIKeyHeader("Authorization-Key"), auth.WithAPIKeyQuery("api_key"))(subjectHandler)
+	if rr := serveAPIKey(h, "/", "Authorization-Key", "key-1"); rr.Code != http.StatusOK {
+		t.Errorf("Expected the custom header to be read, got %d", rr.Code)
+	}
+	if rr := serveAPIKey(h, "/?api_key=key-1"); rr.Code != http.StatusOK {
+		t.Errorf("Expected the query parameter to be read, got %d", rr.Code)
+	}
+}
+
+// TestAPIKey_LookupError verifies that failing lookups are not treated as
+// invalid keys.
+func TestAPIKey_LookupError(t *testing.T) {
+	h := auth.APIKey(func(context.Context, string) (auth.Claims, error) {
+		return nil, errors.New("database unavailable")
+	})(subjectHandler)
+	if rr := serveAPIKey(h, "/", "X-API-Key", "key-1"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", rr.Code)
+	}
+}
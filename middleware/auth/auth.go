@@ -0,0 +1,215 @@
+// Package auth provides authentication middlewares for Transwarp: HTTP Basic,
+// API keys and JWT bearer tokens. Each middleware verifies the caller and
+// stores its Claims in the request context under router.ClaimsKey, where
+// handlers read them with GetClaims or Claim, and middleware.Validate binds
+// them to DTO fields tagged "claim".
+//
+// Authorization middlewares then decide what an authenticated caller may do:
+// Require and RequireAny check permissions granted by a role-based Policy or
+// held as scopes, Allow and RequireParam enforce resource-level rules, and
+// DenyByDefault rejects routes that declare no policy at all. Every middleware
+// documents itself for route introspection and the openapi package.
+package auth
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// Claims are the verified attributes of an authenticated caller, such as the
+// payload of a JWT. Values follow encoding/json conventions: numbers are
+// float64, arrays []any and objects map[string]any.
+type Claims map[string]any
+
+// Claim returns the value of the named claim.
+func (c Claims) Claim(name string) (any, bool) {
+	v, ok := c[name]
+	return v, ok
+}
+
+// String returns the named claim if it is a string, or "".
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Subject returns the "sub" claim.
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// Issuer returns the "iss" claim.
+func (c Claims) Issuer() string {
+	return c.String("iss")
+}
+
+// Audience returns the "aud" claim, which may be a string or an array.
+func (c Claims) Audience() []string {
+	return stringList(c["aud"])
+}
+
+// ExpiresAt returns the "exp" claim, or the zero time when it is absent.
+func (c Claims) ExpiresAt() time.Time {
+	t, _ := numericDate(c["exp"])
+	return t
+}
+
+// Scopes returns the OAuth 2.0 scopes granted to the caller, read from the
+// space-separated "scope" claim (RFC 8693) or the "scp" claim.
+func (c Claims) Scopes() []string {
+	if s, ok := c["scope"].(string); ok {
+		return strings.Fields(s)
+	}
+	if s, ok := c["scp"].(string); ok {
+		return strings.Fields(s)
+	}
+	return stringList(c["scp"])
+}
+
+// Roles returns the "roles" claim, which may be a string or an array.
+func (c Claims) Roles() []string {
+	return stringList(c["roles"])
+}
+
+// WithClaims returns a copy of ctx carrying claims. The middlewares of this
+// package call it; custom authentication schemes can use it too.
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, router.ClaimsKey, claims)
+}
+
+// GetClaims returns the claims of the authenticated caller stored in ctx.
+func GetClaims(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(router.ClaimsKey).(Claims)
+	return claims, ok
+}
+
+// Claim returns the named claim of the authenticated caller converted to T.
+// Numeric claims convert to any integer or float type when the value fits, and
+// arrays of strings to []string. It reports false when the request is not
+// authenticated, the claim is absent or it cannot be converted.
+//
+//	tenant, ok := auth.Claim[string](r, "tenant_id")
+func Claim[T any](r *http.Request, name string) (T, bool) {
+	var zero T
+	claims, ok := GetClaims(r.Context())
+	if !ok {
+		return zero, false
+	}
+	v, ok := claims[name]
+	if !ok {
+		return zero, false
+	}
+	if t, ok := v.(T); ok {
+		return t, true
+	}
+
+	target := reflect.ValueOf(&zero).Elem()
+	switch src := v.(type) {
+	case float64:
+		if !isNumber(target.Kind()) {
+			return zero, false
+		}
+		converted := reflect.ValueOf(src).Convert(target.Type())
+		if converted.Convert(reflect.TypeFor[float64]()).Float() != src {
+			return zero, false // overflows or truncates
+		}
+		target.Set(converted)
+		return zero, true
+	case []any:
+		if target.Type() != reflect.TypeFor[[]string]() {
+			return zero, false
+		}
+		list := make([]string, 0, len(src))
+		for _, item := range src {
+			s, ok := item.(string)
+			if !ok {
+				return zero, false
+			}
+			list = append(list, s)
+		}
+		target.Set(reflect.ValueOf(list))
+		return zero, true
+	}
+	return zero, false
+}
+
+// isNumber reports whether k is an integer or float kind.
+func isNumber(k reflect.Kind) bool {
+	return (k >= reflect.Int && k <= reflect.Uint64) || k == reflect.Float32 || k == reflect.Float64
+}
+
+// stringList converts a claim holding a string or an array of strings.
+func stringList(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// numericDate converts a claim holding seconds since the Unix epoch.
+func numericDate(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case float64:
+		if math.IsNaN(v) || math.Abs(v) >= 1<<62 {
+			return time.Time{}, false
+		}
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	case int64:
+		return time.Unix(v, 0), true
+	case int:
+		return time.Unix(int64(v), 0), true
+	}
+	return time.Time{}, false
+}
+
+// unauthorized rejects a request with 401 Unauthorized and the given
+// WWW-Authenticate challenge.
+func unauthorized(w http.ResponseWriter, r *http.Request, challenge, detail string) {
+	w.Header().Set("WWW-Authenticate", challenge)
+	problem.Write(w, r, problem.New(http.StatusUnauthorized).WithDetail(detail))
+}
+
+// authenticated continues the chain with claims stored in the request context.
+func authenticated(next http.Handler, w http.ResponseWriter, r *http.Request, claims Claims) {
+	next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+}
+
+// describedHandler wraps the handler built by a middleware of this package
+// with the documentation it contributes to the route (see router.Route.Describe).
+type describedHandler struct {
+	http.Handler
+	describe func(d *router.RouteDescription)
+}
+
+// DescribeRoute implements router.RouteDescriber.
+func (h *describedHandler) DescribeRoute(d *router.RouteDescription) {
+	h.describe(d)
+}
+
+// describeAuthentication documents an authentication middleware: its scheme
+// and error statuses.
+func describeAuthentication(scheme router.SecurityScheme, statuses ...int) func(d *router.RouteDescription) {
+	return func(d *router.RouteDescription) {
+		d.AddSecurity(scheme)
+		d.AddError(statuses...)
+	}
+}
@@ -0,0 +1,132 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/middleware/auth"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// decodeClaims builds claims the way they arrive in a JWT payload.
+func decodeClaims(t *testing.T, payload string) auth.Claims {
+	t.Helper()
+	var claims auth.Claims
+	if err := json.Unmarshal([]byte(payload), &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+// TestClaims_Accessors verifies the typed accessors of the registered claims.
+func TestClaims_Accessors(t *testing.T) {
+	claims := decodeClaims(t, `{
+		"sub": "user-1", "iss": "https://id.example.com", "aud": "orders",
+		"exp": 1700000000, "scope": "orders:read orders:write", "roles": ["admin", "billing"]
+	}`)
+
+	if claims.Subject() != "user-1" || claims.Issuer() != "https://id.example.com" {
+		t.Errorf("Unexpected subject or issuer: %q %q", claims.Subject(), claims.Issuer())
+	}
+	if !slices.Equal(claims.Audience(), []string{"orders"}) {
+		t.Errorf("Unexpected audience %v", claims.Audience())
+	}
+	if !claims.ExpiresAt().Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Unexpected expiry %v", claims.ExpiresAt())
+	}
+	if exp := decodeClaims(t, `{"exp": 9999999999}`).ExpiresAt(); exp.Year() != 2286 {
+		t.Errorf("Expected a far-future expiry, got %v", exp)
+	}
+	if !slices.Equal(claims.Scopes(), []string{"orders:read", "orders:write"}) {
+		t.Errorf("Unexpected scopes %v", claims.Scopes())
+	}
+	if !slices.Equal(claims.Roles(), []string{"admin", "billing"}) {
+		t.Errorf("Unexpected roles %v", claims.Roles())
+	}
+	if scp := decodeClaims(t, `{"scp": ["a", "b"]}`).Scopes(); !slices.Equal(scp, []string{"a", "b"}) {
+		t.Errorf("Expected scopes from the scp claim, got %v", scp)
+	}
+}
+
+// TestClaim verifies the generic accessor and its conversions.
+func TestClaim(t *testing.T) {
+	claims := decodeClaims(t, `{"sub": "user-1", "tenant": 42, "ratio": 0.5, "groups": ["a", "b"]}`)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, ok := auth.Claim[string](req, "sub"); ok {
+		t.Error("Expected no claims on an unauthenticated request")
+	}
+	req = req.WithContext(auth.WithClaims(req.Context(), claims))
+
+	if sub, ok := auth.Claim[string](req, "sub"); !ok || sub != "user-1" {
+		t.Errorf("Unexpected sub %q", sub)
+	}
+	if tenant, ok := auth.Claim[int64](req, "tenant"); !ok || tenant != 42 {
+		t.Errorf("Unexpected tenant %d", tenant)
+	}
+	if _, ok := auth.Claim[int](req, "ratio"); ok {
+		t.Error("A fractional claim must not convert to an integer")
+	}
+	if _, ok := auth.Claim[uint8](req, "tenant"); !ok {
+		t.Error("Expected 42 to fit in an uint8")
+	}
+	if groups, ok := auth.Claim[[]string](req, "groups"); !ok || !slices.Equal(groups, []string{"a", "b"}) {
+		t.Errorf("Unexpected groups %v", groups)
+	}
+	if _, ok := auth.Claim[string](req, "tenant"); ok {
+		t.Error("A number must not convert to a string")
+	}
+	if _, ok := auth.Claim[string](req, "missing"); ok {
+		t.Error("Expected missing claims to be reported")
+	}
+}
+
+type createOrderRequest struct {
+	Item     string   `json:"item" validate:"required"`
+	UserID   string   `json:"user_id" claim:"sub"`
+	TenantID int      `claim:"tenant" validate:"required"`
+	Roles    []string `claim:"roles"`
+}
+
+// TestValidate_BindsClaims verifies that Validate binds "claim" fields from the
+// verified claims, never from the body.
+func TestValidate_BindsClaims(t *testing.T) {
+	var got *createOrderRequest
+	h := middleware.Validate(createOrderRequest{})(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = r.Context().Value(router.ValidationKey).(*createOrderRequest)
+	}))
+	send := func(claims auth.Claims) *httptest.ResponseRecorder {
+		body := `{"item": "book", "user_id": "someone-else"}`
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		state := &adapter.TranswarpState{Params: map[string]string{}, Body: []byte(body)}
+		ctx := adapter.WithState(req.Context(), state)
+		if claims != nil {
+			ctx = auth.WithClaims(ctx, claims)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	rr := send(decodeClaims(t, `{"sub": "user-1", "tenant": 7, "roles": ["admin"]}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got.Item != "book" || got.UserID != "user-1" || got.TenantID != 7 || !slices.Equal(got.Roles, []string{"admin"}) {
+		t.Errorf("Unexpected binding %+v", got)
+	}
+
+	rr = send(nil)
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), `"field":"tenant"`) {
+		t.Errorf("Expected 422 for the missing tenant claim, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got.UserID != "user-1" {
+		t.Error("The handler must not run when validation fails")
+	}
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// Basic returns a middleware that requires HTTP Basic credentials (RFC 7617)
+// accepted by verify, e.g. StaticUsers or Htpasswd.Verify. Other requests get
+// 401 Unauthorized with a challenge for realm. The claims of authenticated
+// requests hold the user name as "sub".
+func Basic(realm string, verify func(user, password string) bool) func(http.Handler) http.Handler {
+	challenge := `Basic realm=` + strconv.Quote(realm) + `, charset="UTF-8"`
+	return func(next http.Handler) http.Handler {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, password, ok := r.BasicAuth()
+			if !ok {
+				unauthorized(w, r, challenge, "Authentication is required")
+				return
+			}
+			if !verify(user, password) {
+				unauthorized(w, r, challenge, "Invalid user name or password")
+				return
+			}
+			authenticated(next, w, r, Claims{"sub": user})
+		})
+		return &describedHandler{Handler: handler, describe: describeBasic}
+	}
+}
+
+// describeBasic documents the Basic middleware.
+var describeBasic = describeAuthentication(
+	router.SecurityScheme{Name: "basicAuth", Type: "http", Scheme: "basic"},
+	http.StatusUnauthorized,
+)
+
+// StaticUsers returns a verify function for Basic accepting the given user
+// names and plain-text passwords. Passwords are compared in constant time, and
+// unknown users take as long to reject as wrong passwords.
+func StaticUsers(users map[string]string) func(user, password string) bool {
+	hashes := make(map[string][sha256.Size]byte, len(users))
+	for user, password := range users {
+		hashes[user] = sha256.Sum256([]byte(password))
+	}
+	return func(user, password string) bool {
+		want, known := hashes[user]
+		got := sha256.Sum256([]byte(password))
+		match := subtle.ConstantTimeCompare(want[:], got[:]) == 1
+		return known && match
+	}
+}
+
+// Htpasswd holds the users of an Apache htpasswd file. Only bcrypt hashes
+// (htpasswd -B) are supported.
+type Htpasswd struct {
+	hashes map[string][]byte
+	dummy  []byte // compared for unknown users, so they take as long to reject
+}
+
+// LoadHtpasswd reads an htpasswd file.
+func LoadHtpasswd(path string) (*Htpasswd, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseHtpasswd(f)
+}
+
+// ParseHtpasswd reads htpasswd entries ("user:hash" lines) from r. Blank lines
+// and lines starting with '#' are ignored.
+func ParseHtpasswd(r io.Reader) (*Htpasswd, error) {
+	h := &Htpasswd{hashes: make(map[string][]byte)}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("auth: htpasswd line %d: missing user name", n)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("auth: htpasswd line %d: user %q: only bcrypt hashes are supported: %w", n, user, err)
+		}
+		h.hashes[user] = []byte(hash)
+		if h.dummy == nil {
+			h.dummy = []byte(hash)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Verify reports whether password is the password of user. It can be passed
+// to Basic.
+func (h *Htpasswd) Verify(user, password string) bool {
+	hash, known := h.hashes[user]
+	if !known {
+		if h.dummy == nil {
+			return false
+		}
+		hash = h.dummy
+	}
+	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+	return known && err == nil
+}
@@ -0,0 +1,90 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/iaconlabs/transwarp/middleware/auth"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// subjectHandler echoes the subject of the authenticated caller.
+var subjectHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetClaims(r.Context())
+	_, _ = w.Write([]byte(claims.Subject()))
+})
+
+func serveBasic(h http.Handler, user, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if user != "" {
+		req.SetBasicAuth(user, password)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+// TestBasic_StaticUsers verifies the challenge and the credential check.
+func TestBasic_StaticUsers(t *testing.T) {
+	h := auth.Basic("admin area", auth.StaticUsers(map[string]string{"alice": "s3cret"}))(subjectHandler)
+
+	rr := serveBasic(h, "", "")
+	if rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") != `Basic realm="admin area", charset="UTF-8"` {
+		t.Errorf("Expected a Basic challenge, got %d %q", rr.Code, rr.Header().Get("WWW-Authenticate"))
+	}
+	for _, creds := range [][2]string{{"alice", "wrong"}, {"bob", "s3cret"}, {"alice", ""}} {
+		if rr := serveBasic(h, creds[0], creds[1]); rr.Code != http.StatusUnauthorized {
+			t.Errorf("%v: expected 401, got %d", creds, rr.Code)
+		}
+	}
+	if rr := serveBasic(h, "alice", "s3cret"); rr.Code != http.StatusOK || rr.Body.String() != "alice" {
+		t.Errorf("Expected alice to be authenticated, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	var d router.RouteDescription
+	h.(router.RouteDescriber).DescribeRoute(&d)
+	if len(d.Errors) != 1 || d.Errors[0] != http.StatusUnauthorized {
+		t.Errorf("Expected 401 to be documented, got %v", d.Errors)
+	}
+}
+
+// TestHtpasswd verifies bcrypt htpasswd files.
+func TestHtpasswd(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// htpasswd -B writes the $2y$ prefix.
+	apacheHash := "$2y$" + strings.TrimPrefix(string(hash), "$2a$")
+	path := filepath.Join(t.TempDir(), ".htpasswd")
+	content := "# users\nalice:" + string(hash) + "\n\nbob:" + apacheHash + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := auth.LoadHtpasswd(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !users.Verify("alice", "s3cret") || !users.Verify("bob", "s3cret") {
+		t.Error("Expected both users to be verified")
+	}
+	if users.Verify("alice", "wrong") || users.Verify("carol", "s3cret") {
+		t.Error("Expected wrong passwords and unknown users to be rejected")
+	}
+
+	h := auth.Basic("api", users.Verify)(subjectHandler)
+	if rr := serveBasic(h, "bob", "s3cret"); rr.Body.String() != "bob" {
+		t.Errorf("Expected bob to be authenticated, got %d", rr.Code)
+	}
+
+	if _, err := auth.ParseHtpasswd(strings.NewReader("alice:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n")); err == nil {
+		t.Error("Expected non-bcrypt hashes to be rejected")
+	}
+}
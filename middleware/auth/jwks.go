@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Supported JWT signature algorithms (RFC 7518).
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+// verificationKey is a key of a KeySet with the algorithm it verifies.
+type verificationKey struct {
+	kid string
+	alg string
+	key any // []byte, *rsa.PublicKey or *ecdsa.PublicKey
+}
+
+// KeySet holds the keys JWT signatures are verified with. It is safe for
+// concurrent use, so keys can be rotated while the server runs.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []verificationKey
+}
+
+// NewKeySet creates an empty key set.
+func NewKeySet() *KeySet {
+	return &KeySet{}
+}
+
+// AddHMAC adds a shared secret for HS256 tokens. The secret must be at least
+// 32 bytes long (RFC 7518, section 3.2).
+func (s *KeySet) AddHMAC(kid string, secret []byte) error {
+	if len(secret) < sha256.Size {
+		return errors.New("auth: HS256 secrets must be at least 32 bytes long")
+	}
+	s.add(verificationKey{kid: kid, alg: HS256, key: slices.Clone(secret)})
+	return nil
+}
+
+// AddPublicKey adds an RSA public key for RS256 tokens or a P-256 ECDSA public
+// key for ES256 tokens.
+func (s *KeySet) AddPublicKey(kid string, key crypto.PublicKey) error {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return errors.New("auth: RS256 keys must be at least 2048 bits long")
+		}
+		s.add(verificationKey{kid: kid, alg: RS256, key: key})
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return errors.New("auth: ES256 keys must use the P-256 curve")
+		}
+		s.add(verificationKey{kid: kid, alg: ES256, key: key})
+	default:
+		return fmt.Errorf("auth: unsupported public key type %T", key)
+	}
+	return nil
+}
+
+// Replace atomically replaces the keys of s with those of other, e.g. after
+// reloading a JWKS file.
+func (s *KeySet) Replace(other *KeySet) {
+	other.mu.RLock()
+	keys := slices.Clone(other.keys)
+	other.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+// Len returns the number of keys in s.
+func (s *KeySet) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+func (s *KeySet) add(k verificationKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append(s.keys, k)
+}
+
+// candidates returns the keys that may have signed a token with the given
+// header: the keys with its kid, or every key when it has none, restricted to
+// its algorithm.
+func (s *KeySet) candidates(kid, alg string) []verificationKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []verificationKey
+	for _, k := range s.keys {
+		if k.alg == alg && (kid == "" || k.kid == kid) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// jwk is a JSON Web Key (RFC 7517) of the types supported by KeySet.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// Symmetric
+	K string `json:"k"`
+}
+
+// LoadJWKS reads a JSON Web Key Set file.
+func LoadJWKS(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+// ParseJWKS decodes a JSON Web Key Set ({"keys": [...]}) holding RSA, P-256 EC
+// and symmetric ("oct") keys. Encryption keys (use "enc") and keys of other
+// types or algorithms are skipped.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("auth: invalid JWKS: %w", err)
+	}
+
+	set := NewKeySet()
+	for i, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if err := set.addJWK(k); err != nil {
+			return nil, fmt.Errorf("auth: JWKS key %d (kid %q): %w", i, k.Kid, err)
+		}
+	}
+	return set, nil
+}
+
+// addJWK adds a JSON Web Key, skipping unsupported ones.
+func (s *KeySet) addJWK(k jwk) error {
+	switch {
+	case k.Kty == "RSA" && (k.Alg == "" || k.Alg == RS256):
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return errors.New("invalid RSA exponent")
+		}
+		return s.AddPublicKey(k.Kid, &rsa.PublicKey{N: n, E: int(e.Int64())})
+
+	case k.Kty == "EC" && k.Crv == "P-256" && (k.Alg == "" || k.Alg == ES256):
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return errors.New("invalid P-256 coordinates")
+		}
+		key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), slices.Concat([]byte{4}, x, y))
+		if err != nil {
+			return err
+		}
+		return s.AddPublicKey(k.Kid, key)
+
+	case k.Kty == "oct" && (k.Alg == "" || k.Alg == HS256):
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return err
+		}
+		return s.AddHMAC(k.Kid, secret)
+	}
+	return nil
+}
+
+// decodeBigInt decodes a base64url-encoded unsigned big-endian integer.
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
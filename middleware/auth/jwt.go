@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iaconlabs/transwarp/router"
+)
+
+var (
+	// ErrInvalidToken is returned (wrapped) for malformed tokens, unsupported
+	// algorithms, bad signatures and claims that do not match the verifier.
+	ErrInvalidToken = errors.New("auth: invalid token")
+	// ErrTokenExpired is returned for tokens past their "exp" claim or before
+	// their "nbf" claim.
+	ErrTokenExpired = errors.New("auth: token expired or not yet valid")
+)
+
+// JWTOption configures a JWTVerifier.
+type JWTOption func(*JWTVerifier)
+
+// WithIssuer requires the "iss" claim to be one of issuers.
+func WithIssuer(issuers ...string) JWTOption {
+	return func(v *JWTVerifier) {
+		v.issuers = append(v.issuers, issuers...)
+	}
+}
+
+// WithAudience requires the "aud" claim to contain audience, usually the
+// identifier of this API.
+func WithAudience(audience string) JWTOption {
+	return func(v *JWTVerifier) {
+		v.audience = audience
+	}
+}
+
+// WithLeeway tolerates clock skew between the issuer and this server when
+// checking "exp" and "nbf" (one minute by default).
+func WithLeeway(d time.Duration) JWTOption {
+	return func(v *JWTVerifier) {
+		if d >= 0 {
+			v.leeway = d
+		}
+	}
+}
+
+// JWTVerifier verifies compact JWS tokens (RFC 7519) signed with HS256, RS256
+// or ES256.
+type JWTVerifier struct {
+	keys     *KeySet
+	issuers  []string
+	audience string
+	leeway   time.Duration
+}
+
+// NewJWTVerifier creates a verifier checking signatures against keys.
+func NewJWTVerifier(keys *KeySet, opts ...JWTOption) *JWTVerifier {
+	v := &JWTVerifier{keys: keys, leeway: time.Minute}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Verify checks the signature and the registered claims of token and returns
+// its claims. The "exp" claim is required; "nbf", "iss" and "aud" are checked
+// when present or configured. The algorithm must match the key: a token
+// cannot choose "none" or sign with an RSA public key as an HMAC secret.
+func (v *JWTVerifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg  string   `json:"alg"`
+		Kid  string   `json:"kid"`
+		Crit []string `json:"crit"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %w", ErrInvalidToken, err)
+	}
+	if len(header.Crit) > 0 {
+		return nil, fmt.Errorf("%w: unsupported critical header parameters %v", ErrInvalidToken, header.Crit)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %w", ErrInvalidToken, err)
+	}
+	if !v.verifySignature(header.Alg, header.Kid, parts[0]+"."+parts[1], signature) {
+		return nil, fmt.Errorf("%w: signature verification failed", ErrInvalidToken)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: payload: %w", ErrInvalidToken, err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verifySignature reports whether signature was made over input by one of the
+// keys of alg matching kid.
+func (v *JWTVerifier) verifySignature(alg, kid, input string, signature []byte) bool {
+	switch alg {
+	case HS256, RS256, ES256:
+	default:
+		return false
+	}
+	digest := sha256.Sum256([]byte(input))
+	for _, k := range v.keys.candidates(kid, alg) {
+		switch key := k.key.(type) {
+		case []byte:
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte(input))
+			if hmac.Equal(mac.Sum(nil), signature) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			// JWS encodes ECDSA signatures as the fixed-size concatenation R || S.
+			if len(signature) != 64 {
+				continue
+			}
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			if ecdsa.Verify(key, digest[:], r, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkClaims validates the registered claims of a verified token.
+func (v *JWTVerifier) checkClaims(claims Claims) error {
+	now := time.Now()
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	if now.After(exp.Add(v.leeway)) {
+		return ErrTokenExpired
+	}
+	if raw, present := claims["nbf"]; present {
+		nbf, ok := numericDate(raw)
+		if !ok {
+			return fmt.Errorf("%w: invalid nbf claim", ErrInvalidToken)
+		}
+		if now.Add(v.leeway).Before(nbf) {
+			return ErrTokenExpired
+		}
+	}
+	if len(v.issuers) > 0 && !slices.Contains(v.issuers, claims.Issuer()) {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Issuer())
+	}
+	if v.audience != "" && !slices.Contains(claims.Audience(), v.audience) {
+		return fmt.Errorf("%w: token not issued for this audience", ErrInvalidToken)
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url-encoded JSON token segment into dst.
+func decodeSegment(segment string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// JWT returns a middleware that requires a bearer token (RFC 6750) accepted by
+// a JWTVerifier built from keys and opts. Requests without a token or with an
+// invalid one get 401 Unauthorized; the claims of valid tokens are stored in
+// the request context.
+//
+//	keys, err := auth.LoadJWKS("/etc/transwarp/jwks.json")
+//	...
+//	api.Use(auth.JWT(keys, auth.WithIssuer("https://id.example.com"), auth.WithAudience("orders-api")))
+func JWT(keys *KeySet, opts ...JWTOption) func(http.Handler) http.Handler {
+	verifier := NewJWTVerifier(keys, opts...)
+	return func(next http.Handler) http.Handler {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				unauthorized(w, r, "Bearer", "A bearer token is required")
+				return
+			}
+			claims, err := verifier.Verify(token)
+			if err != nil {
+				detail := "The access token is invalid"
+				if errors.Is(err, ErrTokenExpired) {
+					detail = "The access token is expired or not yet valid"
+				}
+				unauthorized(w, r, `Bearer error="invalid_token", error_description=`+strconv.Quote(detail), detail)
+				return
+			}
+			authenticated(next, w, r, claims)
+		})
+		return &describedHandler{Handler: handler, describe: describeJWT}
+	}
+}
+
+// describeJWT documents the JWT middleware.
+var describeJWT = describeAuthentication(
+	router.SecurityScheme{Name: "bearerAuth", Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+	http.StatusUnauthorized,
+)
+
+// bearerToken extracts the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
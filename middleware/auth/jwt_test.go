@@ -0,0 +1,260 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iaconlabs/transwarp/middleware/auth"
+)
+
+var (
+	hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+	rsaKey     = mustRSAKey()
+	ecKey      = mustECKey()
+)
+
+func mustRSAKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func mustECKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signToken builds a compact JWS with the given algorithm and key.
+func signToken(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return input + "." + b64(sig)
+}
+
+// validClaims returns claims accepted by the verifiers of these tests.
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub": "user-1",
+		"iss": "https://id.example.com",
+		"aud": []string{"orders", "billing"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func testKeySet(t *testing.T) *auth.KeySet {
+	t.Helper()
+	keys := auth.NewKeySet()
+	if err := keys.AddHMAC("hs", hmacSecret); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.AddPublicKey("rs", &rsaKey.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.AddPublicKey("es", &ecKey.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// TestJWTVerifier_Algorithms verifies HS256, RS256 and ES256 signatures.
+func TestJWTVerifier_Algorithms(t *testing.T) {
+	v := auth.NewJWTVerifier(testKeySet(t))
+	cases := []struct {
+		alg, kid string
+		key      any
+	}{
+		{auth.HS256, "hs", hmacSecret},
+		{auth.RS256, "rs", rsaKey},
+		{auth.ES256, "es", ecKey},
+		{auth.ES256, "", ecKey},
+	}
+	for _, tc := range cases {
+		token := signToken(t, tc.alg, tc.kid, tc.key, validClaims())
+		claims, err := v.Verify(token)
+		if err != nil {
+			t.Errorf("%s/%q: %v", tc.alg, tc.kid, err)
+			continue
+		}
+		if claims.Subject() != "user-1" {
+			t.Errorf("%s: unexpected claims %v", tc.alg, claims)
+		}
+
+		parts := strings.Split(token, ".")
+		tampered, _ := json.Marshal(map[string]any{"sub": "admin", "exp": time.Now().Add(time.Hour).Unix()})
+		if _, err := v.Verify(parts[0] + "." + b64(tampered) + "." + parts[2]); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("%s: expected a tampered payload to be rejected, got %v", tc.alg, err)
+		}
+	}
+}
+
+// TestJWTVerifier_AlgorithmConfusion verifies that tokens cannot choose an
+// algorithm the key was not registered for.
+func TestJWTVerifier_AlgorithmConfusion(t *testing.T) {
+	v := auth.NewJWTVerifier(testKeySet(t))
+
+	header := b64([]byte(`{"alg":"none"}`))
+	payload, _ := json.Marshal(validClaims())
+	if _, err := v.Verify(header + "." + b64(payload) + "."); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Expected alg none to be rejected, got %v", err)
+	}
+
+	// An HS256 token using the RSA public key as the HMAC secret.
+	secret, _ := json.Marshal(rsaKey.PublicKey)
+	if _, err := v.Verify(signToken(t, auth.HS256, "rs", secret, validClaims())); err == nil {
+		t.Error("Expected an HS256 token for an RSA key to be rejected")
+	}
+	if _, err := v.Verify(signToken(t, auth.RS256, "es", rsaKey, validClaims())); err == nil {
+		t.Error("Expected a kid of another algorithm to be rejected")
+	}
+	other := mustECKey()
+	if _, err := v.Verify(signToken(t, auth.ES256, "es", other, validClaims())); err == nil {
+		t.Error("Expected a token signed with an unknown key to be rejected")
+	}
+}
+
+// TestJWTVerifier_Claims verifies the exp, nbf, iss and aud checks.
+func TestJWTVerifier_Claims(t *testing.T) {
+	v := auth.NewJWTVerifier(testKeySet(t),
+		auth.WithIssuer("https://id.example.com"),
+		auth.WithAudience("orders"),
+		auth.WithLeeway(time.Second),
+	)
+	cases := []struct {
+		name   string
+		modify func(c map[string]any)
+		want   error
+	}{
+		{"valid", func(map[string]any) {}, nil},
+		{"expired", func(c map[string]any) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, auth.ErrTokenExpired},
+		{"within leeway", func(c map[string]any) { c["exp"] = time.Now().Unix() }, nil},
+		{"far future", func(c map[string]any) { c["exp"] = 9999999999 }, nil},
+		{"fractional", func(c map[string]any) { c["exp"] = float64(time.Now().Add(time.Hour).UnixMilli()) / 1000 }, nil},
+		{"missing exp", func(c map[string]any) { delete(c, "exp") }, auth.ErrInvalidToken},
+		{"not yet valid", func(c map[string]any) { c["nbf"] = time.Now().Add(time.Minute).Unix() }, auth.ErrTokenExpired},
+		{"issuer", func(c map[string]any) { c["iss"] = "https://evil.example.com" }, auth.ErrInvalidToken},
+		{"audience", func(c map[string]any) { c["aud"] = "payments" }, auth.ErrInvalidToken},
+		{"single audience", func(c map[string]any) { c["aud"] = "orders" }, nil},
+	}
+	for _, tc := range cases {
+		claims := validClaims()
+		tc.modify(claims)
+		_, err := v.Verify(signToken(t, auth.RS256, "rs", rsaKey, claims))
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+// TestJWT_Middleware verifies the bearer challenge and the stored claims.
+func TestJWT_Middleware(t *testing.T) {
+	h := auth.JWT(testKeySet(t))(subjectHandler)
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(""); rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("Expected a bearer challenge, got %d %q", rr.Code, rr.Header().Get("WWW-Authenticate"))
+	}
+	rr := serve("Bearer not-a-token")
+	if rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Header().Get("WWW-Authenticate"), `error="invalid_token"`) {
+		t.Errorf("Expected an invalid_token challenge, got %d %q", rr.Code, rr.Header().Get("WWW-Authenticate"))
+	}
+	token := signToken(t, auth.HS256, "hs", hmacSecret, validClaims())
+	if rr := serve("bearer " + token); rr.Code != http.StatusOK || rr.Body.String() != "user-1" {
+		t.Errorf("Expected the token to be accepted, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+// TestLoadJWKS verifies RSA, EC and symmetric keys loaded from a JWKS file.
+func TestLoadJWKS(t *testing.T) {
+	ecBytes, err := ecKey.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rs", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "es", "crv": "P-256", "x": b64(ecBytes[1:33]), "y": b64(ecBytes[33:])},
+		{"kty": "oct", "kid": "hs", "alg": "HS256", "k": b64(hmacSecret)},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "AAAA"},
+	}}
+	data, _ := json.Marshal(jwks)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := auth.LoadJWKS(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.Len() != 3 {
+		t.Errorf("Expected 3 signing keys, got %d", keys.Len())
+	}
+	v := auth.NewJWTVerifier(keys)
+	for alg, key := range map[string]any{auth.RS256: rsaKey, auth.ES256: ecKey, auth.HS256: hmacSecret} {
+		kid := strings.ToLower(alg[:2])
+		if _, err := v.Verify(signToken(t, alg, kid, key, validClaims())); err != nil {
+			t.Errorf("%s: %v", alg, err)
+		}
+	}
+
+	if _, err := auth.ParseJWKS([]byte(`{"keys":[{"kty":"oct","k":"c2hvcnQ"}]}`)); err == nil {
+		t.Error("Expected a short HMAC secret to be rejected")
+	}
+}
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// Internal default instance backing the package-level Require and RequireAny helpers.
+var defaultPolicy = NewPolicy()
+
+// DefaultPolicy returns the Policy used by the package-level Require and
+// RequireAny middlewares. Grant permissions to roles on it at startup.
+func DefaultPolicy() *Policy {
+	return defaultPolicy
+}
+
+// Policy implements role-based access control. A caller holds the permissions
+// granted to its roles (Claims.Roles) plus its OAuth scopes (Claims.Scopes);
+// anything not granted is denied. Permissions are opaque strings such as
+// "orders:read"; a granted permission ending in "*" covers every permission
+// with that prefix, so "orders:*" covers "orders:read" and "*" covers all.
+type Policy struct {
+	mu     sync.RWMutex
+	grants map[string][]string
+}
+
+// NewPolicy creates a policy that grants nothing.
+func NewPolicy() *Policy {
+	return &Policy{grants: make(map[string][]string)}
+}
+
+// Grant gives role the permissions and returns p, so grants can be chained.
+func (p *Policy) Grant(role string, permissions ...string) *Policy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.grants[role] = append(p.grants[role], permissions...)
+	return p
+}
+
+// Permissions returns the permissions held by the caller with claims.
+func (p *Policy) Permissions(claims Claims) []string {
+	held := claims.Scopes()
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, role := range claims.Roles() {
+		held = append(held, p.grants[role]...)
+	}
+	return held
+}
+
+// Allowed reports whether the caller with claims holds permission.
+func (p *Policy) Allowed(claims Claims, permission string) bool {
+	return covers(p.Permissions(claims), permission)
+}
+
+// Require returns a middleware that lets through only callers holding every
+// one of permissions. It must run after an authentication middleware:
+// anonymous requests get 401 Unauthorized, and callers lacking permissions get
+// 403 Forbidden listing the missing ones. Attached to a group with Use, it
+// guards every route of the group.
+//
+//	admin := app.Group("/admin")
+//	admin.Use(auth.JWT(keys), auth.Require("admin:write"))
+func (p *Policy) Require(permissions ...string) func(http.Handler) http.Handler {
+	return p.authorize(permissions, func(held []string) []string {
+		var missing []string
+		for _, permission := range permissions {
+			if !covers(held, permission) {
+				missing = append(missing, permission)
+			}
+		}
+		return missing
+	}, func(d *router.RouteDescription) {
+		d.AddPermission(permissions...)
+	})
+}
+
+// RequireAny is like Require, but lets through callers holding at least one
+// of permissions.
+func (p *Policy) RequireAny(permissions ...string) func(http.Handler) http.Handler {
+	return p.authorize(permissions, func(held []string) []string {
+		if slices.ContainsFunc(permissions, func(permission string) bool { return covers(held, permission) }) {
+			return nil
+		}
+		return permissions
+	}, func(d *router.RouteDescription) {
+		d.AddRule("any of: " + strings.Join(permissions, ", "))
+	})
+}
+
+// authorize builds the Require and RequireAny middlewares. missing returns
+// the permissions that deny access.
+func (p *Policy) authorize(permissions []string, missing func(held []string) []string, describe func(d *router.RouteDescription)) func(http.Handler) http.Handler {
+	if len(permissions) == 0 {
+		panic("transwarp: an authorization requirement needs at least one permission")
+	}
+	return func(next http.Handler) http.Handler {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := GetClaims(r.Context())
+			if !ok {
+				problem.Write(w, r, problem.New(http.StatusUnauthorized).WithDetail("Authentication is required"))
+				return
+			}
+			if m := missing(p.Permissions(claims)); len(m) > 0 {
+				problem.Write(w, r, problem.New(http.StatusForbidden).
+					WithDetail("The caller lacks the permissions required by this route").
+					With("missing_permissions", m))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+		return &describedHandler{Handler: handler, describe: func(d *router.RouteDescription) {
+			describe(d)
+			d.AddError(http.StatusUnauthorized, http.StatusForbidden)
+		}}
+	}
+}
+
+// Require returns a middleware requiring every one of permissions under the
+// default policy (see Policy.Require).
+func Require(permissions ...string) func(http.Handler) http.Handler {
+	return defaultPolicy.Require(permissions...)
+}
+
+// RequireAny returns a middleware requiring at least one of permissions under
+// the default policy (see Policy.RequireAny).
+func RequireAny(permissions ...string) func(http.Handler) http.Handler {
+	return defaultPolicy.RequireAny(permissions...)
+}
+
+// covers reports whether the held permissions include permission, directly
+// or through a wildcard.
+func covers(held []string, permission string) bool {
+	for _, h := range held {
+		if h == permission {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(h, "*"); ok && strings.HasPrefix(permission, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule decides whether an authenticated caller may access the resource of a
+// request. params holds the path parameters of the matched route.
+type Rule func(r *http.Request, claims Claims, params map[string]string) bool
+
+// Allow returns a middleware enforcing a resource-level rule: callers for
+// whom rule returns false get 403 Forbidden. description documents the rule in
+// route introspection and in the 403 response.
+//
+//	app.GET("/orgs/:org_id/invoices", h, auth.JWT(keys), auth.Allow("member of org_id",
+//		func(r *http.Request, c auth.Claims, params map[string]string) bool {
+//			return slices.Contains(memberships(c.Subject()), params["org_id"])
+//		}))
+func Allow(description string, rule Rule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := GetClaims(r.Context())
+			if !ok {
+				problem.Write(w, r, problem.New(http.StatusUnauthorized).WithDetail("Authentication is required"))
+				return
+			}
+			var params map[string]string
+			if state, ok := r.Context().Value(router.StateKey).(*adapter.TranswarpState); ok && state != nil {
+				params = state.Params
+			}
+			if !rule(r, claims, params) {
+				problem.Write(w, r, problem.New(http.StatusForbidden).
+					WithDetail("The caller may not access this resource").
+					With("rule", description))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+		return &describedHandler{Handler: handler, describe: func(d *router.RouteDescription) {
+			d.AddRule(description)
+			d.AddError(http.StatusUnauthorized, http.StatusForbidden)
+		}}
+	}
+}
+
+// RequireParam returns a middleware letting through callers whose claim
+// matches the path parameter param: the claim equals the value or, when it
+// is an array, contains it. Use it to restrict tenants to their own resources.
+//
+//	orgs := app.Group("/orgs/:org_id")
+//	orgs.Use(auth.JWT(keys), auth.RequireParam("org_id", "orgs"))
+func RequireParam(param, claim string) func(http.Handler) http.Handler {
+	return Allow(param+" in claim "+claim, func(_ *http.Request, claims Claims, params map[string]string) bool {
+		value, ok := params[param]
+		return ok && value != "" && slices.Contains(stringList(claims[claim]), value)
+	})
+}
+
+// Public returns a middleware marking a route as open to anonymous callers. It
+// does nothing at request time; it exempts the route from DenyByDefault and
+// documents the decision in route introspection.
+func Public() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return &describedHandler{Handler: next, describe: func(d *router.RouteDescription) {
+			d.Public = true
+		}}
+	}
+}
+
+// RouteLister is implemented by *transwarp.Transwarp.
+type RouteLister interface {
+	Routes() []router.Route
+}
+
+// DenyByDefault returns a middleware that rejects, with 403 Forbidden, every
+// request whose route declares no authorization policy: no Require,
+// RequireAny, Allow, RequireParam or Public middleware. Routes are looked up in
+// routes by method and pattern on the first request, so they must all be
+// registered before serving; requests to routes unknown to it are denied too.
+// Attach it to the application or a group so that forgetting a policy fails
+// closed:
+//
+//	api := app.Group("/api")
+//	api.Use(auth.JWT(keys), auth.DenyByDefault(app))
+//	api.GET("/orders", list, auth.Require("orders:read"))
+//	api.GET("/status", status, auth.Public()) // open despite the group guard
+func DenyByDefault(routes RouteLister) func(http.Handler) http.Handler {
+	authorized := sync.OnceValue(func() map[string]bool {
+		index := make(map[string]bool)
+		for _, route := range routes.Routes() {
+			d := route.Describe()
+			index[route.Method+" "+route.Path] = d.Authorized()
+		}
+		return index
+	})
+
+	return func(next http.Handler) http.Handler {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pattern := adapter.RoutePattern(r)
+			ok := authorized()[r.Method+" "+pattern]
+			if !ok && r.Method == http.MethodHead {
+				ok = authorized()[http.MethodGet+" "+pattern]
+			}
+			if !ok {
+				slog.WarnContext(r.Context(), "transwarp: route has no authorization policy",
+					"method", r.Method, "route", pattern)
+				problem.Write(w, r, problem.New(http.StatusForbidden).
+					WithDetail("The route has no authorization policy"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+		return &describedHandler{Handler: handler, describe: func(d *router.RouteDescription) {
+			d.AddError(http.StatusForbidden)
+		}}
+	}
+}
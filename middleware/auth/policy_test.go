@@ -0,0 +1,148 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware/auth"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+})
+
+// serveAs sends a request with the given claims (none when nil) and route
+// state through h.
+func serveAs(h http.Handler, method, route string, params map[string]string, claims auth.Claims) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/", nil)
+	ctx := adapter.WithState(req.Context(), &adapter.TranswarpState{Params: params, Route: route})
+	if claims != nil {
+		ctx = auth.WithClaims(ctx, claims)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req.WithContext(ctx))
+	return rr
+}
+
+// TestPolicy_Require verifies role grants, scopes and wildcards.
+func TestPolicy_Require(t *testing.T) {
+	policy := auth.NewPolicy().
+		Grant("admin", "admin:*").
+		Grant("support", "orders:read", "users:read")
+	h := policy.Require("admin:write")(okHandler)
+
+	cases := []struct {
+		name   string
+		claims auth.Claims
+		want   int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"no roles", auth.Claims{"sub": "u"}, http.StatusForbidden},
+		{"other role", auth.Claims{"roles": []any{"support"}}, http.StatusForbidden},
+		{"unknown role", auth.Claims{"roles": []any{"root"}}, http.StatusForbidden},
+		{"wildcard grant", auth.Claims{"roles": []any{"support", "admin"}}, http.StatusNoContent},
+		{"scope", auth.Claims{"scope": "openid admin:write"}, http.StatusNoContent},
+	}
+	for _, tc := range cases {
+		if rr := serveAs(h, http.MethodPost, "/admin", nil, tc.claims); rr.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, rr.Code)
+		}
+	}
+
+	rr := serveAs(policy.Require("orders:read", "orders:write")(okHandler), http.MethodGet, "/", nil,
+		auth.Claims{"roles": "support"})
+	var body struct {
+		Missing []string `json:"missing_permissions"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusForbidden || !slices.Equal(body.Missing, []string{"orders:write"}) {
+		t.Errorf("Expected a 403 listing orders:write, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	anyOf := policy.RequireAny("orders:write", "users:read")(okHandler)
+	if rr := serveAs(anyOf, http.MethodGet, "/", nil, auth.Claims{"roles": "support"}); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected one permission to be enough, got %d", rr.Code)
+	}
+}
+
+// TestRequireParam verifies resource-level checks on path parameters.
+func TestRequireParam(t *testing.T) {
+	h := auth.RequireParam("org_id", "orgs")(okHandler)
+	member := auth.Claims{"orgs": []any{"acme", "globex"}}
+
+	if rr := serveAs(h, http.MethodGet, "/orgs/:org_id", map[string]string{"org_id": "acme"}, member); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected members to be allowed, got %d", rr.Code)
+	}
+	if rr := serveAs(h, http.MethodGet, "/orgs/:org_id", map[string]string{"org_id": "initech"}, member); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected other organizations to be forbidden, got %d", rr.Code)
+	}
+	if rr := serveAs(h, http.MethodGet, "/orgs/:org_id", map[string]string{"org_id": "acme"}, auth.Claims{"orgs": "acme"}); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected a single-valued claim to match, got %d", rr.Code)
+	}
+
+	rule := auth.Allow("owner", func(_ *http.Request, c auth.Claims, params map[string]string) bool {
+		return params["user_id"] == c.Subject()
+	})(okHandler)
+	if rr := serveAs(rule, http.MethodGet, "/users/:user_id", map[string]string{"user_id": "u2"}, auth.Claims{"sub": "u1"}); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected the custom rule to deny, got %d", rr.Code)
+	}
+}
+
+// routeList is a static RouteLister.
+type routeList []router.Route
+
+func (l routeList) Routes() []router.Route { return l }
+
+// TestDenyByDefault verifies that routes without a policy are rejected.
+func TestDenyByDefault(t *testing.T) {
+	mw := func(http.Handler) http.Handler { return okHandler }
+	var routes routeList
+	guard := auth.DenyByDefault(&routes)
+	routes = routeList{
+		{Method: http.MethodGet, Path: "/api/orders", Middlewares: []func(http.Handler) http.Handler{guard, auth.Require("orders:read")}},
+		{Method: http.MethodGet, Path: "/api/status", Middlewares: []func(http.Handler) http.Handler{guard, auth.Public()}},
+		{Method: http.MethodGet, Path: "/api/forgotten", Middlewares: []func(http.Handler) http.Handler{guard, mw}},
+	}
+	h := guard(okHandler)
+
+	for route, want := range map[string]int{
+		"/api/orders":    http.StatusNoContent,
+		"/api/status":    http.StatusNoContent,
+		"/api/forgotten": http.StatusForbidden,
+		"/api/unknown":   http.StatusForbidden,
+	} {
+		if rr := serveAs(h, http.MethodGet, route, nil, nil); rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", route, want, rr.Code)
+		}
+	}
+	if rr := serveAs(h, http.MethodHead, "/api/status", nil, nil); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected HEAD to follow the GET route, got %d", rr.Code)
+	}
+}
+
+// TestPolicy_Describe verifies that policies are visible in route introspection.
+func TestPolicy_Describe(t *testing.T) {
+	route := router.Route{Method: http.MethodPost, Path: "/orgs/:org_id/admin", Middlewares: []func(http.Handler) http.Handler{
+		auth.JWT(auth.NewKeySet()),
+		auth.Require("admin:write"),
+		auth.RequireParam("org_id", "orgs"),
+	}}
+	d := route.Describe()
+
+	if len(d.Security) != 1 || d.Security[0].Name != "bearerAuth" || d.Security[0].BearerFormat != "JWT" {
+		t.Errorf("Unexpected security schemes %+v", d.Security)
+	}
+	if !slices.Equal(d.Permissions, []string{"admin:write"}) || !slices.Equal(d.Rules, []string{"org_id in claim orgs"}) {
+		t.Errorf("Unexpected policy %v %v", d.Permissions, d.Rules)
+	}
+	if !d.Authorized() || !slices.Contains(d.Errors, http.StatusForbidden) {
+		t.Errorf("Expected an authorized route documenting 403, got %+v", d)
+	}
+}
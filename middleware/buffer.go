@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bytes"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// bufferedWriter captures a handler's response (status, headers and body) so a
+// middleware can inspect or replace it before anything reaches the client.
+// Headers set by outer middlewares are visible to the handler; nothing is sent
+// until flush is called.
+type bufferedWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// newBufferedWriter starts capturing a response destined for w.
+func newBufferedWriter(w http.ResponseWriter) *bufferedWriter {
+	return &bufferedWriter{w: w, header: w.Header().Clone(), status: http.StatusOK}
+}
+
+// Header returns the captured header map.
+func (b *bufferedWriter) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader records the status code. Only the first call has effect.
+func (b *bufferedWriter) WriteHeader(status int) {
+	if b.wroteHeader {
+		return
+	}
+	b.status = status
+	b.wroteHeader = true
+}
+
+// Write appends p to the captured body.
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
+
+// flush sends the captured response to the underlying writer.
+func (b *bufferedWriter) flush() {
+	dst := b.w.Header()
+	clear(dst)
+	maps.Copy(dst, b.header)
+	b.w.WriteHeader(b.status)
+	if b.body.Len() > 0 {
+		_, _ = b.w.Write(b.body.Bytes())
+	}
+}
+
+// handlerHeader returns the headers set or changed by the handler, compared
+// with initial, the headers of the underlying writer before it ran. Middlewares
+// storing responses for replay use it to leave out per-request headers set by
+// outer middlewares, such as the request ID.
+func (b *bufferedWriter) handlerHeader(initial http.Header) http.Header {
+	header := http.Header{}
+	for k, v := range b.header {
+		if !slices.Equal(initial[k], v) {
+			header[k] = slices.Clone(v)
+		}
+	}
+	return header
+}
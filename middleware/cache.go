@@ -0,0 +1,439 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// CachedResponse is a response stored by Cache.
+type CachedResponse struct {
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	// Stored is when the response was generated, used for the Age header.
+	Stored time.Time `json:"stored"`
+	// Expires is when the response becomes stale.
+	Expires time.Time `json:"expires"`
+	// StaleUntil is when a stale response can no longer be served while it is
+	// revalidated (stale-while-revalidate).
+	StaleUntil time.Time `json:"stale_until"`
+	// Vary lists the request headers the response varies on. An entry with a
+	// non-empty Vary and no Status is an index pointing to the variants.
+	Vary []string `json:"vary,omitempty"`
+
+	key string // full key the response was generated for, shared with waiters
+}
+
+// CacheStore persists cached responses, e.g. in memory (see MemoryCacheStore)
+// or in Redis to share the cache between instances.
+type CacheStore interface {
+	// Get returns the response stored under key, or nil when missing or expired.
+	Get(ctx context.Context, key string) (*CachedResponse, error)
+	// Set stores resp under key for ttl.
+	Set(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error
+}
+
+// CacheOption configures Cache.
+type CacheOption func(*cacheConfig)
+
+// cacheConfig holds the settings of a Cache middleware.
+type cacheConfig struct {
+	ttl         time.Duration
+	store       CacheStore
+	params      []string
+	query       []string
+	filterQuery bool
+	flights     flightGroup
+}
+
+// WithCacheStore sets where responses are kept. The default is a
+// MemoryCacheStore of 1000 entries private to the middleware.
+func WithCacheStore(s CacheStore) CacheOption {
+	return func(c *cacheConfig) {
+		if s != nil {
+			c.store = s
+		}
+	}
+}
+
+// WithCacheParams restricts the path parameters that are part of the cache
+// key (all of them by default), for parameters that do not affect the response.
+func WithCacheParams(names ...string) CacheOption {
+	return func(c *cacheConfig) {
+		c.params = names
+	}
+}
+
+// WithCacheQuery restricts the query parameters that are part of the cache key
+// (all of them by default). Without arguments the query string is ignored.
+func WithCacheQuery(names ...string) CacheOption {
+	return func(c *cacheConfig) {
+		c.query = names
+		c.filterQuery = true
+	}
+}
+
+// Cache returns a middleware caching GET and HEAD responses on the server for
+// ttl. Entries are keyed on the method, route pattern, path parameters, query
+// string and the request headers named by the response's Vary header.
+//
+// The response's Cache-Control is honored: no-store, no-cache and private
+// responses are not cached, s-maxage or max-age override ttl (a zero ttl only
+// caches responses that set them) and stale-while-revalidate lets stale
+// entries be served while they are refreshed in the background. Responses
+// setting cookies and requests with Cache-Control: no-store bypass the cache;
+// no-cache forces a refresh. Responses to credentialed requests (carrying
+// Authorization or Cookie, or authenticated with claims in the context) are
+// personal and only stored when they are public or set s-maxage. Concurrent
+// misses for the same key run the handler once.
+//
+// Hits replay the original status and headers, adding Age and X-Cache (HIT,
+// STALE or MISS).
+func Cache(ttl time.Duration, opts ...CacheOption) func(http.Handler) http.Handler {
+	cfg := &cacheConfig{ttl: max(ttl, 0)}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.store == nil {
+		cfg.store = NewMemoryCacheStore(1000)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			directives := parseCacheControl(r.Header.Get("Cache-Control"))
+			if _, ok := directives["no-store"]; ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			_, noCache := directives["no-cache"]
+
+			ctx := r.Context()
+			base := cfg.key(r)
+			key := base
+			entry, err := cfg.store.Get(ctx, base)
+			if err == nil && entry != nil && entry.Status == 0 && len(entry.Vary) > 0 {
+				key = varyKey(base, entry.Vary, r)
+				entry, err = cfg.store.Get(ctx, key)
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "transwarp: cache store failed", "error", err)
+				entry = nil
+			}
+
+			now := time.Now()
+			if entry != nil && entry.Status != 0 && !noCache {
+				if now.Before(entry.Expires) {
+					entry.write(w, "HIT", now)
+					return
+				}
+				if now.Before(entry.StaleUntil) {
+					entry.write(w, "STALE", now)
+					cfg.revalidate(next, r, key)
+					return
+				}
+			}
+
+			resp, cacheable, leader := cfg.flights.do(key, func() (*CachedResponse, bool) {
+				return cfg.fill(next, w, r, base)
+			})
+			switch {
+			case leader:
+				// The leader's response has already been written.
+			case cacheable && (len(resp.Vary) == 0 || varyKey(base, resp.Vary, r) == resp.key):
+				resp.write(w, "HIT", time.Now())
+			default:
+				// The shared response was not cacheable, or varies on headers
+				// this request does not share: run the handler for this request.
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// fill runs the handler, writes its response to w and stores it when cacheable.
+func (c *cacheConfig) fill(next http.Handler, w http.ResponseWriter, r *http.Request, base string) (*CachedResponse, bool) {
+	initial := w.Header().Clone()
+	w.Header().Set("X-Cache", "MISS")
+	bw := newBufferedWriter(w)
+	next.ServeHTTP(bw, r)
+
+	header := bw.handlerHeader(initial)
+	header.Del("X-Cache")
+	resp := &CachedResponse{
+		Status: bw.status,
+		Header: header,
+		Body:   slices.Clone(bw.body.Bytes()),
+		Stored: time.Now(),
+	}
+	bw.flush()
+
+	ttl, stale, ok := c.policy(r, resp)
+	if !ok {
+		return resp, false
+	}
+	resp.Expires = resp.Stored.Add(ttl)
+	resp.StaleUntil = resp.Expires.Add(stale)
+	if vary := varyHeaders(header); len(vary) > 0 {
+		resp.Vary = vary
+		index := &CachedResponse{Vary: vary, Stored: resp.Stored, Expires: resp.Expires, StaleUntil: resp.StaleUntil}
+		if err := c.store.Set(r.Context(), base, index, ttl+stale); err != nil {
+			slog.ErrorContext(r.Context(), "transwarp: cache store failed", "error", err)
+			return resp, false
+		}
+		base = varyKey(base, vary, r)
+	}
+	resp.key = base
+	if err := c.store.Set(r.Context(), base, resp, ttl+stale); err != nil {
+		slog.ErrorContext(r.Context(), "transwarp: cache store failed", "error", err)
+	}
+	return resp, true
+}
+
+// revalidate refreshes a stale entry in the background, unless a refresh for
+// the same key is already running.
+func (c *cacheConfig) revalidate(next http.Handler, r *http.Request, key string) {
+	if !c.flights.start(key) {
+		return
+	}
+	bg := r.Clone(context.WithoutCancel(r.Context()))
+	bg.Header.Del("Cache-Control")
+	go func() {
+		defer c.flights.finish(key)
+		c.fill(next, &discardWriter{header: http.Header{}}, bg, c.key(bg))
+	}()
+}
+
+// policy decides whether resp may be cached and for how long, per its
+// Cache-Control header.
+func (c *cacheConfig) policy(r *http.Request, resp *CachedResponse) (ttl, stale time.Duration, ok bool) {
+	switch resp.Status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusGone:
+	default:
+		return 0, 0, false
+	}
+	if resp.Header.Get("Set-Cookie") != "" || resp.Header.Get("Vary") == "*" {
+		return 0, 0, false
+	}
+
+	cc := parseCacheControl(resp.Header.Get("Cache-Control"))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, found := cc[d]; found {
+			return 0, 0, false
+		}
+	}
+	_, public := cc["public"]
+	sMaxAge, shared := cc["s-maxage"]
+	if credentialed(r) && !public && !shared {
+		return 0, 0, false
+	}
+
+	ttl = c.ttl
+	if v, found := cc["max-age"]; found {
+		ttl = deltaSeconds(v)
+	}
+	if shared {
+		ttl = deltaSeconds(sMaxAge)
+	}
+	if ttl <= 0 {
+		return 0, 0, false
+	}
+	if v, found := cc["stale-while-revalidate"]; found {
+		stale = deltaSeconds(v)
+	}
+	return ttl, stale, true
+}
+
+// credentialed reports whether r identifies its caller, so that the response
+// may be personal.
+func credentialed(r *http.Request) bool {
+	return r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" ||
+		r.Context().Value(router.ClaimsKey) != nil
+}
+
+// key builds the base cache key: method, route pattern (or path), path
+// parameters and query string.
+func (c *cacheConfig) key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+
+	state, _ := r.Context().Value(router.StateKey).(*adapter.TranswarpState)
+	if route := adapter.RoutePattern(r); route != "" && state != nil {
+		b.WriteString(route)
+		names := c.params
+		if names == nil {
+			names = slices.Sorted(maps.Keys(state.Params))
+		}
+		for _, name := range names {
+			if value, ok := state.Params[name]; ok {
+				b.WriteString("|" + name + "=" + url.QueryEscape(value))
+			}
+		}
+	} else {
+		b.WriteString(r.URL.Path)
+	}
+
+	query := r.URL.Query()
+	if c.filterQuery {
+		for name := range query {
+			if !slices.Contains(c.query, name) {
+				delete(query, name)
+			}
+		}
+	}
+	if len(query) > 0 {
+		// Encode sorts by name, so equivalent query strings share an entry.
+		b.WriteString("?" + query.Encode())
+	}
+	return b.String()
+}
+
+// varyKey extends base with the values of the request headers in vary.
+func varyKey(base string, vary []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(base)
+	for _, name := range vary {
+		b.WriteString("\x00" + name + ":" + strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// varyHeaders returns the sorted, canonical header names listed in Vary.
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Vary") {
+		for name := range strings.SplitSeq(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// parseCacheControl parses a Cache-Control header into lowercase directives
+// and their (unquoted) values.
+func parseCacheControl(header string) map[string]string {
+	directives := map[string]string{}
+	for part := range strings.SplitSeq(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			directives[name] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return directives
+}
+
+// deltaSeconds converts a delta-seconds value, treating invalid values as 0.
+func deltaSeconds(v string) time.Duration {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
+
+// write replays the cached response to w.
+func (c *CachedResponse) write(w http.ResponseWriter, status string, now time.Time) {
+	h := w.Header()
+	for k, v := range c.Header {
+		h[k] = slices.Clone(v)
+	}
+	h.Set("Age", strconv.Itoa(int(max(now.Sub(c.Stored), 0)/time.Second)))
+	h.Set("X-Cache", status)
+	w.WriteHeader(c.Status)
+	if len(c.Body) > 0 {
+		_, _ = w.Write(c.Body)
+	}
+}
+
+// discardWriter is the response writer of background revalidations.
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (d *discardWriter) WriteHeader(int)             {}
+
+// flightGroup collapses concurrent work on the same key, like
+// golang.org/x/sync/singleflight.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done      chan struct{}
+	resp      *CachedResponse
+	cacheable bool
+}
+
+// do runs fn once for concurrent callers with the same key. The caller that
+// ran fn gets leader set; the others wait and share its result.
+func (g *flightGroup) do(key string, fn func() (*CachedResponse, bool)) (resp *CachedResponse, cacheable, leader bool) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.resp, call.cacheable, false
+	}
+	call := &flightCall{done: make(chan struct{})}
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		// Waiters are released even if fn panics; they then run the handler.
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.resp, call.cacheable = fn()
+	return call.resp, call.cacheable, true
+}
+
+// start registers a background call for key, reporting false if one is running.
+func (g *flightGroup) start(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.calls[key]; ok {
+		return false
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	g.calls[key] = &flightCall{done: make(chan struct{})}
+	return true
+}
+
+// finish ends a call registered with start, releasing any waiters.
+func (g *flightGroup) finish(key string) {
+	g.mu.Lock()
+	call := g.calls[key]
+	delete(g.calls, key)
+	g.mu.Unlock()
+	if call != nil {
+		close(call.done)
+	}
+}
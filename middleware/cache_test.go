@@ -0,0 +1,309 @@
+package middleware_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// countingHandler answers with the number of times it ran and the given
+// Cache-Control header.
+func countingHandler(cacheControl string) (http.HandlerFunc, *atomic.Int32) {
+	var calls atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = fmt.Fprintf(w, "call %d %s", n, r.Header.Get("Accept-Language"))
+	}, &calls
+}
+
+func serveCache(h http.Handler, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+// TestCache_Hit verifies that hits replay the original status and headers,
+// but not the headers of outer middlewares.
+func TestCache_Hit(t *testing.T) {
+	handler, calls := countingHandler("")
+	var id atomic.Int32
+	outer := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-ID", fmt.Sprint(id.Add(1)))
+			next.ServeHTTP(w, r)
+		})
+	}
+	h := outer(middleware.Cache(time.Minute)(handler))
+
+	first := serveCache(h, "/items", nil)
+	second := serveCache(h, "/items", nil)
+	if calls.Load() != 1 {
+		t.Fatalf("Expected the handler to run once, ran %d times", calls.Load())
+	}
+	if first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Unexpected X-Cache headers %q, %q", first.Header().Get("X-Cache"), second.Header().Get("X-Cache"))
+	}
+	if second.Code != http.StatusOK || second.Body.String() != "call 1 " || second.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Unexpected hit %d %q %v", second.Code, second.Body.String(), second.Header())
+	}
+	if second.Header().Get("Age") != "0" || second.Header().Get("X-Request-ID") != "2" {
+		t.Errorf("Unexpected hit headers %v", second.Header())
+	}
+
+	serveCache(h, "/items", map[string]string{"Cache-Control": "no-cache"})
+	if calls.Load() != 2 {
+		t.Error("A no-cache request must refresh the entry")
+	}
+	serveCache(h, "/items", map[string]string{"Cache-Control": "no-store"})
+	if calls.Load() != 3 {
+		t.Error("A no-store request must bypass the cache")
+	}
+}
+
+// TestCache_Key verifies the key components: query, route and parameters.
+func TestCache_Key(t *testing.T) {
+	handler, calls := countingHandler("")
+	h := middleware.Cache(time.Minute)(handler)
+
+	serveCache(h, "/items?b=2&a=1", nil)
+	serveCache(h, "/items?a=1&b=2", nil)
+	if calls.Load() != 1 {
+		t.Error("Equivalent query strings must share an entry")
+	}
+	serveCache(h, "/items?a=2", nil)
+	if calls.Load() != 2 {
+		t.Error("Different query strings must not share an entry")
+	}
+
+	handler, calls = countingHandler("")
+	h = middleware.Cache(time.Minute, middleware.WithCacheQuery("page"))(handler)
+	serveCache(h, "/items?page=1&utm_source=a", nil)
+	serveCache(h, "/items?utm_source=b&page=1", nil)
+	if calls.Load() != 1 {
+		t.Error("Ignored query parameters must not be part of the key")
+	}
+
+	handler, calls = countingHandler("")
+	withRoute := func(next http.Handler, params map[string]string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			state := &adapter.TranswarpState{Params: params, Route: "/users/:id"}
+			next.ServeHTTP(w, r.WithContext(adapter.WithState(r.Context(), state)))
+		})
+	}
+	h = middleware.Cache(time.Minute, middleware.WithCacheParams("id"))(handler)
+	serveCache(withRoute(h, map[string]string{"id": "1", "trace": "x"}), "/users/1", nil)
+	serveCache(withRoute(h, map[string]string{"id": "1", "trace": "y"}), "/users/1", nil)
+	serveCache(withRoute(h, map[string]string{"id": "2"}), "/users/2", nil)
+	if calls.Load() != 2 {
+		t.Errorf("Expected one entry per id, handler ran %d times", calls.Load())
+	}
+}
+
+// TestCache_CacheControl verifies which responses are cached.
+func TestCache_CacheControl(t *testing.T) {
+	cases := []struct {
+		name         string
+		ttl          time.Duration
+		cacheControl string
+		credential   string
+		cached       bool
+	}{
+		{"default ttl", time.Minute, "", "", true},
+		{"no-store", time.Minute, "no-store", "", false},
+		{"no-cache", time.Minute, "no-cache", "", false},
+		{"private", time.Minute, "private, max-age=60", "", false},
+		{"zero ttl", 0, "", "", false},
+		{"max-age", 0, "max-age=60", "", true},
+		{"max-age=0", time.Minute, "max-age=0", "", false},
+		{"s-maxage wins", 0, "max-age=0, s-maxage=60", "", true},
+		{"authorization", time.Minute, "", "Authorization", false},
+		{"public authorization", time.Minute, "public, max-age=60", "Authorization", true},
+		{"cookie", time.Minute, "max-age=60", "Cookie", false},
+		{"public cookie", time.Minute, "public, max-age=60", "Cookie", true},
+	}
+	for _, tc := range cases {
+		handler, calls := countingHandler(tc.cacheControl)
+		h := middleware.Cache(tc.ttl)(handler)
+		headers := map[string]string{}
+		if tc.credential != "" {
+			headers[tc.credential] = "secret"
+		}
+		serveCache(h, "/items", headers)
+		serveCache(h, "/items", headers)
+		if cached := calls.Load() == 1; cached != tc.cached {
+			t.Errorf("%s: expected cached=%v, handler ran %d times", tc.name, tc.cached, calls.Load())
+		}
+	}
+
+	var calls atomic.Int32
+	h := middleware.Cache(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+	}))
+	serveCache(h, "/items", nil)
+	serveCache(h, "/items", nil)
+	if calls.Load() != 2 {
+		t.Error("Responses setting cookies must not be cached")
+	}
+}
+
+// TestCache_Claims verifies that responses to callers authenticated by an
+// earlier middleware are not served to other callers.
+func TestCache_Claims(t *testing.T) {
+	handler, calls := countingHandler("max-age=60")
+	h := middleware.Cache(time.Minute)(handler)
+	serve := func(subject string) {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("X-API-Key", subject)
+		req = req.WithContext(context.WithValue(req.Context(), router.ClaimsKey, map[string]any{"sub": subject}))
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	serve("alice")
+	serve("bob")
+	if calls.Load() != 2 {
+		t.Errorf("Expected personal responses not to be cached, handler ran %d times", calls.Load())
+	}
+}
+
+// TestCache_Vary verifies that variants are cached per Vary header value.
+func TestCache_Vary(t *testing.T) {
+	var calls atomic.Int32
+	h := middleware.Cache(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = io.WriteString(w, "hello "+r.Header.Get("Accept-Language"))
+	}))
+
+	en := serveCache(h, "/greeting", map[string]string{"Accept-Language": "en"})
+	es := serveCache(h, "/greeting", map[string]string{"Accept-Language": "es"})
+	again := serveCache(h, "/greeting", map[string]string{"Accept-Language": "en"})
+	if calls.Load() != 2 {
+		t.Errorf("Expected one call per language, got %d", calls.Load())
+	}
+	if en.Body.String() != "hello en" || es.Body.String() != "hello es" || again.Body.String() != "hello en" {
+		t.Errorf("Variants mixed up: %q %q %q", en.Body.String(), es.Body.String(), again.Body.String())
+	}
+	if again.Header().Get("X-Cache") != "HIT" {
+		t.Error("Expected the second English request to hit the cache")
+	}
+}
+
+// TestCache_Singleflight verifies that concurrent misses run the handler once.
+func TestCache_Singleflight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	h := middleware.Cache(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		<-release
+		_, _ = io.WriteString(w, "slow")
+	}))
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Go(func() {
+			bodies[i] = serveCache(h, "/slow", nil).Body.String()
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected one handler call, got %d", calls.Load())
+	}
+	for i, body := range bodies {
+		if body != "slow" {
+			t.Errorf("Request %d: unexpected body %q", i, body)
+		}
+	}
+}
+
+// expiringStore is a MemoryCacheStore whose entries can be made stale.
+type expiringStore struct {
+	*middleware.MemoryCacheStore
+	mu    sync.Mutex
+	resps []*middleware.CachedResponse
+}
+
+func (s *expiringStore) Set(ctx context.Context, key string, resp *middleware.CachedResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	s.resps = append(s.resps, resp)
+	s.mu.Unlock()
+	return s.MemoryCacheStore.Set(ctx, key, resp, ttl)
+}
+
+func (s *expiringStore) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, resp := range s.resps {
+		resp.Expires = time.Now().Add(-time.Second)
+	}
+}
+
+// TestCache_StaleWhileRevalidate verifies that stale entries are served while
+// they are refreshed in the background.
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	store := &expiringStore{MemoryCacheStore: middleware.NewMemoryCacheStore(10)}
+	handler, calls := countingHandler("max-age=60, stale-while-revalidate=60")
+	h := middleware.Cache(0, middleware.WithCacheStore(store))(handler)
+
+	serveCache(h, "/items", nil)
+	store.expire()
+
+	stale := serveCache(h, "/items", nil)
+	if stale.Header().Get("X-Cache") != "STALE" || stale.Body.String() != "call 1 " {
+		t.Errorf("Expected the stale response, got %q %q", stale.Header().Get("X-Cache"), stale.Body.String())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if fresh := serveCache(h, "/items", nil); fresh.Body.String() != "call 2 " || fresh.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected the refreshed response, got %q %q", fresh.Header().Get("X-Cache"), fresh.Body.String())
+	}
+}
+
+// TestMemoryCacheStore_LRU verifies that the least recently used entry is evicted.
+func TestMemoryCacheStore_LRU(t *testing.T) {
+	ctx := context.Background()
+	store := middleware.NewMemoryCacheStore(2)
+	_ = store.Set(ctx, "a", &middleware.CachedResponse{Status: 200}, time.Minute)
+	_ = store.Set(ctx, "b", &middleware.CachedResponse{Status: 200}, time.Minute)
+	_, _ = store.Get(ctx, "a")
+	_ = store.Set(ctx, "c", &middleware.CachedResponse{Status: 200}, time.Minute)
+
+	if resp, _ := store.Get(ctx, "b"); resp != nil {
+		t.Error("Expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if resp, _ := store.Get(ctx, key); resp == nil {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+	_ = store.Set(ctx, "d", &middleware.CachedResponse{Status: 200}, -time.Second)
+	if resp, _ := store.Get(ctx, "d"); resp != nil {
+		t.Error("Expired entries must not be returned")
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+var _ CacheStore = &MemoryCacheStore{}
+
+// MemoryCacheStore is an in-process CacheStore that evicts the least recently
+// used entry once it holds its maximum number of entries.
+type MemoryCacheStore struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List // front is the most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	resp    *CachedResponse
+	expires time.Time
+}
+
+// NewMemoryCacheStore creates a store holding at most maxEntries responses
+// (1000 when zero or negative).
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &MemoryCacheStore{
+		max:     maxEntries,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get implements CacheStore.
+func (s *MemoryCacheStore) Get(_ context.Context, key string) (*CachedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := el.Value.(*cacheEntry)
+	if !time.Now().Before(entry.expires) {
+		s.order.Remove(el)
+		delete(s.entries, key)
+		return nil, nil
+	}
+	s.order.MoveToFront(el)
+	return entry.resp, nil
+}
+
+// Set implements CacheStore.
+func (s *MemoryCacheStore) Set(_ context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if el, ok := s.entries[key]; ok {
+		el.Value = &cacheEntry{key: key, resp: resp, expires: expires}
+		s.order.MoveToFront(el)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(&cacheEntry{key: key, resp: resp, expires: expires})
+	for s.order.Len() > s.max {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*cacheEntry).key)
+	}
+	return nil
+}
+
+// Len returns the number of entries held, including expired ones not yet evicted.
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
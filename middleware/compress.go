@@ -0,0 +1,423 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings supported by Compress. "deflate" is the zlib format, as
+// defined for HTTP by RFC 9110.
+const (
+	EncodingZstd    = "zstd"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// defaultCompressTypes are the media types compressed by default: text formats
+// that shrink well. Already compressed formats (images, video, archives) are
+// deliberately absent.
+var defaultCompressTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/*+xml",
+	"application/javascript",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// CompressOption configures Compress.
+type CompressOption func(*compressConfig)
+
+// compressConfig holds the settings of a Compress middleware.
+type compressConfig struct {
+	encodings  []string
+	types      []string
+	minSize    int
+	maxRequest int64
+}
+
+// WithCompressEncodings sets the supported encodings in order of server
+// preference, used to break ties between equal client q-values. The default is
+// zstd, gzip, deflate.
+func WithCompressEncodings(encodings ...string) CompressOption {
+	return func(c *compressConfig) {
+		c.encodings = c.encodings[:0]
+		for _, e := range encodings {
+			e = strings.ToLower(e)
+			if e == EncodingZstd || e == EncodingGzip || e == EncodingDeflate {
+				c.encodings = append(c.encodings, e)
+			}
+		}
+	}
+}
+
+// WithCompressTypes replaces the compressible media types. An entry may use
+// "*" for a subtype ("text/*") or a subtype prefix ("application/*+json").
+func WithCompressTypes(types ...string) CompressOption {
+	return func(c *compressConfig) {
+		c.types = types
+	}
+}
+
+// WithCompressMinSize sets the smallest response, in bytes, worth compressing
+// (1024 by default). Smaller responses are sent as is.
+func WithCompressMinSize(n int) CompressOption {
+	return func(c *compressConfig) {
+		c.minSize = max(n, 0)
+	}
+}
+
+// WithCompressMaxRequestSize bounds the decompressed size of request bodies
+// (10 MiB by default); larger bodies are rejected with 413 Content Too Large,
+// protecting against decompression bombs.
+func WithCompressMaxRequestSize(n int64) CompressOption {
+	return func(c *compressConfig) {
+		if n > 0 {
+			c.maxRequest = n
+		}
+	}
+}
+
+// Compress returns a middleware that compresses responses with zstd, gzip or
+// deflate, as negotiated from Accept-Encoding and its q-values. Responses are
+// compressed only when their media type is compressible (see WithCompressTypes),
+// they reach the minimum size, have no Content-Encoding yet and are not partial
+// content. Vary: Accept-Encoding is always set. Streaming handlers may flush at
+// any time; the data written so far is compressed and flushed.
+//
+// Request bodies sent with a Content-Encoding are decompressed as well, and the
+// request state body is replaced so binding middlewares see the plain body.
+// Unsupported encodings are rejected with 415 Unsupported Media Type.
+func Compress(opts ...CompressOption) func(http.Handler) http.Handler {
+	cfg := &compressConfig{
+		encodings:  []string{EncodingZstd, EncodingGzip, EncodingDeflate},
+		types:      defaultCompressTypes,
+		minSize:    1024,
+		maxRequest: 10 << 20,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Content-Encoding") != "" {
+				var ok bool
+				if r, ok = cfg.decompressRequest(w, r); !ok {
+					return
+				}
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := cfg.negotiate(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, cfg: cfg, encoding: encoding}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiate picks the encoding with the highest client q-value, breaking ties
+// with the server preference. It returns "" when only identity is acceptable.
+func (c *compressConfig) negotiate(header string) string {
+	accepted := parseQualityList(header)
+	best, bestQ := "", 0.0
+	for _, encoding := range c.encodings {
+		q, wildcard := -1.0, -1.0
+		for _, v := range accepted {
+			switch v.value {
+			case encoding:
+				if q < 0 {
+					q = v.q
+				}
+			case "*":
+				if wildcard < 0 {
+					wildcard = v.q
+				}
+			case "x-gzip":
+				if encoding == EncodingGzip && q < 0 {
+					q = v.q
+				}
+			}
+		}
+		if q < 0 {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether the media type of contentType is configured
+// for compression.
+func (c *compressConfig) compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, pattern := range c.types {
+		prefix, suffix, wildcard := strings.Cut(strings.ToLower(pattern), "*")
+		if !wildcard {
+			if mediaType == prefix {
+				return true
+			}
+			continue
+		}
+		if len(mediaType) >= len(prefix)+len(suffix) && strings.HasPrefix(mediaType, prefix) && strings.HasSuffix(mediaType, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// errUnsupportedEncoding is returned for request bodies in an unknown coding.
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// decompressRequest replaces the body of r, and of the request state when the
+// adapter has already read it, with its decompressed form. On failure it writes
+// a problem and returns false.
+func (c *compressConfig) decompressRequest(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	state, _ := r.Context().Value(router.StateKey).(*adapter.TranswarpState)
+	var body io.Reader = r.Body
+	if state != nil && state.Body != nil {
+		body = bytes.NewReader(state.Body)
+	}
+
+	plain, err := c.decode(r.Header.Get("Content-Encoding"), body)
+	switch {
+	case errors.Is(err, errUnsupportedEncoding):
+		problem.Write(w, r, problem.New(http.StatusUnsupportedMediaType).
+			WithDetail(fmt.Sprintf("Unsupported Content-Encoding %q", r.Header.Get("Content-Encoding"))))
+		return r, false
+	case errors.Is(err, errBodyTooLarge):
+		problem.Write(w, r, problem.New(http.StatusRequestEntityTooLarge).
+			WithDetail("Decompressed request body exceeds the allowed size"))
+		return r, false
+	case err != nil:
+		problem.Write(w, r, problem.New(http.StatusBadRequest).WithDetail("Invalid compressed request body"))
+		return r, false
+	}
+
+	r = r.Clone(r.Context())
+	r.Header.Del("Content-Encoding")
+	r.Header.Set("Content-Length", strconv.Itoa(len(plain)))
+	r.ContentLength = int64(len(plain))
+	r.Body = io.NopCloser(bytes.NewReader(plain))
+	if state != nil {
+		state.Body = plain
+	}
+	return r, true
+}
+
+// decode decompresses body, applying the codings of a Content-Encoding header
+// in reverse order.
+func (c *compressConfig) decode(header string, body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	codings := strings.Split(header, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		var rc io.ReadCloser
+		switch coding := strings.ToLower(strings.TrimSpace(codings[i])); coding {
+		case "identity", "":
+			continue
+		case EncodingGzip, "x-gzip":
+			rc, err = gzip.NewReader(bytes.NewReader(data))
+		case EncodingDeflate:
+			rc, err = zlib.NewReader(bytes.NewReader(data))
+		case EncodingZstd:
+			var d *zstd.Decoder
+			d, err = zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+			if err == nil {
+				rc = d.IOReadCloser()
+			}
+		default:
+			return nil, errUnsupportedEncoding
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err = io.ReadAll(io.LimitReader(rc, c.maxRequest+1))
+		_ = rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > c.maxRequest {
+			return nil, errBodyTooLarge
+		}
+	}
+	return data, nil
+}
+
+// encoder is the common interface of the pooled compressors.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	EncodingGzip: {New: func() any {
+		return gzip.NewWriter(nil)
+	}},
+	EncodingDeflate: {New: func() any {
+		return zlib.NewWriter(nil)
+	}},
+	EncodingZstd: {New: func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true))
+		return enc
+	}},
+}
+
+// compressWriter buffers the start of a response until it can decide whether
+// to compress it: when the minimum size is reached, the handler flushes or the
+// handler returns.
+type compressWriter struct {
+	http.ResponseWriter
+	cfg      *compressConfig
+	encoding string
+	status   int
+	buf      []byte
+	decided  bool
+	enc      encoder
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		// Informational responses such as 103 Early Hints are sent right away.
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = code
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.cfg.minSize {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// decide writes the response header, compressed if allowed and wanted, and
+// then the buffered data.
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 && h.Get("X-Content-Type-Options") != "nosniff" {
+		// Sniff now: net/http would otherwise sniff the compressed bytes.
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	if compress && cw.eligible() {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			// The compressed representation is no longer byte-identical.
+			h.Set("ETag", "W/"+etag)
+		}
+		cw.enc = encoderPools[cw.encoding].Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// eligible reports whether the response may be compressed.
+func (cw *compressWriter) eligible() bool {
+	switch {
+	case cw.status < 200, cw.status == http.StatusNoContent,
+		cw.status == http.StatusNotModified, cw.status == http.StatusPartialContent:
+		return false
+	}
+	h := cw.Header()
+	return h.Get("Content-Encoding") == "" && cw.cfg.compressible(h.Get("Content-Type"))
+}
+
+// close finishes the response once the handler has returned.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			// Nothing was written (or the connection was hijacked).
+			return
+		}
+		_ = cw.decide(false)
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+		cw.enc.Reset(nil)
+		encoderPools[cw.encoding].Put(cw.enc)
+		cw.enc = nil
+	}
+}
+
+// Flush implements http.Flusher: buffered data is compressed (if eligible) and
+// sent immediately, which keeps server-sent events and other streams working.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide(true)
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker for WebSocket upgrades.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,226 @@
+package middleware_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+)
+
+var compressBody = strings.Repeat(`{"name":"transwarp","tags":["fast","small"]},`, 100)
+
+// serveCompress sends a GET with the given Accept-Encoding through Compress to
+// handler.
+func serveCompress(t *testing.T, acceptEncoding string, handler http.HandlerFunc, opts ...middleware.CompressOption) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rr := httptest.NewRecorder()
+	middleware.Compress(opts...)(handler).ServeHTTP(rr, req)
+	return rr
+}
+
+func jsonHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", "999")
+	_, _ = io.WriteString(w, compressBody)
+}
+
+// decompress decodes body according to encoding.
+func decompress(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	var err error
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	case "zstd":
+		r, err = zstd.NewReader(bytes.NewReader(body))
+	default:
+		return string(body)
+	}
+	if err != nil {
+		t.Fatalf("Invalid %s stream: %v", encoding, err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Invalid %s stream: %v", encoding, err)
+	}
+	return string(out)
+}
+
+// TestCompress_Negotiation verifies that the encoding follows the client
+// q-values, then the server preference.
+func TestCompress_Negotiation(t *testing.T) {
+	cases := map[string]string{
+		"gzip":                        "gzip",
+		"gzip, deflate":               "gzip",
+		"deflate":                     "deflate",
+		"gzip, deflate, br, zstd":     "zstd",
+		"gzip;q=0.5, zstd;q=0.8":      "zstd",
+		"zstd;q=0.2, gzip":            "gzip",
+		"*;q=0.1, zstd;q=0, gzip;q=0": "deflate",
+		"identity":                    "",
+		"":                            "",
+		"br":                          "",
+	}
+	for accept, want := range cases {
+		rr := serveCompress(t, accept, jsonHandler)
+		if got := rr.Header().Get("Content-Encoding"); got != want {
+			t.Errorf("%q: expected encoding %q, got %q", accept, want, got)
+			continue
+		}
+		if got := decompress(t, want, rr.Body.Bytes()); got != compressBody {
+			t.Errorf("%q: body does not round-trip", accept)
+		}
+		if rr.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%q: expected Vary: Accept-Encoding, got %q", accept, rr.Header().Get("Vary"))
+		}
+		if want != "" && rr.Header().Get("Content-Length") != "" {
+			t.Errorf("%q: Content-Length must be removed from compressed responses", accept)
+		}
+	}
+}
+
+// TestCompress_Skips verifies that small, incompressible and already encoded
+// responses are sent as is.
+func TestCompress_Skips(t *testing.T) {
+	cases := map[string]http.HandlerFunc{
+		"small": func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"ok":true}`)
+		},
+		"image": func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = io.WriteString(w, compressBody)
+		},
+		"encoded": func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = io.WriteString(w, compressBody)
+		},
+		"no content": func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		},
+	}
+	for name, handler := range cases {
+		rr := serveCompress(t, "gzip", handler)
+		if enc := rr.Header().Get("Content-Encoding"); enc == "gzip" {
+			t.Errorf("%s: response must not be compressed", name)
+		}
+	}
+
+	rr := serveCompress(t, "gzip", cases["small"], middleware.WithCompressMinSize(0))
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Error("A zero minimum size must compress small responses")
+	}
+	rr = serveCompress(t, "gzip", cases["image"], middleware.WithCompressTypes("image/*"))
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Error("Custom types must replace the defaults")
+	}
+}
+
+// TestCompress_SniffAndETag verifies that untyped bodies are sniffed before
+// compression and that strong ETags become weak.
+func TestCompress_SniffAndETag(t *testing.T) {
+	rr := serveCompress(t, "gzip", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, "<html><body>"+compressBody+"</body></html>")
+	})
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected 201, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected a sniffed text/html type, got %q", ct)
+	}
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("Expected a gzip response")
+	}
+	if got := rr.Header().Get("ETag"); got != `W/"v1"` {
+		t.Errorf("Expected a weak ETag, got %q", got)
+	}
+}
+
+// TestCompress_Flush verifies that flushed data reaches the client compressed
+// before the handler returns.
+func TestCompress_Flush(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	middleware.Compress()(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+
+		if !rr.Flushed || rr.Body.Len() == 0 {
+			t.Error("Expected the first event to be flushed")
+		}
+		r, err := gzip.NewReader(bytes.NewReader(rr.Body.Bytes()))
+		if err != nil {
+			t.Fatalf("Invalid gzip stream: %v", err)
+		}
+		buf := make([]byte, 16)
+		n, _ := r.Read(buf)
+		if string(buf[:n]) != "data: 1\n\n" {
+			t.Errorf("Unexpected flushed data %q", buf[:n])
+		}
+		_, _ = io.WriteString(w, "data: 2\n\n")
+	})).ServeHTTP(rr, req)
+
+	if got := decompress(t, "gzip", rr.Body.Bytes()); got != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("Unexpected stream %q", got)
+	}
+}
+
+// TestCompress_RequestBody verifies that compressed request bodies are
+// decompressed, including the body cached in the request state.
+func TestCompress_RequestBody(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, _ = io.WriteString(zw, compressBody)
+	_ = zw.Close()
+
+	send := func(encoding string, body []byte, opts ...middleware.CompressOption) (*httptest.ResponseRecorder, string, string) {
+		req := httptest.NewRequest(http.MethodPost, "/items", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", encoding)
+		state := &adapter.TranswarpState{Params: map[string]string{}, Body: body}
+		req = req.WithContext(adapter.WithState(req.Context(), state))
+
+		var read string
+		rr := httptest.NewRecorder()
+		middleware.Compress(opts...)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			read = string(b)
+		})).ServeHTTP(rr, req)
+		return rr, read, string(state.Body)
+	}
+
+	rr, read, cached := send("gzip", compressed.Bytes())
+	if rr.Code != http.StatusOK || read != compressBody || cached != compressBody {
+		t.Errorf("Expected the body to be decompressed: %d %q", rr.Code, read)
+	}
+
+	if rr, _, _ := send("br", compressed.Bytes()); rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected 415 for unsupported encodings, got %d", rr.Code)
+	}
+	if rr, _, _ := send("gzip", []byte("not gzip")); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for corrupt bodies, got %d", rr.Code)
+	}
+	if rr, _, _ := send("gzip", compressed.Bytes(), middleware.WithCompressMaxRequestSize(64)); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for oversized bodies, got %d", rr.Code)
+	}
+}
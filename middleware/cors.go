@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures CORS. Origins are compared case-insensitively and
+// must include the scheme, e.g. "https://app.example.com".
+type CORSConfig struct {
+	// AllowOrigins lists the allowed origins. An entry may be an exact origin,
+	// a wildcard subdomain ("https://*.example.com", which does not match the
+	// bare domain) or "*" for any origin.
+	AllowOrigins []string
+	// AllowOriginFunc, when set, is consulted for origins not matched by
+	// AllowOrigins.
+	AllowOriginFunc func(origin string, r *http.Request) bool
+	// AllowMethods lists the methods allowed in preflight requests. Defaults to
+	// GET, HEAD, POST, PUT, PATCH and DELETE.
+	AllowMethods []string
+	// AllowHeaders lists the request headers allowed in preflight requests
+	// ("*" for any). When empty, the headers requested by the browser are allowed.
+	AllowHeaders []string
+	// ExposeHeaders lists the response headers readable by the browser.
+	ExposeHeaders []string
+	// AllowCredentials allows cookies and HTTP authentication. The origin is
+	// then always echoed, since browsers reject "*" with credentials. It cannot
+	// be combined with the "*" origin, which would let any website read
+	// responses on behalf of the user; use AllowOriginFunc to decide
+	// dynamically instead.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response. Zero omits
+	// the header (browser default); a negative value disables caching.
+	MaxAge time.Duration
+	// AllowPrivateNetwork answers Private Network Access preflights, letting
+	// public websites reach the API on a private network or localhost.
+	AllowPrivateNetwork bool
+}
+
+// cors is a compiled CORSConfig.
+type cors struct {
+	cfg       CORSConfig
+	anyOrigin bool
+	exact     []string
+	wildcards [][2]string // scheme + "://" and ".domain" parts of "scheme://*.domain"
+	methods   []string
+	headers   []string
+	anyHeader bool
+	expose    string
+	maxAge    string
+}
+
+// CORS returns a middleware implementing Cross-Origin Resource Sharing. It
+// answers preflight requests (OPTIONS with Access-Control-Request-Method) itself
+// with 204 No Content, and adds the CORS headers to actual requests from allowed
+// origins. Requests from other origins are served without CORS headers, so the
+// browser blocks them.
+//
+// Register it with Transwarp.Pre so that preflight requests are answered before
+// routing, whether or not an OPTIONS route exists, on every adapter:
+//
+//	app.Pre(middleware.CORS(middleware.CORSConfig{
+//		AllowOrigins: []string{"https://app.example.com", "https://*.example.com"},
+//	}))
+func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+	c := newCORS(cfg)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				c.preflight(w, r)
+				return
+			}
+			c.actual(w, r)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newCORS(cfg CORSConfig) *cors {
+	c := &cors{cfg: cfg}
+	for _, origin := range cfg.AllowOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		switch {
+		case origin == "*":
+			c.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			scheme, domain, _ := strings.Cut(origin, "*")
+			c.wildcards = append(c.wildcards, [2]string{scheme, domain})
+		default:
+			c.exact = append(c.exact, origin)
+		}
+	}
+	if c.anyOrigin && cfg.AllowCredentials {
+		panic(`transwarp: CORS cannot allow credentials for any origin ("*"); list the origins or use AllowOriginFunc`)
+	}
+
+	methods := cfg.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{
+			http.MethodGet, http.MethodHead, http.MethodPost,
+			http.MethodPut, http.MethodPatch, http.MethodDelete,
+		}
+	}
+	upper := make([]string, len(methods))
+	for i, m := range methods {
+		upper[i] = strings.ToUpper(m)
+	}
+	c.methods = upper
+
+	for _, h := range cfg.AllowHeaders {
+		if h == "*" {
+			c.anyHeader = true
+			continue
+		}
+		c.headers = append(c.headers, strings.ToLower(h))
+	}
+	if len(cfg.AllowHeaders) == 0 {
+		c.anyHeader = true
+	}
+
+	if len(cfg.ExposeHeaders) > 0 {
+		c.expose = strings.Join(cfg.ExposeHeaders, ", ")
+	}
+	switch {
+	case cfg.MaxAge > 0:
+		c.maxAge = strconv.Itoa(int(cfg.MaxAge / time.Second))
+	case cfg.MaxAge < 0:
+		c.maxAge = "0"
+	}
+	return c
+}
+
+// originAllowed reports whether origin may access the resource.
+func (c *cors) originAllowed(origin string, r *http.Request) bool {
+	if c.anyOrigin {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if slices.Contains(c.exact, lower) {
+		return true
+	}
+	for _, wc := range c.wildcards {
+		if len(lower) > len(wc[0])+len(wc[1]) && strings.HasPrefix(lower, wc[0]) && strings.HasSuffix(lower, wc[1]) &&
+			isSubdomain(lower[len(wc[0]):len(lower)-len(wc[1])]) {
+			return true
+		}
+	}
+	return c.cfg.AllowOriginFunc != nil && c.cfg.AllowOriginFunc(origin, r)
+}
+
+// allowOrigin writes Access-Control-Allow-Origin and the credentials flag.
+func (c *cors) allowOrigin(h http.Header, origin string) {
+	if c.anyOrigin && !c.cfg.AllowCredentials && c.cfg.AllowOriginFunc == nil {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if c.cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// varyOnOrigin reports whether responses depend on the Origin header.
+func (c *cors) varyOnOrigin() bool {
+	return !c.anyOrigin || c.cfg.AllowCredentials || c.cfg.AllowOriginFunc != nil
+}
+
+// actual adds the CORS headers of a simple or actual request.
+func (c *cors) actual(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	if c.varyOnOrigin() {
+		h.Add("Vary", "Origin")
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" || !c.originAllowed(origin, r) {
+		return
+	}
+	c.allowOrigin(h, origin)
+	if c.expose != "" {
+		h.Set("Access-Control-Expose-Headers", c.expose)
+	}
+}
+
+// preflight answers a preflight request. Disallowed requests get a 204 without
+// CORS headers, which makes the browser fail the actual request.
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	if c.cfg.AllowPrivateNetwork {
+		h.Add("Vary", "Access-Control-Request-Private-Network")
+	}
+	defer w.WriteHeader(http.StatusNoContent)
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || !c.originAllowed(origin, r) {
+		return
+	}
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !slices.Contains(c.methods, method) {
+		return
+	}
+	requested := r.Header.Get("Access-Control-Request-Headers")
+	if requested != "" && !c.anyHeader {
+		for _, name := range strings.Split(requested, ",") {
+			if !slices.Contains(c.headers, strings.ToLower(strings.TrimSpace(name))) {
+				return
+			}
+		}
+	}
+
+	c.allowOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+	if requested != "" {
+		// Echo the requested headers: "*" is not a wildcard with credentials.
+		h.Set("Access-Control-Allow-Headers", requested)
+	}
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	if c.cfg.AllowPrivateNetwork && r.Header.Get("Access-Control-Request-Private-Network") == "true" {
+		h.Set("Access-Control-Allow-Private-Network", "true")
+	}
+}
+
+// isSubdomain reports whether s only contains host name characters, so a
+// wildcard cannot match a port, credentials or another host.
+func isSubdomain(s string) bool {
+	for i := range len(s) {
+		ch := s[i]
+		if (ch < 'a' || ch > 'z') && (ch < '0' || ch > '9') && ch != '-' && ch != '.' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,156 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iaconlabs/transwarp/middleware"
+)
+
+// serveCORS sends a request with the given headers through a CORS middleware
+// wrapping a handler that answers 200.
+func serveCORS(cfg middleware.CORSConfig, method string, headers map[string]string) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	h := middleware.CORS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(method, "/api/users", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr, reached
+}
+
+// TestCORS_Origins verifies exact, wildcard subdomain and function origins.
+func TestCORS_Origins(t *testing.T) {
+	cfg := middleware.CORSConfig{
+		AllowOrigins: []string{"https://app.example.com", "https://*.example.org"},
+		AllowOriginFunc: func(origin string, _ *http.Request) bool {
+			return strings.HasSuffix(origin, ".internal:8443")
+		},
+	}
+
+	cases := map[string]bool{
+		"https://app.example.com":       true,
+		"https://APP.example.com":       true,
+		"https://a.b.example.org":       true,
+		"https://example.org":           false,
+		"http://a.example.org":          false,
+		"https://a.example.org.evil":    false,
+		"https://evil.com:.example.org": false,
+		"https://svc.internal:8443":     true,
+		"https://other.com":             false,
+	}
+	for origin, allowed := range cases {
+		rr, reached := serveCORS(cfg, http.MethodGet, map[string]string{"Origin": origin})
+		if !reached {
+			t.Errorf("%s: actual requests must reach the handler", origin)
+		}
+		got := rr.Header().Get("Access-Control-Allow-Origin")
+		if allowed && got != origin {
+			t.Errorf("%s: expected the origin to be allowed, got %q", origin, got)
+		}
+		if !allowed && got != "" {
+			t.Errorf("%s: expected no CORS headers, got %q", origin, got)
+		}
+		if rr.Header().Get("Vary") != "Origin" {
+			t.Errorf("%s: expected Vary: Origin, got %q", origin, rr.Header().Get("Vary"))
+		}
+	}
+}
+
+// TestCORS_Preflight verifies that preflight requests are answered without
+// reaching the handler, with methods, headers, max-age and private network access.
+func TestCORS_Preflight(t *testing.T) {
+	cfg := middleware.CORSConfig{
+		AllowOrigins:        []string{"https://app.example.com"},
+		AllowMethods:        []string{"GET", "POST"},
+		AllowHeaders:        []string{"Content-Type", "Authorization"},
+		MaxAge:              10 * time.Minute,
+		AllowPrivateNetwork: true,
+	}
+
+	rr, reached := serveCORS(cfg, http.MethodOptions, map[string]string{
+		"Origin":                                 "https://app.example.com",
+		"Access-Control-Request-Method":          "POST",
+		"Access-Control-Request-Headers":         "content-type, authorization",
+		"Access-Control-Request-Private-Network": "true",
+	})
+	if reached {
+		t.Error("Preflight requests must not reach the handler")
+	}
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", rr.Code)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":          "https://app.example.com",
+		"Access-Control-Allow-Methods":         "GET, POST",
+		"Access-Control-Allow-Headers":         "content-type, authorization",
+		"Access-Control-Max-Age":               "600",
+		"Access-Control-Allow-Private-Network": "true",
+	}
+	for k, v := range want {
+		if got := rr.Header().Get(k); got != v {
+			t.Errorf("%s: expected %q, got %q", k, v, got)
+		}
+	}
+
+	// Disallowed methods or headers get no CORS headers.
+	for _, headers := range []map[string]string{
+		{"Origin": "https://app.example.com", "Access-Control-Request-Method": "DELETE"},
+		{"Origin": "https://app.example.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Secret"},
+		{"Origin": "https://evil.com", "Access-Control-Request-Method": "GET"},
+	} {
+		rr, _ := serveCORS(cfg, http.MethodOptions, headers)
+		if rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("%v: expected a 204 without CORS headers, got %d %v", headers, rr.Code, rr.Header())
+		}
+	}
+}
+
+// TestCORS_Credentials verifies that "*" is never combined with credentials and
+// that exposed headers are listed on actual requests.
+func TestCORS_Credentials(t *testing.T) {
+	rr, _ := serveCORS(middleware.CORSConfig{AllowOrigins: []string{"*"}}, http.MethodGet,
+		map[string]string{"Origin": "https://any.com"})
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected *, got %q", got)
+	}
+	if rr.Header().Get("Vary") != "" {
+		t.Errorf("A wildcard response does not vary on Origin, got %q", rr.Header().Get("Vary"))
+	}
+
+	cfg := middleware.CORSConfig{
+		AllowOriginFunc:  func(origin string, _ *http.Request) bool { return strings.HasSuffix(origin, ".any.com") },
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Request-ID", "ETag"},
+	}
+	rr, _ = serveCORS(cfg, http.MethodGet, map[string]string{"Origin": "https://app.any.com"})
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://app.any.com" {
+		t.Errorf("Expected the origin to be echoed with credentials, got %q", got)
+	}
+	if rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("Expected Access-Control-Allow-Credentials: true")
+	}
+	if got := rr.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-ID, ETag" {
+		t.Errorf("Unexpected exposed headers: %q", got)
+	}
+}
+
+// TestCORS_CredentialsAnyOrigin verifies that credentials cannot be allowed for
+// any origin.
+func TestCORS_CredentialsAnyOrigin(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected credentials with any origin to panic")
+		}
+	}()
+	middleware.CORS(middleware.CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
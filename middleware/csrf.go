@@ -0,0 +1,450 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/internal/payload"
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// Default names used by CSRF.
+const (
+	// CSRFHeader is the default header carrying the token of JavaScript requests.
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFField is the default form field carrying the token of HTML forms.
+	CSRFField = "csrf_token"
+	// CSRFCookie is the default name of the double-submit cookie.
+	CSRFCookie = "_csrf"
+)
+
+// csrfTokenLength is the size in bytes of CSRF tokens.
+const csrfTokenLength = 32
+
+// CSRFStore keeps the synchronizer token of each session, e.g. in memory (see
+// MemoryCSRFStore) or next to the session data to share it between instances.
+type CSRFStore interface {
+	// Get returns the token of session, or "" when it has none.
+	Get(ctx context.Context, session string) (string, error)
+	// Set stores the token of session, valid for ttl.
+	Set(ctx context.Context, session, token string, ttl time.Duration) error
+}
+
+// CSRFOption configures CSRF.
+type CSRFOption func(*csrfConfig)
+
+// csrfConfig holds the settings of a CSRF middleware.
+type csrfConfig struct {
+	cookie   http.Cookie
+	header   string
+	field    string
+	maxAge   time.Duration
+	origins  []string
+	exempt   []string
+	exemptFn func(r *http.Request) bool
+	session  func(r *http.Request) string
+	store    CSRFStore
+}
+
+// WithCSRFCookie replaces the attributes of the double-submit cookie: Name,
+// Path, Domain, Secure, HttpOnly and SameSite are taken from c. The default is
+// a "_csrf" cookie for path "/" with Secure and SameSite=Lax, readable by
+// JavaScript so that single-page apps can echo it in the X-CSRF-Token header.
+// A name with the "__Host-" prefix also protects it from subdomains.
+func WithCSRFCookie(c http.Cookie) CSRFOption {
+	return func(cfg *csrfConfig) {
+		if c.Name != "" {
+			cfg.cookie = http.Cookie{
+				Name: c.Name, Path: c.Path, Domain: c.Domain,
+				Secure: c.Secure, HttpOnly: c.HttpOnly, SameSite: c.SameSite,
+			}
+		}
+	}
+}
+
+// WithCSRFHeader sets the header read for the token (X-CSRF-Token by default).
+func WithCSRFHeader(name string) CSRFOption {
+	return func(c *csrfConfig) {
+		if name != "" {
+			c.header = http.CanonicalHeaderKey(name)
+		}
+	}
+}
+
+// WithCSRFField sets the form field read for the token (csrf_token by default).
+func WithCSRFField(name string) CSRFOption {
+	return func(c *csrfConfig) {
+		if name != "" {
+			c.field = name
+		}
+	}
+}
+
+// WithCSRFMaxAge sets how long a token stays valid (12 hours by default).
+func WithCSRFMaxAge(d time.Duration) CSRFOption {
+	return func(c *csrfConfig) {
+		if d > 0 {
+			c.maxAge = d
+		}
+	}
+}
+
+// WithCSRFTrustedOrigins accepts unsafe requests from other origins, such as
+// "https://admin.example.com", besides the origin of the request itself.
+func WithCSRFTrustedOrigins(origins ...string) CSRFOption {
+	return func(c *csrfConfig) {
+		for _, origin := range origins {
+			c.origins = append(c.origins, strings.ToLower(strings.TrimSuffix(origin, "/")))
+		}
+	}
+}
+
+// WithCSRFExempt skips the protection for routes matching patterns: route
+// patterns as registered, including group prefixes (e.g. "/webhooks/stripe"
+// or "/orgs/:id/hooks"), or a group prefix followed by "/*" for every route of
+// the group (e.g. "/api/*").
+func WithCSRFExempt(patterns ...string) CSRFOption {
+	return func(c *csrfConfig) {
+		c.exempt = append(c.exempt, patterns...)
+	}
+}
+
+// WithCSRFExemptFunc skips the protection for requests for which fn returns
+// true, e.g. requests authenticated with a bearer token rather than cookies.
+func WithCSRFExemptFunc(fn func(r *http.Request) bool) CSRFOption {
+	return func(c *csrfConfig) {
+		c.exemptFn = fn
+	}
+}
+
+// WithCSRFSynchronizer switches to the synchronizer-token pattern: the token
+// of each session is kept in store (a private MemoryCSRFStore when nil)
+// instead of a cookie. session identifies the session of a request, typically
+// from the session cookie or the authenticated user; requests for which it
+// returns "" get no token, so their unsafe requests are rejected.
+func WithCSRFSynchronizer(session func(r *http.Request) string, store CSRFStore) CSRFOption {
+	return func(c *csrfConfig) {
+		c.session = session
+		c.store = store
+	}
+}
+
+// CSRF returns a middleware protecting cookie-authenticated browser routes
+// against cross-site request forgery. Every request gets a token, available to
+// handlers and templates through CSRFToken and CSRFTemplateField. Unsafe
+// requests (other than GET, HEAD, OPTIONS and TRACE) are rejected with 403
+// Forbidden unless:
+//
+//   - their Origin header, or else their Referer, is the origin of the request
+//     or a trusted one (requests carrying neither rely on the token alone), and
+//   - they carry the token in the X-CSRF-Token header or, for HTML forms, in
+//     the csrf_token field of a urlencoded or multipart body.
+//
+// By default the token lives in a cookie (the double-submit cookie pattern);
+// see WithCSRFSynchronizer to keep it server-side instead. Tokens handed out
+// are masked anew on every call, so they do not leak through compression
+// side channels such as BREACH.
+//
+// The form field is read from the body cached in the request state, so
+// handlers can still read the body. Register CSRF with Use rather than
+// Transwarp.Pre, since it needs the state and the matched route.
+func CSRF(opts ...CSRFOption) func(http.Handler) http.Handler {
+	cfg := &csrfConfig{
+		cookie: http.Cookie{Name: CSRFCookie, Path: "/", Secure: true, SameSite: http.SameSiteLaxMode},
+		header: CSRFHeader,
+		field:  CSRFField,
+		maxAge: 12 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.session != nil && cfg.store == nil {
+		cfg.store = NewMemoryCSRFStore()
+	}
+
+	return func(next http.Handler) http.Handler {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.exempted(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// Responses may embed the token, which depends on the cookies.
+			w.Header().Add("Vary", "Cookie")
+
+			token, err := cfg.token(w, r)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "transwarp: CSRF store failed", "error", err)
+				problem.Write(w, r, problem.New(http.StatusServiceUnavailable))
+				return
+			}
+			if token != nil {
+				r = r.WithContext(context.WithValue(r.Context(), router.CSRFKey, &csrfToken{raw: token, field: cfg.field}))
+			}
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !cfg.sameOrigin(r) {
+				problem.Write(w, r, problem.New(http.StatusForbidden).
+					WithDetail("Cross-origin request rejected"))
+				return
+			}
+			if token == nil || !validCSRFToken(token, cfg.submittedToken(r)) {
+				problem.Write(w, r, problem.New(http.StatusForbidden).
+					WithDetail("The CSRF token is missing or invalid"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+		return &describedHandler{Handler: handler, describe: func(d *router.RouteDescription) {
+			d.AddError(http.StatusForbidden)
+		}}
+	}
+}
+
+// exempted reports whether r skips the protection.
+func (c *csrfConfig) exempted(r *http.Request) bool {
+	if c.exemptFn != nil && c.exemptFn(r) {
+		return true
+	}
+	if len(c.exempt) == 0 {
+		return false
+	}
+	pattern := adapter.RoutePattern(r)
+	for _, e := range c.exempt {
+		if prefix, ok := strings.CutSuffix(e, "/*"); ok {
+			if pattern == prefix || strings.HasPrefix(pattern, prefix+"/") {
+				return true
+			}
+		} else if pattern == e {
+			return true
+		}
+	}
+	return false
+}
+
+// token returns the raw token of r, issuing a new one when it has none. It
+// returns nil in synchronizer mode for requests without a session.
+func (c *csrfConfig) token(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	if c.session == nil {
+		if cookie, err := r.Cookie(c.cookie.Name); err == nil {
+			if raw, err := base64.RawURLEncoding.DecodeString(cookie.Value); err == nil && len(raw) == csrfTokenLength {
+				return raw, nil
+			}
+		}
+		raw := newCSRFToken()
+		cookie := c.cookie
+		cookie.Value = base64.RawURLEncoding.EncodeToString(raw)
+		cookie.MaxAge = int(c.maxAge.Seconds())
+		http.SetCookie(w, &cookie)
+		return raw, nil
+	}
+
+	session := c.session(r)
+	if session == "" {
+		return nil, nil
+	}
+	stored, err := c.store.Get(r.Context(), session)
+	if err != nil {
+		return nil, err
+	}
+	if raw, err := base64.RawURLEncoding.DecodeString(stored); err == nil && len(raw) == csrfTokenLength {
+		return raw, nil
+	}
+	raw := newCSRFToken()
+	if err := c.store.Set(r.Context(), session, base64.RawURLEncoding.EncodeToString(raw), c.maxAge); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// sameOrigin checks the Origin header, or the Referer when it is absent,
+// against the origin of the request and the trusted origins.
+func (c *csrfConfig) sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			return true
+		}
+		u, err := url.Parse(referer)
+		if err != nil || u.Host == "" {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+	origin = strings.ToLower(origin)
+	if slices.Contains(c.origins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" || !strings.EqualFold(u.Host, r.Host) {
+		return false
+	}
+	return r.TLS == nil || u.Scheme == "https"
+}
+
+// submittedToken returns the token sent in the header or, for form bodies, in
+// the form field.
+func (c *csrfConfig) submittedToken(r *http.Request) string {
+	if token := r.Header.Get(c.header); token != "" {
+		return token
+	}
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" && mediaType != "multipart/form-data" {
+		return ""
+	}
+	body, _ := payload.Body(r)
+
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, _ := url.ParseQuery(string(body))
+		return values.Get(c.field)
+	}
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return ""
+		}
+		if part.FormName() == c.field && part.FileName() == "" {
+			value, _ := io.ReadAll(io.LimitReader(part, 256))
+			return string(value)
+		}
+	}
+}
+
+// newCSRFToken returns a random token.
+func newCSRFToken() []byte {
+	raw := make([]byte, csrfTokenLength)
+	_, _ = rand.Read(raw)
+	return raw
+}
+
+// maskCSRFToken encodes raw XORed with a random one-time pad, followed by
+// the pad, so the token sent to the client differs on every response.
+func maskCSRFToken(raw []byte) string {
+	masked := make([]byte, 2*len(raw))
+	pad := masked[len(raw):]
+	_, _ = rand.Read(pad)
+	for i := range raw {
+		masked[i] = raw[i] ^ pad[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+// validCSRFToken reports whether submitted, masked or as stored in the
+// cookie, matches raw.
+func validCSRFToken(raw []byte, submitted string) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(submitted)
+	if err != nil {
+		return false
+	}
+	switch len(decoded) {
+	case csrfTokenLength:
+	case 2 * csrfTokenLength:
+		pad := decoded[csrfTokenLength:]
+		decoded = decoded[:csrfTokenLength]
+		for i := range decoded {
+			decoded[i] ^= pad[i]
+		}
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare(raw, decoded) == 1
+}
+
+// csrfToken is the token of a request, stored under router.CSRFKey.
+type csrfToken struct {
+	raw   []byte
+	field string
+}
+
+// CSRFToken returns the CSRF token of r for a form field, a meta tag or a
+// JavaScript client to send back. It returns "" when CSRF did not run or, in
+// synchronizer mode, the request has no session.
+func CSRFToken(r *http.Request) string {
+	t, ok := r.Context().Value(router.CSRFKey).(*csrfToken)
+	if !ok {
+		return ""
+	}
+	return maskCSRFToken(t.raw)
+}
+
+// CSRFTemplateField returns a hidden form input carrying the CSRF token of r,
+// for html/template:
+//
+//	tmpl.Execute(w, map[string]any{"CSRFField": middleware.CSRFTemplateField(r)})
+//	// <form method="post">{{ .CSRFField }} ... </form>
+func CSRFTemplateField(r *http.Request) template.HTML {
+	t, ok := r.Context().Value(router.CSRFKey).(*csrfToken)
+	if !ok {
+		return ""
+	}
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(t.field) +
+		`" value="` + maskCSRFToken(t.raw) + `">`)
+}
+
+var _ CSRFStore = &MemoryCSRFStore{}
+
+// MemoryCSRFStore is an in-process CSRFStore. Expired tokens are removed lazily.
+type MemoryCSRFStore struct {
+	mu        sync.Mutex
+	tokens    map[string]csrfEntry
+	nextSweep time.Time
+}
+
+type csrfEntry struct {
+	token   string
+	expires time.Time
+}
+
+// NewMemoryCSRFStore creates an empty store.
+func NewMemoryCSRFStore() *MemoryCSRFStore {
+	return &MemoryCSRFStore{tokens: make(map[string]csrfEntry)}
+}
+
+// Get implements CSRFStore.
+func (s *MemoryCSRFStore) Get(_ context.Context, session string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.tokens[session]; ok && time.Now().Before(e.expires) {
+		return e.token, nil
+	}
+	return "", nil
+}
+
+// Set implements CSRFStore.
+func (s *MemoryCSRFStore) Set(_ context.Context, session, token string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.After(s.nextSweep) {
+		for k, e := range s.tokens {
+			if !now.Before(e.expires) {
+				delete(s.tokens, k)
+			}
+		}
+		s.nextSweep = now.Add(time.Minute)
+	}
+	s.tokens[session] = csrfEntry{token: token, expires: now.Add(ttl)}
+	return nil
+}
@@ -0,0 +1,182 @@
+package middleware_test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
+)
+
+// csrfHandler echoes the token and the request body.
+var csrfHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("X-Token", middleware.CSRFToken(r))
+	_, _ = w.Write(body)
+})
+
+// serveCSRF sends a request with the given body and header pairs through h.
+func serveCSRF(h http.Handler, method, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/account", strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+// csrfCookie returns the "name=value" of the cookie set by rr.
+func csrfCookie(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == middleware.CSRFCookie {
+			return c.Name + "=" + c.Value
+		}
+	}
+	t.Fatal("Expected a CSRF cookie")
+	return ""
+}
+
+// TestCSRF_DoubleSubmit verifies the cookie mode with header tokens.
+func TestCSRF_DoubleSubmit(t *testing.T) {
+	h := middleware.CSRF()(csrfHandler)
+
+	get := serveCSRF(h, http.MethodGet, "")
+	cookie, token := csrfCookie(t, get), get.Header().Get("X-Token")
+	if get.Code != http.StatusOK || token == "" {
+		t.Fatalf("Expected GET to pass with a token, got %d %q", get.Code, token)
+	}
+	if again := serveCSRF(h, http.MethodGet, "", "Cookie", cookie); again.Header().Get("X-Token") == token ||
+		len(again.Result().Cookies()) != 0 {
+		t.Error("Expected the cookie to be reused and the token to be masked anew")
+	}
+
+	cases := []struct {
+		name    string
+		headers []string
+		want    int
+	}{
+		{"valid", []string{"Cookie", cookie, "X-CSRF-Token", token}, http.StatusOK},
+		{"missing token", []string{"Cookie", cookie}, http.StatusForbidden},
+		{"missing cookie", []string{"X-CSRF-Token", token}, http.StatusForbidden},
+		{"wrong token", []string{"Cookie", cookie, "X-CSRF-Token", "bogus"}, http.StatusForbidden},
+		{"same origin", []string{"Cookie", cookie, "X-CSRF-Token", token, "Origin", "http://example.com"}, http.StatusOK},
+		{"cross origin", []string{"Cookie", cookie, "X-CSRF-Token", token, "Origin", "https://evil.test"}, http.StatusForbidden},
+		{"null origin", []string{"Cookie", cookie, "X-CSRF-Token", token, "Origin", "null"}, http.StatusForbidden},
+		{"cross referer", []string{"Cookie", cookie, "X-CSRF-Token", token, "Referer", "https://evil.test/form"}, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		if rr := serveCSRF(h, http.MethodPost, "", tc.headers...); rr.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, rr.Code)
+		}
+	}
+
+	trusted := middleware.CSRF(middleware.WithCSRFTrustedOrigins("https://admin.example.com/"))(csrfHandler)
+	if rr := serveCSRF(trusted, http.MethodPost, "", "Cookie", cookie, "X-CSRF-Token", token,
+		"Origin", "https://admin.example.com"); rr.Code != http.StatusOK {
+		t.Errorf("Expected trusted origins to pass, got %d", rr.Code)
+	}
+}
+
+// TestCSRF_FormBody verifies form tokens and that the handler still reads the body.
+func TestCSRF_FormBody(t *testing.T) {
+	h := middleware.CSRF()(csrfHandler)
+	get := serveCSRF(h, http.MethodGet, "")
+	cookie, token := csrfCookie(t, get), get.Header().Get("X-Token")
+
+	form := url.Values{"csrf_token": {token}, "name": {"Ada"}}.Encode()
+	rr := serveCSRF(h, http.MethodPost, form, "Cookie", cookie, "Content-Type", "application/x-www-form-urlencoded")
+	if rr.Code != http.StatusOK || rr.Body.String() != form {
+		t.Errorf("Expected the form to pass with its body intact, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	// With a cached body the token is read from the request state.
+	req := httptest.NewRequest(http.MethodPost, "/account", strings.NewReader(form))
+	req.Header.Set("Cookie", cookie)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(adapter.WithState(req.Context(), &adapter.TranswarpState{Body: []byte(form)}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != form {
+		t.Errorf("Expected the cached form to pass with its body intact, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("csrf_token", token)
+	_ = mw.Close()
+	rr = serveCSRF(h, http.MethodPost, buf.String(), "Cookie", cookie, "Content-Type", mw.FormDataContentType())
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected the multipart form to pass, got %d", rr.Code)
+	}
+
+	bad := url.Values{"csrf_token": {"bogus"}}.Encode()
+	if rr := serveCSRF(h, http.MethodPost, bad, "Cookie", cookie, "Content-Type", "application/x-www-form-urlencoded"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected a forged form to be rejected, got %d", rr.Code)
+	}
+}
+
+// TestCSRF_Synchronizer verifies server-side tokens bound to sessions.
+func TestCSRF_Synchronizer(t *testing.T) {
+	session := func(r *http.Request) string { return r.Header.Get("X-Session") }
+	h := middleware.CSRF(middleware.WithCSRFSynchronizer(session, nil))(csrfHandler)
+
+	get := serveCSRF(h, http.MethodGet, "", "X-Session", "s1")
+	token := get.Header().Get("X-Token")
+	if token == "" || len(get.Result().Cookies()) != 0 {
+		t.Fatalf("Expected a token without cookie, got %q %v", token, get.Result().Cookies())
+	}
+	if rr := serveCSRF(h, http.MethodPost, "", "X-Session", "s1", "X-CSRF-Token", token); rr.Code != http.StatusOK {
+		t.Errorf("Expected the session token to pass, got %d", rr.Code)
+	}
+	if rr := serveCSRF(h, http.MethodPost, "", "X-Session", "s2", "X-CSRF-Token", token); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected another session to be rejected, got %d", rr.Code)
+	}
+	if rr := serveCSRF(h, http.MethodPost, "", "X-CSRF-Token", token); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected requests without session to be rejected, got %d", rr.Code)
+	}
+}
+
+// TestCSRF_Exempt verifies route and group exemptions.
+func TestCSRF_Exempt(t *testing.T) {
+	h := middleware.CSRF(middleware.WithCSRFExempt("/webhooks/stripe", "/api/*"))(csrfHandler)
+
+	for route, want := range map[string]int{
+		"/webhooks/stripe": http.StatusOK,
+		"/webhooks/github": http.StatusForbidden,
+		"/api/orders/:id":  http.StatusOK,
+		"/api":             http.StatusOK,
+		"/apix":            http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodPost, route, nil)
+		req = req.WithContext(adapter.WithState(req.Context(), &adapter.TranswarpState{Route: route}))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", route, want, rr.Code)
+		}
+	}
+}
+
+// TestCSRFTemplateField verifies the hidden input for templates.
+func TestCSRFTemplateField(t *testing.T) {
+	var field string
+	h := middleware.CSRF(middleware.WithCSRFField("_token"))(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		field = string(middleware.CSRFTemplateField(r))
+	}))
+	serveCSRF(h, http.MethodGet, "")
+
+	if !strings.HasPrefix(field, `<input type="hidden" name="_token" value="`) {
+		t.Errorf("Unexpected field %q", field)
+	}
+	if middleware.CSRFTemplateField(httptest.NewRequest(http.MethodGet, "/", nil)) != "" {
+		t.Error("Expected no field outside the middleware")
+	}
+}
@@ -0,0 +1,345 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/iaconlabs/transwarp/problem"
+)
+
+// DecodeOption configures how a route decodes its JSON body. Options are passed
+// to Validate per route, e.g. Validate(CreateUser{}, DisallowUnknownFields()).
+type DecodeOption func(*decodeConfig)
+
+// decodeConfig holds the decoding options of a route.
+type decodeConfig struct {
+	disallowUnknown bool
+	useNumber       bool
+	rejectDuplicate bool
+	maxBytes        int64
+	maxDepth        int
+}
+
+// DisallowUnknownFields rejects bodies with members that do not map to a field of
+// the DTO, reporting the first one found (e.g. a misspelled "emial").
+func DisallowUnknownFields() DecodeOption {
+	return func(c *decodeConfig) {
+		c.disallowUnknown = true
+	}
+}
+
+// UseNumber decodes numbers held in interface fields (any, map[string]any) as
+// json.Number instead of float64, so large integers keep their precision.
+func UseNumber() DecodeOption {
+	return func(c *decodeConfig) {
+		c.useNumber = true
+	}
+}
+
+// RejectDuplicateKeys rejects objects that repeat a member name. By default the
+// last occurrence wins, which can hide conflicting input.
+func RejectDuplicateKeys() DecodeOption {
+	return func(c *decodeConfig) {
+		c.rejectDuplicate = true
+	}
+}
+
+// MaxBodyBytes rejects bodies larger than n bytes with 413 Content Too Large.
+// The adapter has already read the body at this point, so use it together with
+// a server-level limit (e.g. http.MaxBytesHandler) to bound memory usage.
+func MaxBodyBytes(n int64) DecodeOption {
+	return func(c *decodeConfig) {
+		c.maxBytes = n
+	}
+}
+
+// MaxDepth rejects bodies whose objects and arrays are nested deeper than n levels.
+func MaxDepth(n int) DecodeOption {
+	return func(c *decodeConfig) {
+		c.maxDepth = n
+	}
+}
+
+// newDecodeConfig applies opts.
+func newDecodeConfig(opts []DecodeOption) *decodeConfig {
+	cfg := &decodeConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// decodeError reports a body rejected by a decoding option or a type mismatch.
+type decodeError struct {
+	path string
+	rule string
+	msg  string
+}
+
+func (e *decodeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.path, e.msg)
+}
+
+// errBodyTooLarge is returned when the body exceeds MaxBodyBytes.
+var errBodyTooLarge = errors.New("request body too large")
+
+// decode unmarshals body into target honoring the route options.
+func (c *decodeConfig) decode(body []byte, target any) error {
+	if c.maxBytes > 0 && int64(len(body)) > c.maxBytes {
+		return errBodyTooLarge
+	}
+
+	// The structural checks need a token pass that tracks paths and the target type.
+	if c.disallowUnknown || c.rejectDuplicate || c.maxDepth > 0 {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		if err := c.scan(dec, reflect.TypeOf(target), "", 0); err != nil {
+			return err
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if c.disallowUnknown {
+		dec.DisallowUnknownFields()
+	}
+	if c.useNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return &decodeError{
+				path: typeErrorPath(reflect.TypeOf(target), typeErr.Field),
+				rule: "type",
+				msg:  fmt.Sprintf("Expected %s, got JSON %s", typeErr.Type, typeErr.Value),
+			}
+		}
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after the JSON document")
+	}
+	return nil
+}
+
+// typeErrorPath converts the path of a json.UnmarshalTypeError ("items.0.price",
+// "labels.a.b") into the dotted style used for validation errors ("items[0].price",
+// "labels[a.b]"), following typ to tell struct fields from indexes and map keys.
+// A key holding dots is only kept whole when the map holds plain values.
+func typeErrorPath(typ reflect.Type, field string) string {
+	segs := strings.Split(field, ".")
+	var ns string
+	for i := 0; i < len(segs); i++ {
+		typ = indirectType(typ)
+		seg := segs[i]
+		switch {
+		case typ == nil:
+			// Untyped values decode into maps and slices.
+			ns = joinPath(ns, seg, true)
+		case typ.Kind() == reflect.Map:
+			if elem := indirectType(typ.Elem()); elem != nil && !isContainer(elem) {
+				seg = strings.Join(segs[i:], ".")
+				i = len(segs)
+			}
+			ns = joinPath(ns, seg, true)
+			typ = typ.Elem()
+		case typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array:
+			ns = joinPath(ns, seg, true)
+			typ = typ.Elem()
+		default:
+			ns = joinPath(ns, seg, false)
+			typ, _ = memberType(typ, seg)
+		}
+	}
+	return ns
+}
+
+// isContainer reports whether values of typ are JSON objects or arrays.
+func isContainer(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// scan walks the next JSON value. typ is the Go type the value decodes into, or
+// nil when any value is accepted; ns is its path, built like validator namespaces.
+func (c *decodeConfig) scan(dec *json.Decoder, typ reflect.Type, ns string, depth int) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	depth++
+	if c.maxDepth > 0 && depth > c.maxDepth {
+		return &decodeError{path: ns, rule: "max_depth", msg: fmt.Sprintf("Nesting exceeds the maximum depth of %d", c.maxDepth)}
+	}
+	typ = indirectType(typ)
+
+	switch delim {
+	case '{':
+		seen := make(map[string]struct{})
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyTok.(string)
+
+			// Members of maps and untyped objects are keys, like "labels[a.b]".
+			child := joinPath(ns, key, typ == nil || typ.Kind() == reflect.Map)
+			if c.rejectDuplicate {
+				if _, dup := seen[key]; dup {
+					return &decodeError{path: child, rule: "duplicate_key", msg: "Duplicate member in JSON object"}
+				}
+				seen[key] = struct{}{}
+			}
+
+			fieldType, known := memberType(typ, key)
+			if !known && c.disallowUnknown {
+				return &decodeError{path: child, rule: "unknown_field", msg: "Unknown field"}
+			}
+			if err := c.scan(dec, fieldType, child, depth); err != nil {
+				return err
+			}
+		}
+	case '[':
+		var elem reflect.Type
+		if typ != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+			elem = typ.Elem()
+		}
+		for i := 0; dec.More(); i++ {
+			if err := c.scan(dec, elem, joinPath(ns, strconv.Itoa(i), true), depth); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Consume the closing delimiter.
+	_, err = dec.Token()
+	return err
+}
+
+var jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// indirectType dereferences pointers and returns nil for types that accept any
+// JSON structure (interfaces and types with their own unmarshaling).
+func indirectType(typ reflect.Type) reflect.Type {
+	if typ == nil {
+		return nil
+	}
+	for typ.Kind() == reflect.Pointer {
+		if reflect.PointerTo(typ.Elem()).Implements(jsonUnmarshalerType) {
+			return nil
+		}
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Interface || reflect.PointerTo(typ).Implements(jsonUnmarshalerType) {
+		return nil
+	}
+	return typ
+}
+
+// memberType returns the type a JSON object member decodes into and whether the
+// member is known. Maps and untyped values accept any member.
+func memberType(typ reflect.Type, key string) (reflect.Type, bool) {
+	if typ == nil {
+		return nil, true
+	}
+	switch typ.Kind() {
+	case reflect.Map:
+		return typ.Elem(), true
+	case reflect.Struct:
+		fields := structFields(typ)
+		if ft, ok := fields.exact[key]; ok {
+			return ft, true
+		}
+		// encoding/json falls back to a case-insensitive match.
+		ft, ok := fields.folded[strings.ToLower(key)]
+		return ft, ok
+	default:
+		// A type mismatch; the decoder reports it with its own error.
+		return nil, true
+	}
+}
+
+// jsonFields indexes the members of a struct as seen by encoding/json.
+type jsonFields struct {
+	exact  map[string]reflect.Type
+	folded map[string]reflect.Type
+}
+
+var fieldCache sync.Map // map[reflect.Type]*jsonFields
+
+// structFields returns the JSON members of typ, including promoted fields of
+// embedded structs.
+func structFields(typ reflect.Type) *jsonFields {
+	if cached, ok := fieldCache.Load(typ); ok {
+		return cached.(*jsonFields)
+	}
+
+	fields := &jsonFields{exact: map[string]reflect.Type{}, folded: map[string]reflect.Type{}}
+	var collect func(t reflect.Type, visited map[reflect.Type]bool)
+	collect = func(t reflect.Type, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if sf.Anonymous && name == "" {
+				if et := indirectType(sf.Type); et != nil && et.Kind() == reflect.Struct {
+					collect(et, visited)
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			// The first field declared with a name wins; only its type matters here.
+			if _, exists := fields.exact[name]; !exists {
+				fields.exact[name] = sf.Type
+			}
+			if _, exists := fields.folded[strings.ToLower(name)]; !exists {
+				fields.folded[strings.ToLower(name)] = sf.Type
+			}
+		}
+	}
+	collect(typ, map[reflect.Type]bool{})
+
+	actual, _ := fieldCache.LoadOrStore(typ, fields)
+	return actual.(*jsonFields)
+}
+
+// decodeProblem maps a decoding error to the problem sent to the client.
+func (v *Validator) decodeProblem(err error) *problem.Details {
+	if errors.Is(err, errBodyTooLarge) {
+		return problem.New(http.StatusRequestEntityTooLarge).WithDetail("Request body exceeds the allowed size")
+	}
+
+	p := problem.New(http.StatusBadRequest).WithDetail("Invalid JSON format")
+	var de *decodeError
+	if errors.As(err, &de) {
+		p.With("errors", []ValidationError{{Field: v.renderPath(de.path), Rule: de.rule, Message: de.msg}})
+	}
+	return p
+}
@@ -0,0 +1,151 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/middleware"
+)
+
+type Audit struct {
+	CreatedBy string `json:"created_by"`
+}
+
+type LineItem struct {
+	SKU   string `json:"sku"`
+	Price int64  `json:"price"`
+}
+
+type StrictOrder struct {
+	Audit
+	Customer string         `json:"customer" validate:"required"`
+	Items    []LineItem     `json:"items"`
+	Metadata map[string]any `json:"metadata"`
+	Extra    any            `json:"extra"`
+}
+
+// decodeErrors extracts the status and the errors member of a problem response.
+func decodeErrors(t *testing.T, body string, opts ...middleware.DecodeOption) (int, []middleware.ValidationError) {
+	t.Helper()
+	rr, _ := serveCapture[StrictOrder](t, middleware.Validate(StrictOrder{}, opts...), body)
+	var res struct {
+		Errors []middleware.ValidationError `json:"errors"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	return rr.Code, res.Errors
+}
+
+// TestDecode_StrictOptions verifies each option with the field it reports.
+func TestDecode_StrictOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		opts      []middleware.DecodeOption
+		wantField string
+		wantRule  string
+	}{
+		{
+			name:      "Unknown nested field",
+			body:      `{"customer": "acme", "items": [{"sku": "a"}, {"skuu": "b"}]}`,
+			opts:      []middleware.DecodeOption{middleware.DisallowUnknownFields()},
+			wantField: "items[1].skuu",
+			wantRule:  "unknown_field",
+		},
+		{
+			name:      "Duplicate key",
+			body:      `{"customer": "acme", "customer": "evil"}`,
+			opts:      []middleware.DecodeOption{middleware.RejectDuplicateKeys()},
+			wantField: "customer",
+			wantRule:  "duplicate_key",
+		},
+		{
+			name:      "Too deep",
+			body:      `{"customer": "acme", "metadata": {"a": {"b": {"c": 1}}}}`,
+			opts:      []middleware.DecodeOption{middleware.MaxDepth(3)},
+			wantField: "metadata[a][b]",
+			wantRule:  "max_depth",
+		},
+		{
+			name:      "Type mismatch",
+			body:      `{"customer": "acme", "items": [{"price": "free"}]}`,
+			wantField: "items[0].price",
+			wantRule:  "type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, errs := decodeErrors(t, tt.body, tt.opts...)
+			if status != http.StatusBadRequest {
+				t.Fatalf("Expected 400, got %d", status)
+			}
+			if len(errs) != 1 || errs[0].Field != tt.wantField || errs[0].Rule != tt.wantRule {
+				t.Errorf("Unexpected errors: %+v", errs)
+			}
+		})
+	}
+}
+
+// TestDecode_AcceptsValidInput verifies that strict options allow well-formed
+// bodies, including promoted fields, map members and untyped values.
+func TestDecode_AcceptsValidInput(t *testing.T) {
+	body := `{"created_by": "ops", "customer": "acme", "metadata": {"any": {"key": 1}}, "extra": 9007199254740993}`
+	rr, got := serveCapture[StrictOrder](t, middleware.Validate(StrictOrder{},
+		middleware.DisallowUnknownFields(), middleware.RejectDuplicateKeys(), middleware.UseNumber(), middleware.MaxDepth(3)), body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got.CreatedBy != "ops" || got.Extra != json.Number("9007199254740993") {
+		t.Errorf("Unexpected result: %+v", got)
+	}
+}
+
+// TestDecode_MaxBodyBytes verifies that oversized bodies are rejected with 413.
+func TestDecode_MaxBodyBytes(t *testing.T) {
+	body := `{"customer": "` + strings.Repeat("a", 64) + `"}`
+	if status, _ := decodeErrors(t, body, middleware.MaxBodyBytes(32)); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d", status)
+	}
+	if status, _ := decodeErrors(t, body, middleware.MaxBodyBytes(1024)); status != http.StatusOK {
+		t.Errorf("Expected 200, got %d", status)
+	}
+}
+
+// LabeledOrder holds maps whose keys appear in reported field paths.
+type LabeledOrder struct {
+	Labels map[string]string   `json:"labels" validate:"dive,max=3"`
+	Limits map[string]LineItem `json:"limits"`
+}
+
+// TestDecode_MapKeyPaths verifies that decoding and validation errors report
+// map members with the same path.
+func TestDecode_MapKeyPaths(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantField string
+		wantRule  string
+	}{
+		{"Validation", `{"labels": {"env.name": "production"}}`, "labels[env.name]", "max"},
+		{"Duplicate key", `{"labels": {"env.name": "a", "env.name": "b"}}`, "labels[env.name]", "duplicate_key"},
+		{"Type mismatch", `{"labels": {"env.name": 5}}`, "labels[env.name]", "type"},
+		{"Unknown nested field", `{"limits": {"eu": {"skuu": "a"}}}`, "limits[eu].skuu", "unknown_field"},
+		{"Nested type mismatch", `{"limits": {"eu": {"price": "free"}}}`, "limits[eu].price", "type"},
+	}
+
+	mw := middleware.Validate(LabeledOrder{}, middleware.DisallowUnknownFields(), middleware.RejectDuplicateKeys())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, _ := serveCapture[LabeledOrder](t, mw, tt.body)
+			var res struct {
+				Errors []middleware.ValidationError `json:"errors"`
+			}
+			_ = json.Unmarshal(rr.Body.Bytes(), &res)
+			if len(res.Errors) != 1 || res.Errors[0].Field != tt.wantField || res.Errors[0].Rule != tt.wantRule {
+				t.Errorf("Unexpected errors: %s", rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// describedHandler wraps the handler built by a middleware with the
+// documentation that middleware contributes to the route (see router.Route.Describe).
+type describedHandler struct {
+	http.Handler
+	describe func(d *router.RouteDescription)
+}
+
+// DescribeRoute implements router.RouteDescriber.
+func (h *describedHandler) DescribeRoute(d *router.RouteDescription) {
+	h.describe(d)
+}
@@ -0,0 +1,257 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/iaconlabs/transwarp/problem"
+)
+
+// ResourceVersion identifies the current state of a resource for conditional
+// requests. Either field may be left empty.
+type ResourceVersion struct {
+	// ETag is the entity tag including its quotes and optional weak prefix,
+	// e.g. `"v42"` or `W/"v42"`. A bare value is quoted automatically.
+	ETag string
+	// LastModified is when the resource last changed. It is compared with a
+	// one-second resolution, as in HTTP dates.
+	LastModified time.Time
+}
+
+// ETagOption configures ETag.
+type ETagOption func(*etagConfig)
+
+// etagConfig holds the settings of an ETag middleware.
+type etagConfig struct {
+	weak         bool
+	version      func(r *http.Request) (ResourceVersion, error)
+	requireMatch bool
+}
+
+// WithWeakETags makes computed ETags weak (W/"..."), for representations that
+// are semantically but not byte-for-byte equivalent.
+func WithWeakETags() ETagOption {
+	return func(c *etagConfig) {
+		c.weak = true
+	}
+}
+
+// WithETagFunc sets a hook returning the current version of the requested
+// resource, e.g. from a database column, before the handler runs. Conditional
+// requests are then answered without running the handler (304 or 412), and
+// GET responses are not buffered to compute a hash. A zero version means it is
+// unknown; errors are rendered as problems (see problem.From).
+func WithETagFunc(fn func(r *http.Request) (ResourceVersion, error)) ETagOption {
+	return func(c *etagConfig) {
+		c.version = fn
+	}
+}
+
+// WithETagRequireMatch rejects PUT, PATCH and DELETE requests without If-Match
+// or If-Unmodified-Since with 428 Precondition Required, so clients cannot
+// overwrite changes they have not seen (lost updates).
+func WithETagRequireMatch() ETagOption {
+	return func(c *etagConfig) {
+		c.requireMatch = true
+	}
+}
+
+// ETag returns a middleware handling conditional requests (RFC 9110, section 13).
+// Successful GET and HEAD responses without an ETag get one computed from a
+// hash of the buffered body, and If-None-Match / If-Modified-Since are answered
+// with 304 Not Modified. Writes carrying If-Match / If-Unmodified-Since are
+// checked against the version from WithETagFunc, or by the handler itself with
+// CheckPreconditions, and fail with 412 Precondition Failed when stale.
+//
+// Buffering disables streaming on GET and HEAD routes unless WithETagFunc is used.
+func ETag(opts ...ETagOption) func(http.Handler) http.Handler {
+	cfg := &etagConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+			if cfg.requireMatch && (r.Method == http.MethodPut || r.Method == http.MethodPatch || r.Method == http.MethodDelete) &&
+				r.Header.Get("If-Match") == "" && r.Header.Get("If-Unmodified-Since") == "" {
+				problem.Write(w, r, problem.New(http.StatusPreconditionRequired).
+					WithDetail("This request must be conditional: send If-Match with the current ETag"))
+				return
+			}
+
+			if cfg.version != nil {
+				v, err := cfg.version(r)
+				if err != nil {
+					problem.Write(w, r, problem.From(err))
+					return
+				}
+				if v != (ResourceVersion{}) {
+					if !CheckPreconditions(w, r, v) {
+						return
+					}
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			if !safe {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			bw := newBufferedWriter(w)
+			next.ServeHTTP(bw, r)
+
+			h := bw.Header()
+			if bw.status != http.StatusOK {
+				bw.flush()
+				return
+			}
+			if h.Get("ETag") == "" {
+				h.Set("ETag", computeETag(bw.body.Bytes(), cfg.weak))
+			}
+			v := ResourceVersion{ETag: h.Get("ETag")}
+			v.LastModified, _ = http.ParseTime(h.Get("Last-Modified"))
+			if status := evaluatePreconditions(r, v); status == http.StatusNotModified {
+				bw.status = http.StatusNotModified
+				bw.body.Reset()
+				notModifiedHeaders(h)
+			}
+			bw.flush()
+		})
+	}
+}
+
+// CheckPreconditions declares the current version of the resource a handler
+// is about to read or modify. It sets the ETag and Last-Modified headers and
+// evaluates the conditional headers of r. When a precondition fails it writes
+// 304 Not Modified (GET and HEAD) or a 412 problem and returns false; the
+// handler must then return without further writes.
+//
+//	func updateUser(w http.ResponseWriter, r *http.Request) {
+//		user := load(r)
+//		if !middleware.CheckPreconditions(w, r, middleware.ResourceVersion{ETag: user.Version}) {
+//			return
+//		}
+//		// apply the update
+//	}
+func CheckPreconditions(w http.ResponseWriter, r *http.Request, v ResourceVersion) bool {
+	v.ETag = quoteETag(v.ETag)
+	h := w.Header()
+	if v.ETag != "" {
+		h.Set("ETag", v.ETag)
+	}
+	if !v.LastModified.IsZero() {
+		h.Set("Last-Modified", v.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	switch evaluatePreconditions(r, v) {
+	case http.StatusNotModified:
+		notModifiedHeaders(h)
+		w.WriteHeader(http.StatusNotModified)
+		return false
+	case http.StatusPreconditionFailed:
+		problem.Write(w, r, problem.New(http.StatusPreconditionFailed).
+			WithDetail("The resource has been modified since it was last retrieved"))
+		return false
+	}
+	return true
+}
+
+// evaluatePreconditions applies the conditional headers of r to v in the order
+// of RFC 9110, section 13.2.2. It returns 0 when the request may proceed.
+func evaluatePreconditions(r *http.Request, v ResourceVersion) int {
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+	lastModified := v.LastModified.Truncate(time.Second)
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !etagListMatches(im, v, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := r.Header.Get("If-Unmodified-Since"); ius != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ius); err == nil && lastModified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagListMatches(inm, v, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && safe && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// etagListMatches reports whether the comma-separated entity tags of header
+// match the ETag of v, using the weak or strong comparison of RFC 9110. "*"
+// matches any known version, even one without an ETag.
+func etagListMatches(header string, v ResourceVersion, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return v != (ResourceVersion{})
+	}
+	etag := v.ETag
+	if etag == "" {
+		return false
+	}
+	etagWeak, etagValue := strings.HasPrefix(etag, "W/"), strings.TrimPrefix(etag, "W/")
+	if etagWeak && !weak {
+		return false
+	}
+
+	for rest := header; ; {
+		rest = strings.TrimLeft(rest, " \t,")
+		if rest == "" {
+			return false
+		}
+		tagWeak := strings.HasPrefix(rest, "W/")
+		rest = strings.TrimPrefix(rest, "W/")
+		if !strings.HasPrefix(rest, `"`) {
+			return false // malformed list
+		}
+		end := strings.IndexByte(rest[1:], '"')
+		if end < 0 {
+			return false
+		}
+		tag := rest[:end+2]
+		rest = rest[end+2:]
+		if tag == etagValue && (weak || !tagWeak) {
+			return true
+		}
+	}
+}
+
+// computeETag hashes body into an entity tag.
+func computeETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// quoteETag adds the quotes missing from a bare entity tag.
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// notModifiedHeaders removes the representation headers a 304 must not carry.
+func notModifiedHeaders(h http.Header) {
+	for _, name := range []string{"Content-Type", "Content-Length", "Content-Encoding", "Transfer-Encoding"} {
+		h.Del(name)
+	}
+}
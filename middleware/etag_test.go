@@ -0,0 +1,188 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/problem"
+)
+
+// serveETag sends a request with the given headers through mw to handler.
+func serveETag(mw func(http.Handler) http.Handler, method string, headers map[string]string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/users/1", strings.NewReader(`{"name":"Ada"}`))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	mw(handler).ServeHTTP(rr, req)
+	return rr
+}
+
+func userHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, `{"id":1,"name":"Ada"}`)
+}
+
+// TestETag_Computed verifies computed ETags and 304 responses to If-None-Match.
+func TestETag_Computed(t *testing.T) {
+	mw := middleware.ETag()
+	rr := serveETag(mw, http.MethodGet, nil, userHandler)
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) || rr.Body.Len() == 0 {
+		t.Fatalf("Expected a 200 with a strong ETag, got %d %q", rr.Code, etag)
+	}
+	if again := serveETag(mw, http.MethodGet, nil, userHandler); again.Header().Get("ETag") != etag {
+		t.Error("Equal bodies must have equal ETags")
+	}
+
+	for _, inm := range []string{etag, `"other", ` + etag, "W/" + etag, "*"} {
+		rr := serveETag(mw, http.MethodGet, map[string]string{"If-None-Match": inm}, userHandler)
+		if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+			t.Errorf("%s: expected an empty 304, got %d %q", inm, rr.Code, rr.Body.String())
+		}
+		if rr.Header().Get("ETag") != etag || rr.Header().Get("Content-Type") != "" {
+			t.Errorf("%s: unexpected 304 headers %v", inm, rr.Header())
+		}
+	}
+	if rr := serveETag(mw, http.MethodGet, map[string]string{"If-None-Match": `"stale"`}, userHandler); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a stale ETag, got %d", rr.Code)
+	}
+
+	weak := serveETag(middleware.ETag(middleware.WithWeakETags()), http.MethodGet, nil, userHandler)
+	if got := weak.Header().Get("ETag"); got != "W/"+etag {
+		t.Errorf("Expected the weak form W/%s, got %q", etag, got)
+	}
+
+	failed := serveETag(mw, http.MethodGet, nil, func(w http.ResponseWriter, r *http.Request) {
+		problem.NotFound(w, r)
+	})
+	if failed.Code != http.StatusNotFound || failed.Header().Get("ETag") != "" {
+		t.Errorf("Unsuccessful responses must not get an ETag: %d %v", failed.Code, failed.Header())
+	}
+}
+
+// TestETag_IfModifiedSince verifies 304 responses based on Last-Modified.
+func TestETag_IfModifiedSince(t *testing.T) {
+	modified := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		userHandler(w, r)
+	}
+	mw := middleware.ETag()
+
+	rr := serveETag(mw, http.MethodGet, map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, handler)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected 304, got %d", rr.Code)
+	}
+	rr = serveETag(mw, http.MethodGet, map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, handler)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for an older date, got %d", rr.Code)
+	}
+	// If-None-Match takes precedence over If-Modified-Since.
+	rr = serveETag(mw, http.MethodGet, map[string]string{
+		"If-None-Match":     `"stale"`,
+		"If-Modified-Since": modified.Format(http.TimeFormat),
+	}, handler)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected If-None-Match to win, got %d", rr.Code)
+	}
+}
+
+// TestETag_VersionFunc verifies that a version hook answers conditional
+// requests without running the handler.
+func TestETag_VersionFunc(t *testing.T) {
+	modified := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	mw := middleware.ETag(middleware.WithETagFunc(func(*http.Request) (middleware.ResourceVersion, error) {
+		return middleware.ResourceVersion{ETag: "v7", LastModified: modified}, nil
+	}))
+
+	cases := []struct {
+		method  string
+		headers map[string]string
+		status  int
+		runs    bool
+	}{
+		{http.MethodGet, map[string]string{"If-None-Match": `"v7"`}, http.StatusNotModified, false},
+		{http.MethodGet, map[string]string{"If-None-Match": `"v6"`}, http.StatusOK, true},
+		{http.MethodPut, map[string]string{"If-Match": `"v6"`}, http.StatusPreconditionFailed, false},
+		{http.MethodPut, map[string]string{"If-Match": `"v7"`}, http.StatusOK, true},
+		{http.MethodPatch, map[string]string{"If-Match": "*"}, http.StatusOK, true},
+		{http.MethodPut, map[string]string{"If-Match": `W/"v7"`}, http.StatusPreconditionFailed, false},
+		{http.MethodDelete, map[string]string{"If-Unmodified-Since": modified.Add(-time.Minute).Format(http.TimeFormat)}, http.StatusPreconditionFailed, false},
+		{http.MethodDelete, map[string]string{"If-Unmodified-Since": modified.Format(http.TimeFormat)}, http.StatusOK, true},
+		{http.MethodPost, map[string]string{"If-None-Match": "*"}, http.StatusPreconditionFailed, false},
+	}
+	for _, tc := range cases {
+		ran := false
+		rr := serveETag(mw, tc.method, tc.headers, func(w http.ResponseWriter, r *http.Request) {
+			ran = true
+			userHandler(w, r)
+		})
+		if rr.Code != tc.status || ran != tc.runs {
+			t.Errorf("%s %v: expected %d (handler run: %v), got %d (%v)", tc.method, tc.headers, tc.status, tc.runs, rr.Code, ran)
+		}
+		if rr.Header().Get("ETag") != `"v7"` {
+			t.Errorf("%s %v: expected the declared ETag, got %q", tc.method, tc.headers, rr.Header().Get("ETag"))
+		}
+	}
+}
+
+// TestETag_VersionWithoutETag verifies that "*" matches a version known only
+// by its modification time.
+func TestETag_VersionWithoutETag(t *testing.T) {
+	modified := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	mw := middleware.ETag(middleware.WithETagFunc(func(*http.Request) (middleware.ResourceVersion, error) {
+		return middleware.ResourceVersion{LastModified: modified}, nil
+	}))
+
+	if rr := serveETag(mw, http.MethodPut, map[string]string{"If-Match": "*"}, userHandler); rr.Code != http.StatusOK {
+		t.Errorf(`Expected If-Match "*" to pass, got %d`, rr.Code)
+	}
+	if rr := serveETag(mw, http.MethodPut, map[string]string{"If-Match": `"v7"`}, userHandler); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected an entity tag to fail without ETag, got %d", rr.Code)
+	}
+	if rr := serveETag(mw, http.MethodPost, map[string]string{"If-None-Match": "*"}, userHandler); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf(`Expected If-None-Match "*" to fail, got %d`, rr.Code)
+	}
+}
+
+// TestETag_RequireMatch verifies that unconditional writes are rejected.
+func TestETag_RequireMatch(t *testing.T) {
+	mw := middleware.ETag(middleware.WithETagRequireMatch())
+	if rr := serveETag(mw, http.MethodPut, nil, userHandler); rr.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected 428, got %d", rr.Code)
+	}
+	if rr := serveETag(mw, http.MethodPost, nil, userHandler); rr.Code != http.StatusOK {
+		t.Errorf("POST creates resources and must not require If-Match, got %d", rr.Code)
+	}
+}
+
+// TestCheckPreconditions verifies the handler-side version declaration.
+func TestCheckPreconditions(t *testing.T) {
+	updated := false
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.CheckPreconditions(w, r, middleware.ResourceVersion{ETag: `"v2"`}) {
+			return
+		}
+		updated = true
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	rr := serveETag(middleware.ETag(), http.MethodPut, map[string]string{"If-Match": `"v1"`}, handler)
+	if rr.Code != http.StatusPreconditionFailed || updated {
+		t.Errorf("Expected 412 without updating, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected a problem response, got %q", ct)
+	}
+
+	rr = serveETag(middleware.ETag(), http.MethodPut, map[string]string{"If-Match": `"v2"`}, handler)
+	if rr.Code != http.StatusNoContent || !updated || rr.Header().Get("ETag") != `"v2"` {
+		t.Errorf("Expected the update to proceed, got %d", rr.Code)
+	}
+}
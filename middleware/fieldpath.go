@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/iaconlabs/transwarp/internal/payload"
+)
+
+// PathStyle controls how the location of an invalid field is reported in
+// ValidationError.Field.
+type PathStyle int
+
+const (
+	// PathDotted reports paths such as "items[3].price" or "labels[env]".
+	// Top-level fields are reported by their bare name (e.g. "email").
+	PathDotted PathStyle = iota
+	// PathJSONPointer reports RFC 6901 JSON Pointers such as "/items/3/price".
+	PathJSONPointer
+)
+
+// WithFieldPaths selects the style used to report field locations.
+func WithFieldPaths(style PathStyle) ValidatorOption {
+	return func(v *Validator) {
+		v.pathStyle = style
+	}
+}
+
+// WithSensitiveFields adds field names whose rejected values must be redacted in
+// validation errors. Fields whose json name contains one of them, ignoring case,
+// underscores and hyphens, are redacted. Common credential names are redacted by
+// default.
+func WithSensitiveFields(names ...string) ValidatorOption {
+	return func(v *Validator) {
+		v.sensitive.Add(names...)
+	}
+}
+
+// fieldName resolves the public name of a struct field: the json tag when present,
+// then the param or claim tag, and finally the lowercased Go field name.
+func fieldName(fld reflect.StructField) string {
+	if name, _, _ := strings.Cut(fld.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	if name := fld.Tag.Get("param"); name != "" {
+		return name
+	}
+	if name := fld.Tag.Get("claim"); name != "" {
+		return name
+	}
+	return strings.ToLower(fld.Name)
+}
+
+// fieldSegments splits a validator namespace without its root type name, such as
+// "items[3].price" or "labels[a.b]", into path segments ("items", "3", "price").
+// Bracketed map keys are kept intact even when they contain dots.
+func fieldSegments(namespace string) []string {
+	var segments []string
+	var current strings.Builder
+
+	flush := func() {
+		if current.Len() > 0 {
+			segments = append(segments, current.String())
+			current.Reset()
+		}
+	}
+
+	for i := 0; i < len(namespace); i++ {
+		switch c := namespace[i]; c {
+		case '.':
+			flush()
+		case '[':
+			flush()
+			end := strings.IndexByte(namespace[i:], ']')
+			if end == -1 {
+				end = len(namespace) - i
+			}
+			segments = append(segments, namespace[i+1:i+end])
+			i += end
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return segments
+}
+
+// joinPath appends a member to a dotted namespace with the segment rules of the
+// validator: struct fields follow a dot, while slice indexes and map keys are
+// bracketed ("items[3]", "labels[a.b]").
+func joinPath(ns, member string, bracket bool) string {
+	switch {
+	case ns == "":
+		return member
+	case bracket:
+		return ns + "[" + member + "]"
+	default:
+		return ns + "." + member
+	}
+}
+
+// formatPath renders the location of a field error in the configured style.
+func (v *Validator) formatPath(fe validator.FieldError) string {
+	// The namespace starts with the root type name (e.g. "Order.items[3].price"),
+	// or with an index or key when elements of a list were validated ("[3].price").
+	if ns := fe.Namespace(); strings.HasPrefix(ns, "[") {
+		return v.renderPath(ns)
+	}
+	_, ns, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return v.renderPath(ns)
+}
+
+// renderPath renders a dotted namespace without its root type name, such as
+// "items[3].price", in the configured style.
+func (v *Validator) renderPath(ns string) string {
+	if v.pathStyle != PathJSONPointer {
+		return ns
+	}
+
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	var b strings.Builder
+	for _, seg := range fieldSegments(ns) {
+		b.WriteByte('/')
+		b.WriteString(escaper.Replace(seg))
+	}
+	return b.String()
+}
+
+// rejectedValue returns the value that failed validation, redacting sensitive fields.
+func (v *Validator) rejectedValue(fe validator.FieldError) any {
+	if v.sensitive.Match(fe.Field()) {
+		return payload.Redacted
+	}
+
+	value := fe.Value()
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Pointer, reflect.Interface, reflect.Func, reflect.Chan:
+		// Composite values may embed sensitive data and are rarely useful to clients.
+		return nil
+	case reflect.Invalid:
+		return nil
+	default:
+		return value
+	}
+}
@@ -0,0 +1,157 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
+)
+
+// OrderRequest nests slices, maps and structs to exercise field path reporting.
+type OrderRequest struct {
+	Customer struct {
+		Password string `json:"password" validate:"min=8"`
+	} `json:"customer"`
+	Items []struct {
+		Price float64 `json:"price" validate:"gt=0"`
+	} `json:"items" validate:"dive"`
+	Labels map[string]string `json:"labels" validate:"dive,max=3"`
+}
+
+// validationErrors runs the middleware and decodes the reported errors.
+func validationErrors(t *testing.T, v *middleware.Validator, body string) map[string]middleware.ValidationError {
+	t.Helper()
+	rr := serveWithState(t, v.Validate(OrderRequest{}), &adapter.TranswarpState{Body: []byte(body)})
+
+	var response struct {
+		Errors []middleware.ValidationError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v (%s)", err, rr.Body.String())
+	}
+
+	byField := make(map[string]middleware.ValidationError, len(response.Errors))
+	for _, e := range response.Errors {
+		byField[e.Field] = e
+	}
+	return byField
+}
+
+const invalidOrder = `{
+	"customer": {"password": "short"},
+	"items": [{"price": 10}, {"price": 0}],
+	"labels": {"env.name": "production"}
+}`
+
+// TestFieldPaths_Dotted verifies dotted paths built from json tags.
+func TestFieldPaths_Dotted(t *testing.T) {
+	errs := validationErrors(t, middleware.NewValidator(), invalidOrder)
+
+	for _, field := range []string{"customer.password", "items[1].price", "labels[env.name]"} {
+		if _, ok := errs[field]; !ok {
+			t.Errorf("Expected an error for %q, got %v", field, errs)
+		}
+	}
+
+	if price := errs["items[1].price"]; price.Param != "0" {
+		t.Errorf("Unexpected param for price: %+v", price)
+	}
+	if label := errs["labels[env.name]"]; label.Value != "production" || label.Param != "3" {
+		t.Errorf("Unexpected param/value for label: %+v", label)
+	}
+}
+
+// TestFieldPaths_JSONPointer verifies RFC 6901 pointers and redaction of sensitive values.
+func TestFieldPaths_JSONPointer(t *testing.T) {
+	v := middleware.NewValidator(middleware.WithFieldPaths(middleware.PathJSONPointer))
+	errs := validationErrors(t, v, invalidOrder)
+
+	for _, field := range []string{"/customer/password", "/items/1/price", "/labels/env.name"} {
+		if _, ok := errs[field]; !ok {
+			t.Errorf("Expected an error for %q, got %v", field, errs)
+		}
+	}
+
+	if pwd := errs["/customer/password"]; pwd.Value != "[REDACTED]" {
+		t.Errorf("Password value must be redacted, got %v", pwd.Value)
+	}
+}
+
+// TestFieldPaths_CustomSensitiveField verifies user-declared sensitive fields.
+func TestFieldPaths_CustomSensitiveField(t *testing.T) {
+	type CardRequest struct {
+		PinCode string `json:"pin_code" validate:"len=4"`
+	}
+
+	v := middleware.NewValidator(middleware.WithSensitiveFields("PIN_CODE"))
+	rr := serveWithState(t, v.Validate(CardRequest{}), &adapter.TranswarpState{Body: []byte(`{"pin_code": "12345"}`)})
+
+	var response struct {
+		Errors []middleware.ValidationError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || len(response.Errors) != 1 {
+		t.Fatalf("Unexpected response: %s", rr.Body.String())
+	}
+	if got := response.Errors[0]; got.Field != "pin_code" || got.Value != "[REDACTED]" {
+		t.Errorf("Expected redacted pin_code, got %+v", got)
+	}
+}
+
+// TestFieldPaths_SensitiveVariants verifies that names containing a sensitive
+// name are redacted, while short names only match whole field names.
+func TestFieldPaths_SensitiveVariants(t *testing.T) {
+	type CredentialsRequest struct {
+		PasswordConfirmation string `json:"password_confirmation" validate:"min=8"`
+		NewPassword          string `json:"new_password" validate:"min=8"`
+		APIKey               string `json:"apiKey" validate:"min=8"`
+		Shipping             string `json:"shipping" validate:"min=8"`
+	}
+
+	rr := serveWithState(t, middleware.NewValidator().Validate(CredentialsRequest{}), &adapter.TranswarpState{
+		Body: []byte(`{"password_confirmation": "x", "new_password": "y", "apiKey": "z", "shipping": "home"}`),
+	})
+
+	var response struct {
+		Errors []middleware.ValidationError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || len(response.Errors) != 4 {
+		t.Fatalf("Unexpected response: %s", rr.Body.String())
+	}
+	for _, e := range response.Errors {
+		want := any("[REDACTED]")
+		if e.Field == "shipping" {
+			want = "home"
+		}
+		if e.Value != want {
+			t.Errorf("%s: expected value %v, got %v", e.Field, want, e.Value)
+		}
+	}
+}
+
+// TestFieldPaths_ZeroValues verifies that rejected zero values are reported.
+func TestFieldPaths_ZeroValues(t *testing.T) {
+	type SettingsRequest struct {
+		Name    string `json:"name" validate:"required"`
+		Retries int    `json:"retries" validate:"required"`
+		Enabled bool   `json:"enabled" validate:"eq=true"`
+	}
+
+	rr := serveWithState(t, middleware.NewValidator().Validate(SettingsRequest{}), &adapter.TranswarpState{
+		Body: []byte(`{"name": "", "retries": 0, "enabled": false}`),
+	})
+
+	var response struct {
+		Errors []map[string]any `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || len(response.Errors) != 3 {
+		t.Fatalf("Unexpected response: %s", rr.Body.String())
+	}
+	want := map[string]any{"name": "", "retries": float64(0), "enabled": false}
+	for _, e := range response.Errors {
+		field, _ := e["field"].(string)
+		if value, ok := e["value"]; !ok || value != want[field] {
+			t.Errorf("%s: expected value %v, got %v (present %t)", field, want[field], value, ok)
+		}
+	}
+}
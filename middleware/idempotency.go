@@ -0,0 +1,274 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/iaconlabs/transwarp/internal/payload"
+	"github.com/iaconlabs/transwarp/problem"
+)
+
+// IdempotencyKeyHeader is the header carrying the client-chosen idempotency key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds keys so they are safe to store and log.
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyRecord is the stored outcome of a request made with an
+// idempotency key. Until Done is set the original request is still in flight.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request (method, path, query and body) the key
+	// was first used with.
+	Fingerprint string      `json:"fingerprint"`
+	Done        bool        `json:"done"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// IdempotencyStore persists idempotency records, e.g. in memory (see
+// MemoryIdempotencyStore) or in Redis to share them between instances.
+type IdempotencyStore interface {
+	// Lock atomically creates an in-flight record for key, valid for ttl, unless
+	// one exists. It returns the existing record, or nil when the lock was acquired.
+	Lock(ctx context.Context, key string, rec *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, error)
+	// Complete replaces the record of key with the final response, kept for ttl.
+	Complete(ctx context.Context, key string, rec *IdempotencyRecord, ttl time.Duration) error
+	// Release deletes the record of key so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyOption configures Idempotency.
+type IdempotencyOption func(*idempotencyConfig)
+
+// idempotencyConfig holds the settings of an Idempotency middleware.
+type idempotencyConfig struct {
+	store    IdempotencyStore
+	ttl      time.Duration
+	lockTTL  time.Duration
+	scope    func(r *http.Request) string
+	required bool
+}
+
+// WithIdempotencyStore sets where records are kept. The default is a
+// MemoryIdempotencyStore private to the middleware.
+func WithIdempotencyStore(s IdempotencyStore) IdempotencyOption {
+	return func(c *idempotencyConfig) {
+		if s != nil {
+			c.store = s
+		}
+	}
+}
+
+// WithIdempotencyTTL sets how long responses are replayed (24 hours by
+// default) and how long an in-flight request holds its key before it is
+// considered abandoned (one minute by default).
+func WithIdempotencyTTL(ttl, lock time.Duration) IdempotencyOption {
+	return func(c *idempotencyConfig) {
+		if ttl > 0 {
+			c.ttl = ttl
+		}
+		if lock > 0 {
+			c.lockTTL = lock
+		}
+	}
+}
+
+// WithIdempotencyScope scopes keys, e.g. by user or tenant, so that clients
+// cannot replay each other's responses by guessing keys. fn typically reads a
+// value set by an authentication middleware in the request state.
+func WithIdempotencyScope(fn func(r *http.Request) string) IdempotencyOption {
+	return func(c *idempotencyConfig) {
+		c.scope = fn
+	}
+}
+
+// WithIdempotencyRequired rejects POST and PATCH requests without an
+// Idempotency-Key header with 400 Bad Request.
+func WithIdempotencyRequired() IdempotencyOption {
+	return func(c *idempotencyConfig) {
+		c.required = true
+	}
+}
+
+// Idempotency returns a middleware that makes POST and PATCH requests safe to
+// retry. The first response for each Idempotency-Key (within its scope) is
+// stored and replayed for later requests with the same key, marked with
+// Idempotent-Replayed: true. A request reusing a key while the original is in
+// flight gets 409 Conflict; reusing it with a different method, path or body
+// gets 422 Unprocessable Content. 5xx responses are not stored, so failed
+// requests can be retried. Other methods and requests without a key pass through.
+//
+// The body fingerprint uses the request state body cached by the adapter.
+func Idempotency(opts ...IdempotencyOption) func(http.Handler) http.Handler {
+	cfg := &idempotencyConfig{ttl: 24 * time.Hour, lockTTL: time.Minute}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.store == nil {
+		cfg.store = NewMemoryIdempotencyStore()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost && r.Method != http.MethodPatch {
+				next.ServeHTTP(w, r)
+				return
+			}
+			idemKey := r.Header.Get(IdempotencyKeyHeader)
+			switch {
+			case idemKey == "" && !cfg.required:
+				next.ServeHTTP(w, r)
+				return
+			case idemKey == "":
+				problem.Write(w, r, problem.New(http.StatusBadRequest).
+					WithDetail("The Idempotency-Key header is required"))
+				return
+			case len(idemKey) > maxIdempotencyKeyLength:
+				problem.Write(w, r, problem.New(http.StatusBadRequest).
+					WithDetail("The Idempotency-Key header is too long"))
+				return
+			}
+
+			key := idemKey
+			if cfg.scope != nil {
+				key = cfg.scope(r) + "\x00" + idemKey
+			}
+			fingerprint := requestFingerprint(r)
+
+			ctx := r.Context()
+			existing, err := cfg.store.Lock(ctx, key, &IdempotencyRecord{Fingerprint: fingerprint}, cfg.lockTTL)
+			if err != nil {
+				slog.ErrorContext(ctx, "transwarp: idempotency store failed", "error", err)
+				problem.Write(w, r, problem.New(http.StatusServiceUnavailable))
+				return
+			}
+			if existing != nil {
+				switch {
+				case existing.Fingerprint != fingerprint:
+					problem.Write(w, r, problem.New(http.StatusUnprocessableEntity).
+						WithDetail("The Idempotency-Key was already used with a different request"))
+				case !existing.Done:
+					w.Header().Set("Retry-After", "1")
+					problem.Write(w, r, problem.New(http.StatusConflict).
+						WithDetail("A request with this Idempotency-Key is still being processed"))
+				default:
+					h := w.Header()
+					for k, v := range existing.Header {
+						h[k] = slices.Clone(v)
+					}
+					h.Set("Idempotent-Replayed", "true")
+					w.WriteHeader(existing.Status)
+					_, _ = w.Write(existing.Body)
+				}
+				return
+			}
+
+			completed := false
+			defer func() {
+				// Release the key if the handler failed or panicked.
+				if !completed {
+					if err := cfg.store.Release(context.WithoutCancel(ctx), key); err != nil {
+						slog.ErrorContext(ctx, "transwarp: idempotency store failed", "error", err)
+					}
+				}
+			}()
+
+			initial := w.Header().Clone()
+			bw := newBufferedWriter(w)
+			next.ServeHTTP(bw, r)
+
+			if bw.status < 500 {
+				rec := &IdempotencyRecord{
+					Fingerprint: fingerprint,
+					Done:        true,
+					Status:      bw.status,
+					Header:      bw.handlerHeader(initial),
+					Body:        slices.Clone(bw.body.Bytes()),
+				}
+				if err := cfg.store.Complete(context.WithoutCancel(ctx), key, rec, cfg.ttl); err != nil {
+					slog.ErrorContext(ctx, "transwarp: idempotency store failed", "error", err)
+				} else {
+					completed = true
+				}
+			}
+			bw.flush()
+		})
+	}
+}
+
+// requestFingerprint hashes the method, path, query and body of r. The body comes from
+// the request state when cached, or is read and restored.
+func requestFingerprint(r *http.Request) string {
+	body, _ := payload.Body(r)
+
+	h := sha256.New()
+	_, _ = io.WriteString(h, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+"\x00")
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+var _ IdempotencyStore = &MemoryIdempotencyStore{}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore. Expired records
+// are removed lazily.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]idempotencyEntry
+	nextSweep time.Time
+}
+
+type idempotencyEntry struct {
+	rec     *IdempotencyRecord
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]idempotencyEntry)}
+}
+
+// Lock implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Lock(_ context.Context, key string, rec *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.After(s.nextSweep) {
+		for k, e := range s.records {
+			if !now.Before(e.expires) {
+				delete(s.records, k)
+			}
+		}
+		s.nextSweep = now.Add(time.Minute)
+	}
+
+	if e, ok := s.records[key]; ok && now.Before(e.expires) {
+		return e.rec, nil
+	}
+	s.records[key] = idempotencyEntry{rec: rec, expires: now.Add(ttl)}
+	return nil, nil
+}
+
+// Complete implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Complete(_ context.Context, key string, rec *IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = idempotencyEntry{rec: rec, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Release implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
@@ -0,0 +1,192 @@
+package middleware_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
+)
+
+// serveIdempotent sends a POST with the given key and body through h.
+func serveIdempotent(h http.Handler, key, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+// paymentHandler creates a payment per call, echoing the request body.
+func paymentHandler() (http.HandlerFunc, *atomic.Int32) {
+	var calls atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Location", fmt.Sprintf("/payments/%d", n))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, "payment %d: %s", n, body)
+	}, &calls
+}
+
+// TestIdempotency_Replay verifies that retries replay the first response.
+func TestIdempotency_Replay(t *testing.T) {
+	handler, calls := paymentHandler()
+	h := middleware.Idempotency()(handler)
+
+	first := serveIdempotent(h, "k1", `{"amount":10}`)
+	retry := serveIdempotent(h, "k1", `{"amount":10}`)
+	if calls.Load() != 1 {
+		t.Fatalf("Expected the payment to be created once, got %d", calls.Load())
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() ||
+		retry.Header().Get("Location") != "/payments/1" {
+		t.Errorf("Unexpected replay %d %q %v", retry.Code, retry.Body.String(), retry.Header())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("Only replays must carry Idempotent-Replayed")
+	}
+
+	if rr := serveIdempotent(h, "k1", `{"amount":99}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a different body, got %d", rr.Code)
+	}
+	serveIdempotent(h, "k2", `{"amount":10}`)
+	serveIdempotent(h, "", `{"amount":10}`)
+	if calls.Load() != 3 {
+		t.Errorf("New keys and requests without a key must run the handler, got %d calls", calls.Load())
+	}
+}
+
+// TestIdempotency_Query verifies that the query string is part of the fingerprint.
+func TestIdempotency_Query(t *testing.T) {
+	handler, calls := paymentHandler()
+	h := middleware.Idempotency()(handler)
+
+	serve := func(target string) int {
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		req.Header.Set("Idempotency-Key", "k1")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := serve("/payments?amount=500"); code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+	if code := serve("/payments?amount=5"); code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a different query, got %d", code)
+	}
+	if code := serve("/payments?amount=500"); code != http.StatusCreated || calls.Load() != 1 {
+		t.Errorf("Expected the same query to be replayed, got %d after %d calls", code, calls.Load())
+	}
+}
+
+// TestIdempotency_InFlight verifies the 409 answer while the original request runs.
+func TestIdempotency_InFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	h := middleware.Idempotency()(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serveIdempotent(h, "k1", "{}") }()
+	<-started
+
+	rr := serveIdempotent(h, "k1", "{}")
+	if rr.Code != http.StatusConflict || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 409 with Retry-After, got %d", rr.Code)
+	}
+	close(release)
+	if first := <-done; first.Code != http.StatusCreated {
+		t.Errorf("Expected the original request to succeed, got %d", first.Code)
+	}
+	if rr := serveIdempotent(h, "k1", "{}"); rr.Code != http.StatusCreated || rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected a replay after completion, got %d", rr.Code)
+	}
+}
+
+// TestIdempotency_ServerErrors verifies that failed requests can be retried.
+func TestIdempotency_ServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	h := middleware.Idempotency()(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	if rr := serveIdempotent(h, "k1", "{}"); rr.Code != http.StatusBadGateway {
+		t.Fatalf("Expected 502, got %d", rr.Code)
+	}
+	if rr := serveIdempotent(h, "k1", "{}"); rr.Code != http.StatusCreated || calls.Load() != 2 {
+		t.Errorf("Expected the retry to run the handler, got %d after %d calls", rr.Code, calls.Load())
+	}
+}
+
+// TestIdempotency_Options verifies scopes, required keys and the TTL.
+func TestIdempotency_Options(t *testing.T) {
+	handler, calls := paymentHandler()
+	h := middleware.Idempotency(
+		middleware.WithIdempotencyScope(func(r *http.Request) string { return r.Header.Get("X-User") }),
+		middleware.WithIdempotencyRequired(),
+		middleware.WithIdempotencyTTL(20*time.Millisecond, 0),
+	)(handler)
+
+	serveIdempotent(h, "k1", "{}", "X-User", "alice")
+	if rr := serveIdempotent(h, "k1", "{}", "X-User", "bob"); rr.Header().Get("Idempotent-Replayed") != "" || calls.Load() != 2 {
+		t.Error("Keys of different users must not collide")
+	}
+	if rr := serveIdempotent(h, "", "{}"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a key, got %d", rr.Code)
+	}
+	if rr := serveIdempotent(h, strings.Repeat("k", 256), "{}"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an oversized key, got %d", rr.Code)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	serveIdempotent(h, "k1", "{}", "X-User", "alice")
+	if calls.Load() != 3 {
+		t.Errorf("Expected expired records to be forgotten, got %d calls", calls.Load())
+	}
+
+	get := httptest.NewRequest(http.MethodGet, "/payments", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, get)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("GET requests must pass through, got %d", rr.Code)
+	}
+}
+
+// TestIdempotency_StateBody verifies that the fingerprint uses the body cached
+// in the request state.
+func TestIdempotency_StateBody(t *testing.T) {
+	handler, _ := paymentHandler()
+	h := middleware.Idempotency()(handler)
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/payments", http.NoBody)
+		req.Header.Set("Idempotency-Key", "k1")
+		state := &adapter.TranswarpState{Params: map[string]string{}, Body: []byte(body)}
+		req = req.WithContext(adapter.WithState(req.Context(), state))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	send(`{"amount":10}`)
+	if rr := send(`{"amount":20}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a different state body, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,401 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iaconlabs/transwarp/internal/payload"
+)
+
+// Sentinel errors wrapped by PatchError. Use errors.Is to tell them apart.
+var (
+	// ErrInvalidPatch means the patch document is malformed (bad JSON, unknown
+	// operation, missing members or an invalid JSON Pointer).
+	ErrInvalidPatch = errors.New("invalid patch document")
+	// ErrPatchPath means an operation targets a location that does not exist.
+	ErrPatchPath = errors.New("patch path does not exist")
+	// ErrPatchTestFailed means a "test" operation did not match the resource.
+	ErrPatchTestFailed = errors.New("patch test operation failed")
+	// ErrPatchResult means the patched document no longer fits the resource type.
+	ErrPatchResult = errors.New("patched document does not match the resource")
+)
+
+// PatchError describes why a patch could not be applied.
+type PatchError struct {
+	// Index is the position of the failing operation in a JSON Patch document,
+	// or -1 when the error is not tied to an operation.
+	Index int
+	// Op is the failing JSON Patch operation (e.g. "replace").
+	Op string
+	// Path is the JSON Pointer the failing operation targets.
+	Path string
+	// Err is one of the sentinel errors, possibly wrapping the underlying cause.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *PatchError) Error() string {
+	if e.Index < 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("operation %d (%s %q): %v", e.Index, e.Op, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// decodeJSON decodes a document keeping numbers as json.Number, so integers
+// survive the round trip without losing precision.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after the JSON document")
+	}
+	return doc, nil
+}
+
+// applyMergePatch applies an RFC 7396 JSON Merge Patch to doc.
+func applyMergePatch(doc, patch any) any {
+	pm, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	target, ok := doc.(map[string]any)
+	if !ok {
+		target = make(map[string]any, len(pm))
+	}
+	for key, value := range pm {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		target[key] = applyMergePatch(target[key], value)
+	}
+	return target
+}
+
+// patchOperation is a single RFC 6902 operation.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyJSONPatch applies an RFC 6902 JSON Patch document to doc.
+func applyJSONPatch(doc any, patch []byte) (any, error) {
+	var ops []patchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, &PatchError{Index: -1, Err: fmt.Errorf("%w: %w", ErrInvalidPatch, err)}
+	}
+
+	for i, op := range ops {
+		var err error
+		doc, err = applyOperation(doc, op)
+		if err != nil {
+			path := ""
+			if op.Path != nil {
+				path = *op.Path
+			}
+			return nil, &PatchError{Index: i, Op: op.Op, Path: path, Err: err}
+		}
+	}
+	return doc, nil
+}
+
+// applyOperation applies one operation, returning the new document.
+func applyOperation(doc any, op patchOperation) (any, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: missing \"path\"", ErrInvalidPatch)
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	value := func() (any, error) {
+		// "value" must be present, although it may be null.
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: missing \"value\"", ErrInvalidPatch)
+		}
+		v, err := decodeJSON(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+		}
+		return v, nil
+	}
+	from := func() ([]string, error) {
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: missing \"from\"", ErrInvalidPatch)
+		}
+		return parsePointer(*op.From)
+	}
+
+	switch op.Op {
+	case "add":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return setValue(doc, path, v, true)
+
+	case "replace":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return setValue(doc, path, v, false)
+
+	case "remove":
+		doc, _, err := removeValue(doc, path)
+		return doc, err
+
+	case "move":
+		src, err := from()
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > len(src) && isPrefix(src, path) {
+			return nil, fmt.Errorf("%w: cannot move a value into one of its children", ErrInvalidPatch)
+		}
+		doc, v, err := removeValue(doc, src)
+		if err != nil {
+			return nil, err
+		}
+		return setValue(doc, path, v, true)
+
+	case "copy":
+		src, err := from()
+		if err != nil {
+			return nil, err
+		}
+		v, err := getValue(doc, src)
+		if err != nil {
+			return nil, err
+		}
+		return setValue(doc, path, deepCopy(v), true)
+
+	case "test":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		current, err := getValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !payload.Equal(current, v) {
+			return nil, ErrPatchTestFailed
+		}
+		return doc, nil
+
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("%w: JSON Pointer %q must start with \"/\"", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	unescaper := strings.NewReplacer("~1", "/", "~0", "~")
+	for i, tok := range tokens {
+		tokens[i] = unescaper.Replace(tok)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array reference token. When insert is true, "-" and
+// len(arr) are accepted to append.
+func arrayIndex(tok string, length int, insert bool) (int, error) {
+	if tok == "-" {
+		if insert {
+			return length, nil
+		}
+		return 0, fmt.Errorf("%w: \"-\" refers to a nonexistent element", ErrPatchPath)
+	}
+	// RFC 6901: no leading zeros and no signs.
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') || strings.ContainsAny(tok, "+-") {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrInvalidPatch, tok)
+	}
+	idx, err := strconv.Atoi(tok)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrInvalidPatch, tok)
+	}
+	limit := length
+	if insert {
+		limit++
+	}
+	if idx >= limit {
+		return 0, fmt.Errorf("%w: index %d out of range", ErrPatchPath, idx)
+	}
+	return idx, nil
+}
+
+// getValue returns the value at path.
+func getValue(doc any, path []string) (any, error) {
+	for _, tok := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			v, ok := node[tok]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q not found", ErrPatchPath, tok)
+			}
+			doc = v
+		case []any:
+			idx, err := arrayIndex(tok, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[idx]
+		default:
+			return nil, fmt.Errorf("%w: %q is not a container", ErrPatchPath, tok)
+		}
+	}
+	return doc, nil
+}
+
+// setValue adds (insert) or replaces the value at path and returns the new document.
+func setValue(doc any, path []string, value any, insert bool) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	tok, rest := path[0], path[1:]
+	switch node := doc.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			if _, ok := node[tok]; !ok && !insert {
+				return nil, fmt.Errorf("%w: member %q not found", ErrPatchPath, tok)
+			}
+			node[tok] = value
+			return node, nil
+		}
+		child, ok := node[tok]
+		if !ok {
+			return nil, fmt.Errorf("%w: member %q not found", ErrPatchPath, tok)
+		}
+		updated, err := setValue(child, rest, value, insert)
+		if err != nil {
+			return nil, err
+		}
+		node[tok] = updated
+		return node, nil
+
+	case []any:
+		idx, err := arrayIndex(tok, len(node), insert && len(rest) == 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) == 0 {
+			if !insert {
+				node[idx] = value
+				return node, nil
+			}
+			node = append(node, nil)
+			copy(node[idx+1:], node[idx:])
+			node[idx] = value
+			return node, nil
+		}
+		updated, err := setValue(node[idx], rest, value, insert)
+		if err != nil {
+			return nil, err
+		}
+		node[idx] = updated
+		return node, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %q is not a container", ErrPatchPath, tok)
+	}
+}
+
+// removeValue deletes the value at path, returning the new document and the removed value.
+func removeValue(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+	}
+
+	tok, rest := path[0], path[1:]
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[tok]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: member %q not found", ErrPatchPath, tok)
+		}
+		if len(rest) == 0 {
+			delete(node, tok)
+			return node, child, nil
+		}
+		updated, removed, err := removeValue(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		node[tok] = updated
+		return node, removed, nil
+
+	case []any:
+		idx, err := arrayIndex(tok, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := node[idx]
+			return append(node[:idx], node[idx+1:]...), removed, nil
+		}
+		updated, removed, err := removeValue(node[idx], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		node[idx] = updated
+		return node, removed, nil
+
+	default:
+		return nil, nil, fmt.Errorf("%w: %q is not a container", ErrPatchPath, tok)
+	}
+}
+
+// isPrefix reports whether prefix is a leading part of path.
+func isPrefix(prefix, path []string) bool {
+	for i, tok := range prefix {
+		if path[i] != tok {
+			return false
+		}
+	}
+	return true
+}
+
+// deepCopy clones a decoded JSON value so copies do not share containers.
+func deepCopy(v any) any {
+	switch node := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(node))
+		for k, child := range node {
+			out[k] = deepCopy(child)
+		}
+		return out
+	case []any:
+		out := make([]any, len(node))
+		for i, child := range node {
+			out[i] = deepCopy(child)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,115 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+type patchDocument struct {
+	Name  string            `json:"name"`
+	Items []string          `json:"items"`
+	Meta  map[string]string `json:"meta"`
+}
+
+// applyTestPatch applies a JSON Patch to a fixed document through ValidatePatch.
+func applyTestPatch(t *testing.T, patch string) (int, *patchDocument) {
+	t.Helper()
+	var got *patchDocument
+	req := httptest.NewRequest(http.MethodPatch, "/documents/1", strings.NewReader(patch))
+	req.Header.Set("Content-Type", middleware.JSONPatchContentType)
+	req = req.WithContext(context.WithValue(t.Context(), router.StateKey, &adapter.TranswarpState{Body: []byte(patch)}))
+
+	rr := httptest.NewRecorder()
+	middleware.ValidatePatch(func(*http.Request) (*patchDocument, error) {
+		return &patchDocument{
+			Name:  "doc",
+			Items: []string{"a", "b", "c"},
+			Meta:  map[string]string{"a/b": "slash", "m~n": "tilde"},
+		}, nil
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = r.Context().Value(router.ValidationKey).(*patchDocument)
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, req)
+	return rr.Code, got
+}
+
+// TestJSONPatch_Operations verifies RFC 6902 operations, array indexes and
+// RFC 6901 escapes.
+func TestJSONPatch_Operations(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  patchDocument
+	}{
+		{
+			"move to the end", `[{"op": "move", "from": "/items/0", "path": "/items/-"}]`,
+			patchDocument{Name: "doc", Items: []string{"b", "c", "a"}, Meta: map[string]string{"a/b": "slash", "m~n": "tilde"}},
+		},
+		{
+			"move between members", `[{"op": "move", "from": "/meta/a~1b", "path": "/name"}]`,
+			patchDocument{Name: "slash", Items: []string{"a", "b", "c"}, Meta: map[string]string{"m~n": "tilde"}},
+		},
+		{
+			"copy escaped member", `[{"op": "copy", "from": "/meta/m~0n", "path": "/items/1"}]`,
+			patchDocument{Name: "doc", Items: []string{"a", "tilde", "b", "c"}, Meta: map[string]string{"a/b": "slash", "m~n": "tilde"}},
+		},
+		{
+			"remove array element", `[{"op": "remove", "path": "/items/1"}]`,
+			patchDocument{Name: "doc", Items: []string{"a", "c"}, Meta: map[string]string{"a/b": "slash", "m~n": "tilde"}},
+		},
+		{
+			"remove escaped member", `[{"op": "remove", "path": "/meta/m~0n"}]`,
+			patchDocument{Name: "doc", Items: []string{"a", "b", "c"}, Meta: map[string]string{"a/b": "slash"}},
+		},
+		{
+			"add at length", `[{"op": "add", "path": "/items/3", "value": "d"}]`,
+			patchDocument{Name: "doc", Items: []string{"a", "b", "c", "d"}, Meta: map[string]string{"a/b": "slash", "m~n": "tilde"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, got := applyTestPatch(t, tt.patch)
+			if status != http.StatusOK {
+				t.Fatalf("Expected 200, got %d", status)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, *got)
+			}
+		})
+	}
+}
+
+// TestJSONPatch_InvalidPaths verifies the status of operations on invalid or
+// missing locations.
+func TestJSONPatch_InvalidPaths(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  int
+	}{
+		{"add past the end", `[{"op": "add", "path": "/items/4", "value": "x"}]`, http.StatusUnprocessableEntity},
+		{"remove out of range", `[{"op": "remove", "path": "/items/3"}]`, http.StatusUnprocessableEntity},
+		{"replace end marker", `[{"op": "replace", "path": "/items/-", "value": "x"}]`, http.StatusUnprocessableEntity},
+		{"leading zero", `[{"op": "remove", "path": "/items/01"}]`, http.StatusBadRequest},
+		{"negative index", `[{"op": "remove", "path": "/items/-1"}]`, http.StatusBadRequest},
+		{"unescaped member", `[{"op": "remove", "path": "/meta/a/b"}]`, http.StatusUnprocessableEntity},
+		{"move into child", `[{"op": "move", "from": "/meta", "path": "/meta/child"}]`, http.StatusBadRequest},
+		{"missing from", `[{"op": "copy", "path": "/name"}]`, http.StatusBadRequest},
+		{"relative pointer", `[{"op": "remove", "path": "name"}]`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _ := applyTestPatch(t, tt.patch); status != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, status)
+			}
+		})
+	}
+}
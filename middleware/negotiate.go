@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// qualityValue is a single entry of a comma-separated HTTP header with optional
+// q-values, such as Accept-Language or Accept-Encoding.
+type qualityValue struct {
+	value string
+	q     float64
+}
+
+// parseQualityList parses headers like "es-AR,es;q=0.9,en;q=0.5" into entries
+// sorted by descending quality. Entries with q=0 are explicitly refused and are
+// kept so callers can honor them; malformed q-values default to 1.
+func parseQualityList(header string) []qualityValue {
+	if header == "" {
+		return nil
+	}
+
+	parts := strings.Split(header, ",")
+	values := make([]qualityValue, 0, len(parts))
+	for _, part := range parts {
+		value, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for param := range strings.SplitSeq(params, ";") {
+			k, v, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.EqualFold(strings.TrimSpace(k), "q") {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && parsed >= 0 && parsed <= 1 {
+					q = parsed
+				}
+			}
+		}
+		values = append(values, qualityValue{value: value, q: q})
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].q > values[j].q
+	})
+	return values
+}
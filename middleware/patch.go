@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// Patch media types accepted by ValidatePatch.
+const (
+	// MergePatchContentType is the media type of RFC 7396 JSON Merge Patch documents.
+	MergePatchContentType = "application/merge-patch+json"
+	// JSONPatchContentType is the media type of RFC 6902 JSON Patch documents.
+	JSONPatchContentType = "application/json-patch+json"
+)
+
+// PatchLoader loads the current version of the resource targeted by a PATCH
+// request, typically using path parameters (see transwarp.Param). Returning a
+// *problem.Details (e.g. problem.New(http.StatusNotFound)) controls the response;
+// any other error results in a 500.
+type PatchLoader[T any] func(r *http.Request) (*T, error)
+
+// ValidatePatch returns a middleware for partial updates. It loads the current
+// resource with load, applies the request body as a JSON Merge Patch or a JSON
+// Patch (chosen by Content-Type), and then binds path parameters, transforms and
+// validates the merged result exactly like Validate. The patched *T is stored in
+// the request context under router.ValidationKey.
+//
+// Malformed patches are rejected with 400, failed "test" operations with 409,
+// other invalid operations with 422 and unsupported media types with 415. The
+// problem carries the failing operation index, op and path.
+//
+// ValidatePatch uses the default Validator; see ValidatePatchWith.
+func ValidatePatch[T any](load PatchLoader[T]) func(http.Handler) http.Handler {
+	return ValidatePatchWith(defaultValidator, load)
+}
+
+// ValidatePatchWith is like ValidatePatch, but validates with v.
+func ValidatePatchWith[T any](v *Validator, load PatchLoader[T]) func(http.Handler) http.Handler {
+	v.checkTransformers(reflect.TypeFor[T]())
+	checkPatchable(reflect.TypeFor[T](), reflect.TypeFor[T]().String(), make(map[reflect.Type]bool))
+	describe := func(d *router.RouteDescription) {
+		d.Request = reflect.TypeFor[T]()
+		d.RequestContentTypes = []string{MergePatchContentType, JSONPatchContentType}
+		d.AddError(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict,
+			http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity)
+	}
+
+	return func(next http.Handler) http.Handler {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			state, ok := r.Context().Value(router.StateKey).(*adapter.TranswarpState)
+			if !ok {
+				problem.Write(w, r, problem.New(http.StatusInternalServerError).WithDetail("Transwarp state not found"))
+				return
+			}
+
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if mediaType != MergePatchContentType && mediaType != JSONPatchContentType {
+				w.Header().Set("Accept-Patch", MergePatchContentType+", "+JSONPatchContentType)
+				problem.Write(w, r, problem.New(http.StatusUnsupportedMediaType).
+					WithDetail("PATCH requires "+MergePatchContentType+" or "+JSONPatchContentType))
+				return
+			}
+
+			current, err := load(r)
+			if err != nil {
+				problem.Write(w, r, problem.From(err))
+				return
+			}
+			if current == nil {
+				problem.NotFound(w, r)
+				return
+			}
+
+			target, err := applyPatch(current, mediaType, state.Body)
+			if err != nil {
+				problem.Write(w, r, patchProblem(err))
+				return
+			}
+
+			mapPathParams(target, state.Params)
+			mapClaims(target, r.Context().Value(router.ClaimsKey))
+			if !v.check(w, r, target) {
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), router.ValidationKey, target)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+		return &describedHandler{Handler: handler, describe: describe}
+	}
+}
+
+// applyPatch applies body to current and returns the result as a copy of
+// current, so fields hidden from JSON (json:"-" or unexported) keep their
+// stored values. current itself is never modified.
+func applyPatch[T any](current *T, mediaType string, body []byte) (*T, error) {
+	original, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeJSON(original)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mediaType {
+	case MergePatchContentType:
+		patch, err := decodeJSON(body)
+		if err != nil {
+			return nil, &PatchError{Index: -1, Err: fmt.Errorf("%w: %w", ErrInvalidPatch, err)}
+		}
+		doc = applyMergePatch(doc, patch)
+	default:
+		if doc, err = applyJSONPatch(doc, body); err != nil {
+			return nil, err
+		}
+	}
+
+	patched, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	decoded := new(T)
+	if err := json.Unmarshal(patched, decoded); err != nil {
+		return nil, &PatchError{Index: -1, Err: fmt.Errorf("%w: %w", ErrPatchResult, err)}
+	}
+	if reflect.TypeFor[T]().Kind() != reflect.Struct {
+		return decoded, nil
+	}
+	target := new(T)
+	*target = *current
+	copyJSONFields(reflect.ValueOf(target).Elem(), reflect.ValueOf(decoded).Elem())
+	return target, nil
+}
+
+// copyJSONFields sets the fields of struct dst that JSON encodes to their
+// values in src, keeping the other fields of dst. Nested structs, inline or
+// behind a pointer present in both, are merged in turn so they keep their
+// hidden fields too; the pointed-to struct of dst is copied, never modified.
+func copyJSONFields(dst, src reflect.Value) {
+	t := dst.Type()
+	for i := range t.NumField() {
+		f, df, sf := t.Field(i), dst.Field(i), src.Field(i)
+		if (!f.IsExported() && !f.Anonymous) || f.Tag.Get("json") == "-" {
+			continue
+		}
+		if !df.CanSet() {
+			// An unexported embedded struct cannot be set as a whole, but the
+			// exported fields it promotes can.
+			if df.Kind() == reflect.Struct {
+				copyJSONFields(df, sf)
+			}
+			continue
+		}
+		switch {
+		case df.Kind() == reflect.Struct && !isJSONUnmarshaler(df.Type()):
+			copyJSONFields(df, sf)
+		case df.Kind() == reflect.Pointer && !df.IsNil() && !sf.IsNil() &&
+			df.Type().Elem().Kind() == reflect.Struct && !isJSONUnmarshaler(df.Type().Elem()):
+			clone := reflect.New(df.Type().Elem())
+			clone.Elem().Set(df.Elem())
+			copyJSONFields(clone.Elem(), sf.Elem())
+			df.Set(clone)
+		default:
+			df.Set(sf)
+		}
+	}
+}
+
+// checkPatchable panics when typ embeds an unexported struct through a pointer:
+// encoding/json cannot allocate it, and its promoted fields could only be
+// patched by modifying the stored resource.
+func checkPatchable(typ reflect.Type, path string, seen map[reflect.Type]bool) {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || seen[typ] || isJSONUnmarshaler(typ) {
+		return
+	}
+	seen[typ] = true
+
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		if (!f.IsExported() && !f.Anonymous) || f.Tag.Get("json") == "-" {
+			continue
+		}
+		if !f.IsExported() && f.Type.Kind() == reflect.Pointer && f.Type.Elem().Kind() == reflect.Struct {
+			panic(fmt.Sprintf("transwarp: ValidatePatch cannot update the fields promoted by %s.%s; embed %s by value",
+				path, f.Name, f.Type.Elem()))
+		}
+		checkPatchable(f.Type, path+"."+fieldName(f), seen)
+	}
+}
+
+// isJSONUnmarshaler reports whether values of type t decode themselves.
+func isJSONUnmarshaler(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(reflect.TypeFor[json.Unmarshaler]())
+}
+
+// patchProblem maps patch errors to problems.
+func patchProblem(err error) *problem.Details {
+	var pe *PatchError
+	if !errors.As(err, &pe) {
+		return problem.New(http.StatusInternalServerError)
+	}
+
+	status := http.StatusUnprocessableEntity
+	switch {
+	case errors.Is(err, ErrInvalidPatch):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrPatchTestFailed):
+		status = http.StatusConflict
+	}
+
+	p := problem.New(status).WithDetail(pe.Error())
+	if pe.Index >= 0 {
+		p.With("operation", pe.Index).With("op", pe.Op).With("path", pe.Path)
+	}
+	return p
+}
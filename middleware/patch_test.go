@@ -0,0 +1,229 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+type ArticleResource struct {
+	ID    string   `json:"id" param:"id" validate:"required"`
+	Title string   `json:"title" mod:"trim" validate:"required,min=3"`
+	Body  string   `json:"body"`
+	Views int64    `json:"views"`
+	Tags  []string `json:"tags" validate:"max=3"`
+}
+
+// articleLoader simulates a repository lookup keyed by the "id" path parameter.
+func articleLoader(r *http.Request) (*ArticleResource, error) {
+	state, _ := r.Context().Value(router.StateKey).(*adapter.TranswarpState)
+	if state.Params["id"] != "42" {
+		return nil, problem.New(http.StatusNotFound).WithDetail("article not found")
+	}
+	return &ArticleResource{ID: "42", Title: "Warp drives", Body: "draft", Views: 9007199254740993, Tags: []string{"go"}}, nil
+}
+
+// servePatch sends a PATCH request with the given media type through ValidatePatch.
+func servePatch(t *testing.T, id, contentType, body string) (*httptest.ResponseRecorder, *ArticleResource) {
+	t.Helper()
+	var got *ArticleResource
+	req := httptest.NewRequest(http.MethodPatch, "/articles/"+id, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	state := &adapter.TranswarpState{Params: map[string]string{"id": id}, Body: []byte(body)}
+	req = req.WithContext(context.WithValue(t.Context(), router.StateKey, state))
+
+	rr := httptest.NewRecorder()
+	middleware.ValidatePatch(articleLoader)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = r.Context().Value(router.ValidationKey).(*ArticleResource)
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, req)
+	return rr, got
+}
+
+// TestValidatePatch_MergePatch verifies RFC 7396 semantics: members are replaced,
+// null removes them, and untouched fields keep their stored values.
+func TestValidatePatch_MergePatch(t *testing.T) {
+	rr, got := servePatch(t, "42", middleware.MergePatchContentType, `{"title": "  Warp cores ", "body": null}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got.Title != "Warp cores" || got.Body != "" || got.Views != 9007199254740993 || got.ID != "42" {
+		t.Errorf("Unexpected merge result: %+v", got)
+	}
+
+	// The merged result is validated as a whole.
+	rr, _ = servePatch(t, "42", middleware.MergePatchContentType, `{"title": "no"}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for an invalid merged result, got %d", rr.Code)
+	}
+}
+
+// TestValidatePatch_JSONPatch verifies RFC 6902 operations.
+func TestValidatePatch_JSONPatch(t *testing.T) {
+	body := `[
+		{"op": "test", "path": "/title", "value": "Warp drives"},
+		{"op": "add", "path": "/tags/-", "value": "physics"},
+		{"op": "add", "path": "/tags/0", "value": "featured"},
+		{"op": "copy", "from": "/title", "path": "/body"},
+		{"op": "replace", "path": "/views", "value": 10}
+	]`
+	rr, got := servePatch(t, "42", middleware.JSONPatchContentType, body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if strings.Join(got.Tags, ",") != "featured,go,physics" || got.Body != "Warp drives" || got.Views != 10 {
+		t.Errorf("Unexpected patch result: %+v", got)
+	}
+}
+
+// TestValidatePatch_Errors verifies the status and typed details of rejected patches.
+func TestValidatePatch_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          string
+		contentType string
+		body        string
+		wantStatus  int
+		wantOp      string
+	}{
+		{"Malformed document", "42", middleware.JSONPatchContentType, `{"op": "add"}`, http.StatusBadRequest, ""},
+		{"Unknown operation", "42", middleware.JSONPatchContentType, `[{"op": "merge", "path": "/title"}]`, http.StatusBadRequest, "merge"},
+		{"Missing path", "42", middleware.JSONPatchContentType, `[{"op": "remove", "path": "/nope"}]`, http.StatusUnprocessableEntity, "remove"},
+		{"Failed test", "42", middleware.JSONPatchContentType, `[{"op": "test", "path": "/views", "value": 1}]`, http.StatusConflict, "test"},
+		{"Wrong type", "42", middleware.MergePatchContentType, `{"views": "many"}`, http.StatusUnprocessableEntity, ""},
+		{"Plain JSON", "42", "application/json", `{}`, http.StatusUnsupportedMediaType, ""},
+		{"Unknown resource", "7", middleware.MergePatchContentType, `{}`, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, _ := servePatch(t, tt.id, tt.contentType, tt.body)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			var p problem.Details
+			_ = json.Unmarshal(rr.Body.Bytes(), &p)
+			if op, _ := p.Extensions["op"].(string); op != tt.wantOp {
+				t.Errorf("Expected op %q, got %q", tt.wantOp, op)
+			}
+		})
+	}
+}
+
+// TestPatchError_Is verifies that PatchError wraps the sentinel errors.
+func TestPatchError_Is(t *testing.T) {
+	err := error(&middleware.PatchError{Index: 0, Op: "test", Path: "/a", Err: middleware.ErrPatchTestFailed})
+	if !errors.Is(err, middleware.ErrPatchTestFailed) || errors.Is(err, middleware.ErrInvalidPatch) {
+		t.Errorf("Unexpected error chain for %v", err)
+	}
+}
+
+type accountAudit struct {
+	CreatedBy string `json:"-"`
+	Note      string `json:"note"`
+}
+
+type AccountResource struct {
+	Name         string `json:"name" validate:"required"`
+	PasswordHash string `json:"-"`
+	internalID   int64
+	Audit        accountAudit  `json:"audit"`
+	Owner        *accountAudit `json:"owner,omitempty"`
+}
+
+func (a *AccountResource) ID() int64 { return a.internalID }
+
+// TestValidatePatch_HiddenFields verifies that fields hidden from JSON keep
+// their stored values and that the loaded resource is not modified.
+func TestValidatePatch_HiddenFields(t *testing.T) {
+	stored := &AccountResource{
+		Name: "ada", PasswordHash: "$2a$10$hash", internalID: 7,
+		Audit: accountAudit{CreatedBy: "admin", Note: "v1"},
+		Owner: &accountAudit{CreatedBy: "root", Note: "o1"},
+	}
+	var got *AccountResource
+	body := `{"name": "grace", "audit": {"note": "v2"}, "owner": {"note": "o2"}}`
+	req := httptest.NewRequest(http.MethodPatch, "/accounts/7", strings.NewReader(body))
+	req.Header.Set("Content-Type", middleware.MergePatchContentType)
+	req = req.WithContext(adapter.WithState(req.Context(), &adapter.TranswarpState{Body: []byte(body)}))
+
+	rr := httptest.NewRecorder()
+	middleware.ValidatePatch(func(*http.Request) (*AccountResource, error) {
+		copied := *stored
+		return &copied, nil
+	})(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got, _ = r.Context().Value(router.ValidationKey).(*AccountResource)
+	})).ServeHTTP(rr, req)
+
+	if got == nil {
+		t.Fatalf("Expected the patch to pass, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got.Name != "grace" || got.PasswordHash != "$2a$10$hash" || got.ID() != 7 {
+		t.Errorf("Unexpected patch result: %+v", got)
+	}
+	if got.Audit != (accountAudit{CreatedBy: "admin", Note: "v2"}) || *got.Owner != (accountAudit{CreatedBy: "root", Note: "o2"}) {
+		t.Errorf("Expected nested hidden fields to be kept, got %+v %+v", got.Audit, got.Owner)
+	}
+	if stored.Owner.Note != "o1" {
+		t.Error("The loaded resource must not be modified")
+	}
+}
+
+type itemBase struct {
+	Name    string `json:"name"`
+	Version int    `json:"-"`
+}
+
+type ItemResource struct {
+	itemBase
+	Qty int `json:"qty"`
+}
+
+// TestValidatePatch_UnexportedEmbedded verifies that fields promoted by an
+// unexported embedded struct are patched and keep their hidden siblings.
+func TestValidatePatch_UnexportedEmbedded(t *testing.T) {
+	var got *ItemResource
+	body := `{"name": "new", "qty": 2}`
+	req := httptest.NewRequest(http.MethodPatch, "/items/1", strings.NewReader(body))
+	req.Header.Set("Content-Type", middleware.MergePatchContentType)
+	req = req.WithContext(adapter.WithState(req.Context(), &adapter.TranswarpState{Body: []byte(body)}))
+
+	rr := httptest.NewRecorder()
+	middleware.ValidatePatch(func(*http.Request) (*ItemResource, error) {
+		return &ItemResource{itemBase: itemBase{Name: "old", Version: 3}, Qty: 1}, nil
+	})(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got, _ = r.Context().Value(router.ValidationKey).(*ItemResource)
+	})).ServeHTTP(rr, req)
+
+	if got == nil {
+		t.Fatalf("Expected the patch to pass, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got.Name != "new" || got.Version != 3 || got.Qty != 2 {
+		t.Errorf("Unexpected patch result: %+v", got)
+	}
+}
+
+// TestValidatePatch_UnexportedEmbeddedPointer verifies that resources embedding
+// an unexported struct through a pointer are rejected when the middleware is built.
+func TestValidatePatch_UnexportedEmbeddedPointer(t *testing.T) {
+	type PointerItem struct {
+		*itemBase
+		Qty int `json:"qty"`
+	}
+
+	defer func() {
+		if msg, _ := recover().(string); !strings.Contains(msg, "itemBase") {
+			t.Errorf("Unexpected panic: %q", msg)
+		}
+	}()
+	middleware.ValidatePatch(func(*http.Request) (*PointerItem, error) { return nil, nil })
+}
@@ -0,0 +1,296 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// RateLimitState is the per-key state of a rate limiter, persisted by a
+// RateLimitStore. Its meaning depends on the algorithm: a token bucket keeps the
+// available tokens in Count and the last refill in Start; a sliding window keeps
+// the requests of the current and previous windows in Count and Prev, and the
+// start of the current window in Start.
+type RateLimitState struct {
+	Count float64   `json:"count"`
+	Prev  float64   `json:"prev,omitempty"`
+	Start time.Time `json:"start"`
+}
+
+// RateLimitStore persists rate limit state, e.g. in memory (see
+// MemoryRateLimitStore) or in Redis to share limits between instances.
+type RateLimitStore interface {
+	// Update atomically loads the state stored under key (the zero value when
+	// missing or expired), applies fn to it and stores the result for ttl.
+	// Stores using optimistic concurrency may call fn more than once; only the
+	// last call must take effect.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(*RateLimitState)) error
+}
+
+// RateLimitKeyFunc returns the key a request is limited by. Requests for which
+// it returns "" are not limited.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitByIP limits requests per client IP. trustedProxies is the number of
+// reverse proxies in front of the application that append to X-Forwarded-For
+// (0 when it faces clients directly); the IP is then taken from the entry added
+// by the outermost of them, so clients cannot escape the limit by sending
+// their own X-Forwarded-For.
+func RateLimitByIP(trustedProxies int) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return clientIP(r, max(trustedProxies, 0))
+	}
+}
+
+// RateLimitByHeader limits requests per value of a header, e.g. an API key.
+func RateLimitByHeader(name string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// RateLimitByParam limits requests per value of a path parameter. The
+// middleware must be registered on the route (or a group) so that the
+// parameter has been extracted.
+func RateLimitByParam(name string) RateLimitKeyFunc {
+	return stateValue(name)
+}
+
+// RateLimitByState limits requests per value stored in the request state with
+// transwarp.SetStateValue, e.g. a tenant or user resolved by an earlier middleware.
+func RateLimitByState(key string) RateLimitKeyFunc {
+	return stateValue(key)
+}
+
+// stateValue reads a value from the request state, where path parameters and
+// values set by middlewares share the same map.
+func stateValue(key string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		state, ok := r.Context().Value(router.StateKey).(*adapter.TranswarpState)
+		if !ok || state == nil {
+			return ""
+		}
+		return state.Params[key]
+	}
+}
+
+// RateLimitOption configures RateLimit.
+type RateLimitOption func(*rateLimitConfig)
+
+// rateLimitConfig holds the settings of a RateLimit middleware.
+type rateLimitConfig struct {
+	limit    int
+	window   time.Duration
+	burst    int
+	sliding  bool
+	perRoute bool
+	key      RateLimitKeyFunc
+	store    RateLimitStore
+}
+
+// WithRateLimitKey sets how requests are grouped (per client IP by default).
+func WithRateLimitKey(fn RateLimitKeyFunc) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		if fn != nil {
+			c.key = fn
+		}
+	}
+}
+
+// WithRateLimitStore sets where the limiter state is kept. The default is a
+// MemoryRateLimitStore private to the middleware.
+func WithRateLimitStore(s RateLimitStore) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		if s != nil {
+			c.store = s
+		}
+	}
+}
+
+// WithRateLimitBurst sets the capacity of the token bucket, i.e. how many
+// requests may be made at once after a quiet period. It defaults to the limit.
+func WithRateLimitBurst(n int) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		if n > 0 {
+			c.burst = n
+		}
+	}
+}
+
+// WithRateLimitSlidingWindow uses a sliding window counter instead of a token
+// bucket: at most limit requests in any window, without bursts at window edges.
+func WithRateLimitSlidingWindow() RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.sliding = true
+	}
+}
+
+// WithRateLimitPerRoute keeps a separate limit for every route pattern, so a
+// middleware registered with Use limits each endpoint independently.
+func WithRateLimitPerRoute() RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.perRoute = true
+	}
+}
+
+// RateLimit returns a middleware allowing limit requests per window and key
+// (see WithRateLimitKey). By default it uses a token bucket refilled at
+// limit/window, so clients may burst up to the limit. Every response carries
+// the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers; rejected requests get a 429 problem with
+// Retry-After. If the store fails, the request is logged and let through.
+//
+//	api.POST("/login", login, middleware.RateLimit(5, time.Minute,
+//		middleware.WithRateLimitSlidingWindow()))
+func RateLimit(limit int, window time.Duration, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	if limit <= 0 || window <= 0 {
+		panic("transwarp: RateLimit requires a positive limit and window")
+	}
+	cfg := &rateLimitConfig{limit: limit, window: window, burst: limit, key: RateLimitByIP(0)}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.store == nil {
+		cfg.store = NewMemoryRateLimitStore(0)
+	}
+
+	// The policy namespaces keys, so limiters with different settings can share a store.
+	policy := strconv.Itoa(limit) + ";w=" + strconv.Itoa(int(math.Ceil(window.Seconds())))
+	namespace := fmt.Sprintf("tb:%d/%d/%s:", limit, cfg.burst, window)
+	if cfg.sliding {
+		namespace = fmt.Sprintf("sw:%d/%s:", limit, window)
+	} else if cfg.burst != limit {
+		policy += ";burst=" + strconv.Itoa(cfg.burst)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := cfg.key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if cfg.perRoute {
+				key = adapter.RoutePattern(r) + " " + key
+			}
+
+			d, err := cfg.take(r.Context(), namespace+key, time.Now())
+			if err != nil {
+				slog.ErrorContext(r.Context(), "transwarp: rate limit store failed", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(cfg.limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(seconds(d.reset)))
+			h.Set("RateLimit-Policy", policy)
+			if !d.allowed {
+				retry := seconds(d.retryAfter)
+				h.Set("Retry-After", strconv.Itoa(retry))
+				problem.Write(w, r, problem.New(http.StatusTooManyRequests).
+					WithDetail(fmt.Sprintf("Rate limit exceeded, retry in %d seconds", retry)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateDecision is the outcome of a rate limit check.
+type rateDecision struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration // until the limit is fully available again
+	retryAfter time.Duration // until the next request is allowed, when denied
+}
+
+// take counts one request for key at now.
+func (c *rateLimitConfig) take(ctx context.Context, key string, now time.Time) (rateDecision, error) {
+	var d rateDecision
+	ttl := c.window * 2
+	fn := func(s *RateLimitState) { d = c.tokenBucket(s, now) }
+	if c.sliding {
+		fn = func(s *RateLimitState) { d = c.slidingWindow(s, now) }
+	} else {
+		ttl = time.Duration(float64(c.window) * float64(c.burst) / float64(c.limit))
+	}
+	err := c.store.Update(ctx, key, ttl, fn)
+	return d, err
+}
+
+// tokenBucket refills the bucket for the time elapsed since the last request
+// and takes a token if one is available.
+func (c *rateLimitConfig) tokenBucket(s *RateLimitState, now time.Time) rateDecision {
+	capacity := float64(c.burst)
+	perToken := float64(c.window) / float64(c.limit) // nanoseconds per token
+
+	tokens := capacity
+	if !s.Start.IsZero() {
+		elapsed := max(now.Sub(s.Start), 0)
+		tokens = min(capacity, s.Count+float64(elapsed)/perToken)
+	}
+
+	d := rateDecision{allowed: tokens >= 1}
+	if d.allowed {
+		tokens--
+	} else {
+		d.retryAfter = time.Duration((1 - tokens) * perToken)
+	}
+	s.Count, s.Start = tokens, now
+
+	d.remaining = int(tokens)
+	d.reset = time.Duration((capacity - tokens) * perToken)
+	return d
+}
+
+// slidingWindow approximates the number of requests in the last window by
+// weighting the previous fixed window by its overlap, and counts the request
+// if it stays within the limit.
+func (c *rateLimitConfig) slidingWindow(s *RateLimitState, now time.Time) rateDecision {
+	start := now.Truncate(c.window)
+	switch {
+	case s.Start.Equal(start):
+	case s.Start.Add(c.window).Equal(start):
+		s.Prev, s.Count = s.Count, 0
+	default:
+		s.Prev, s.Count = 0, 0
+	}
+	s.Start = start
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(c.window)
+	limit := float64(c.limit)
+	estimate := s.Prev*weight + s.Count
+
+	d := rateDecision{allowed: estimate+1 <= limit, reset: c.window - elapsed}
+	if d.allowed {
+		s.Count++
+		estimate++
+	} else if s.Count+1 <= limit {
+		// The previous window must fade until estimate+1 fits in the limit.
+		fade := 1 - (limit-1-s.Count)/s.Prev
+		d.retryAfter = time.Duration(fade*float64(c.window)) - elapsed
+	} else {
+		// This window is exhausted: wait for the next one, where it becomes
+		// the previous window and must fade as well.
+		fade := max(1-(limit-1)/s.Count, 0)
+		d.retryAfter = c.window - elapsed + time.Duration(fade*float64(c.window))
+	}
+	d.remaining = max(int(limit-estimate), 0)
+	return d
+}
+
+// seconds rounds d up to whole seconds, as used by the rate limit headers.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(max(d, 0).Seconds()))
+}
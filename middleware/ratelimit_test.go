@@ -0,0 +1,286 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
+)
+
+// fakeRedisStore mimics a remote store: state is serialized between calls and
+// fn runs twice on the first update of a key, like an optimistic transaction
+// retried after a conflict.
+type fakeRedisStore struct {
+	mu      sync.Mutex
+	data    map[string][]byte
+	retried map[string]bool
+	calls   int
+}
+
+func newFakeRedisStore() *fakeRedisStore {
+	return &fakeRedisStore{data: map[string][]byte{}, retried: map[string]bool{}}
+}
+
+func (f *fakeRedisStore) Update(_ context.Context, key string, _ time.Duration, fn func(*middleware.RateLimitState)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+
+	load := func() *middleware.RateLimitState {
+		s := &middleware.RateLimitState{}
+		if raw, ok := f.data[key]; ok {
+			_ = json.Unmarshal(raw, s)
+		}
+		return s
+	}
+	if !f.retried[key] {
+		f.retried[key] = true
+		fn(load()) // discarded attempt
+	}
+	s := load()
+	fn(s)
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	f.data[key] = raw
+	return nil
+}
+
+// serveRateLimit sends req through mw to a handler answering 200.
+func serveRateLimit(mw func(http.Handler) http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	mw(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, req)
+	return rr
+}
+
+func rateLimitRequest(ip string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.RemoteAddr = ip + ":4000"
+	return req
+}
+
+// TestRateLimit_TokenBucket verifies the headers of allowed requests and the
+// 429 problem once the bucket is empty, per client IP.
+func TestRateLimit_TokenBucket(t *testing.T) {
+	mw := middleware.RateLimit(3, time.Minute)
+
+	for i := 2; i >= 0; i-- {
+		rr := serveRateLimit(mw, rateLimitRequest("10.0.0.1"))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", 3-i, rr.Code)
+		}
+		if got := rr.Header().Get("RateLimit-Remaining"); got != strconv.Itoa(i) {
+			t.Errorf("Expected %d remaining, got %s", i, got)
+		}
+		if rr.Header().Get("RateLimit-Limit") != "3" || rr.Header().Get("RateLimit-Policy") != "3;w=60" {
+			t.Errorf("Unexpected limit headers: %v", rr.Header())
+		}
+	}
+
+	rr := serveRateLimit(mw, rateLimitRequest("10.0.0.1"))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "20" {
+		t.Errorf("Expected Retry-After 20 (one token every 20s), got %q", got)
+	}
+	if got := rr.Header().Get("RateLimit-Reset"); got != "60" {
+		t.Errorf("Expected the bucket to be full again in 60s, got %q", got)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected a problem response, got %q", ct)
+	}
+
+	if rr := serveRateLimit(mw, rateLimitRequest("10.0.0.2")); rr.Code != http.StatusOK {
+		t.Errorf("Other clients must have their own limit, got %d", rr.Code)
+	}
+}
+
+// TestRateLimit_Refill verifies that tokens are refilled over time.
+func TestRateLimit_Refill(t *testing.T) {
+	mw := middleware.RateLimit(10, 200*time.Millisecond, middleware.WithRateLimitBurst(1))
+
+	if rr := serveRateLimit(mw, rateLimitRequest("10.0.0.1")); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	rr := serveRateLimit(mw, rateLimitRequest("10.0.0.1"))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("A burst of 1 must reject the second request, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("RateLimit-Policy"); got != "10;w=1;burst=1" {
+		t.Errorf("Unexpected policy: %q", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if rr := serveRateLimit(mw, rateLimitRequest("10.0.0.1")); rr.Code != http.StatusOK {
+		t.Errorf("Expected a refilled token, got %d", rr.Code)
+	}
+}
+
+// TestRateLimit_SlidingWindow verifies that the sliding window allows at most
+// limit requests and reports when the next one will be allowed.
+func TestRateLimit_SlidingWindow(t *testing.T) {
+	mw := middleware.RateLimit(2, time.Hour, middleware.WithRateLimitSlidingWindow())
+
+	for i := range 2 {
+		if rr := serveRateLimit(mw, rateLimitRequest("10.0.0.1")); rr.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i+1, rr.Code)
+		}
+	}
+	rr := serveRateLimit(mw, rateLimitRequest("10.0.0.1"))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", rr.Code)
+	}
+	retry, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+	if err != nil || retry <= 0 || retry > 2*3600 {
+		t.Errorf("Unexpected Retry-After %q", rr.Header().Get("Retry-After"))
+	}
+	if rr.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected 0 remaining, got %q", rr.Header().Get("RateLimit-Remaining"))
+	}
+}
+
+// TestRateLimit_Keys verifies header, path parameter and state keys, and that
+// requests without a key are not limited.
+func TestRateLimit_Keys(t *testing.T) {
+	withParams := func(req *http.Request, params map[string]string) *http.Request {
+		state := &adapter.TranswarpState{Params: params, Route: "/tenants/:tenant"}
+		return req.WithContext(adapter.WithState(req.Context(), state))
+	}
+
+	cases := map[string]struct {
+		key   middleware.RateLimitKeyFunc
+		first *http.Request
+		other *http.Request
+	}{
+		"header": {
+			key:   middleware.RateLimitByHeader("X-API-Key"),
+			first: func() *http.Request { r := rateLimitRequest("10.0.0.1"); r.Header.Set("X-API-Key", "a"); return r }(),
+			other: func() *http.Request { r := rateLimitRequest("10.0.0.1"); r.Header.Set("X-API-Key", "b"); return r }(),
+		},
+		"param": {
+			key:   middleware.RateLimitByParam("tenant"),
+			first: withParams(rateLimitRequest("10.0.0.1"), map[string]string{"tenant": "acme"}),
+			other: withParams(rateLimitRequest("10.0.0.1"), map[string]string{"tenant": "globex"}),
+		},
+		"state": {
+			key:   middleware.RateLimitByState("user"),
+			first: withParams(rateLimitRequest("10.0.0.1"), map[string]string{"user": "u1"}),
+			other: withParams(rateLimitRequest("10.0.0.1"), map[string]string{"user": "u2"}),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mw := middleware.RateLimit(1, time.Minute, middleware.WithRateLimitKey(tc.key))
+			serveRateLimit(mw, tc.first)
+			if rr := serveRateLimit(mw, tc.first.Clone(tc.first.Context())); rr.Code != http.StatusTooManyRequests {
+				t.Errorf("Expected the same key to be limited, got %d", rr.Code)
+			}
+			if rr := serveRateLimit(mw, tc.other); rr.Code != http.StatusOK {
+				t.Errorf("Expected another key to be allowed, got %d", rr.Code)
+			}
+			for range 3 {
+				if rr := serveRateLimit(mw, rateLimitRequest("10.0.0.1")); rr.Code != http.StatusOK {
+					t.Errorf("Requests without a key must not be limited, got %d", rr.Code)
+				}
+			}
+		})
+	}
+}
+
+// TestRateLimit_SpoofedForwardedFor verifies that behind a proxy, clients
+// cannot escape the limit by sending their own X-Forwarded-For.
+func TestRateLimit_SpoofedForwardedFor(t *testing.T) {
+	mw := middleware.RateLimit(1, time.Minute, middleware.WithRateLimitKey(middleware.RateLimitByIP(1)))
+	// The proxy at 10.0.0.1 appends the address it sees, 203.0.113.9.
+	forwarded := func(spoofed string) *http.Request {
+		req := rateLimitRequest("10.0.0.1")
+		req.Header.Set("X-Forwarded-For", spoofed+", 203.0.113.9")
+		return req
+	}
+
+	serveRateLimit(mw, forwarded("198.51.100.1"))
+	if rr := serveRateLimit(mw, forwarded("198.51.100.2")); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected a spoofed X-Forwarded-For to be ignored, got %d", rr.Code)
+	}
+	other := rateLimitRequest("10.0.0.1")
+	other.Header.Set("X-Forwarded-For", "203.0.113.10")
+	if rr := serveRateLimit(mw, other); rr.Code != http.StatusOK {
+		t.Errorf("Expected another client behind the proxy to be allowed, got %d", rr.Code)
+	}
+}
+
+// TestRateLimit_PerRoute verifies that each route pattern has its own limit.
+func TestRateLimit_PerRoute(t *testing.T) {
+	mw := middleware.RateLimit(1, time.Minute, middleware.WithRateLimitPerRoute())
+	route := func(pattern string) *http.Request {
+		req := rateLimitRequest("10.0.0.1")
+		return req.WithContext(adapter.WithState(req.Context(), &adapter.TranswarpState{Route: pattern}))
+	}
+
+	serveRateLimit(mw, route("/users"))
+	if rr := serveRateLimit(mw, route("/users")); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected /users to be limited, got %d", rr.Code)
+	}
+	if rr := serveRateLimit(mw, route("/orders")); rr.Code != http.StatusOK {
+		t.Errorf("Expected /orders to have its own limit, got %d", rr.Code)
+	}
+}
+
+// TestRateLimit_SharedStore verifies that limiters sharing a store share their
+// counts, and that only the last call of a retried update takes effect.
+func TestRateLimit_SharedStore(t *testing.T) {
+	store := newFakeRedisStore()
+	a := middleware.RateLimit(2, time.Minute, middleware.WithRateLimitStore(store))
+	b := middleware.RateLimit(2, time.Minute, middleware.WithRateLimitStore(store))
+
+	if rr := serveRateLimit(a, rateLimitRequest("10.0.0.1")); rr.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("A retried update must count once, got %q remaining", rr.Header().Get("RateLimit-Remaining"))
+	}
+	serveRateLimit(b, rateLimitRequest("10.0.0.1"))
+	if rr := serveRateLimit(a, rateLimitRequest("10.0.0.1")); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the shared limit to be exhausted, got %d", rr.Code)
+	}
+
+	sliding := middleware.RateLimit(2, time.Minute, middleware.WithRateLimitStore(store), middleware.WithRateLimitSlidingWindow())
+	if rr := serveRateLimit(sliding, rateLimitRequest("10.0.0.1")); rr.Code != http.StatusOK {
+		t.Errorf("Limiters with other policies must not share state, got %d", rr.Code)
+	}
+	if store.calls != 4 {
+		t.Errorf("Expected 4 store updates, got %d", store.calls)
+	}
+}
+
+// TestMemoryRateLimitStore_Cleanup verifies that expired entries are swept.
+func TestMemoryRateLimitStore_Cleanup(t *testing.T) {
+	store := middleware.NewMemoryRateLimitStore(time.Millisecond)
+	for i := range 100 {
+		_ = store.Update(context.Background(), strconv.Itoa(i), time.Millisecond, func(s *middleware.RateLimitState) { s.Count++ })
+	}
+	if store.Len() != 100 {
+		t.Fatalf("Expected 100 entries, got %d", store.Len())
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	var count float64
+	_ = store.Update(context.Background(), "0", time.Minute, func(s *middleware.RateLimitState) {
+		s.Count++
+		count = s.Count
+	})
+	if count != 1 {
+		t.Errorf("Expired state must be reset, got count %v", count)
+	}
+	if store.Len() != 1 {
+		t.Errorf("Expected expired entries to be swept, got %d", store.Len())
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimitShards is the number of independently locked partitions of a
+// MemoryRateLimitStore, so concurrent requests for different keys rarely contend.
+const rateLimitShards = 64
+
+var _ RateLimitStore = &MemoryRateLimitStore{}
+
+// MemoryRateLimitStore is an in-process RateLimitStore. Keys are spread over
+// sharded maps, and expired entries are removed by periodic sweeps performed
+// during updates, so no background goroutine is needed.
+type MemoryRateLimitStore struct {
+	seed      maphash.Seed
+	shards    [rateLimitShards]rateLimitShard
+	cleanup   time.Duration
+	nextSweep atomic.Int64 // unix nanoseconds
+}
+
+type rateLimitShard struct {
+	mu      sync.Mutex
+	entries map[string]rateLimitEntry
+}
+
+type rateLimitEntry struct {
+	state   RateLimitState
+	expires time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty store that sweeps expired entries
+// every cleanup interval (one minute when zero or negative).
+func NewMemoryRateLimitStore(cleanup time.Duration) *MemoryRateLimitStore {
+	if cleanup <= 0 {
+		cleanup = time.Minute
+	}
+	s := &MemoryRateLimitStore{seed: maphash.MakeSeed(), cleanup: cleanup}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]rateLimitEntry)
+	}
+	s.nextSweep.Store(time.Now().Add(cleanup).UnixNano())
+	return s
+}
+
+// Update implements RateLimitStore.
+func (s *MemoryRateLimitStore) Update(_ context.Context, key string, ttl time.Duration, fn func(*RateLimitState)) error {
+	now := time.Now()
+	s.maybeSweep(now)
+
+	shard := &s.shards[maphash.String(s.seed, key)%rateLimitShards]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, ok := shard.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		entry = rateLimitEntry{}
+	}
+	fn(&entry.state)
+	entry.expires = now.Add(ttl)
+	shard.entries[key] = entry
+	return nil
+}
+
+// Len returns the number of keys held, including expired ones not yet swept.
+func (s *MemoryRateLimitStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		n += len(shard.entries)
+		shard.mu.Unlock()
+	}
+	return n
+}
+
+// maybeSweep removes expired entries when the cleanup interval has elapsed.
+// Only the caller that advances the deadline performs the sweep.
+func (s *MemoryRateLimitStore) maybeSweep(now time.Time) {
+	next := s.nextSweep.Load()
+	if now.UnixNano() < next || !s.nextSweep.CompareAndSwap(next, now.Add(s.cleanup).UnixNano()) {
+		return
+	}
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		for key, entry := range shard.entries {
+			if !now.Before(entry.expires) {
+				delete(shard.entries, key)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
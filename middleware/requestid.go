@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// RequestIDHeader is the default header carrying the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds incoming IDs so they are safe to log and echo.
+const maxRequestIDLength = 128
+
+// RequestIDOption configures RequestID.
+type RequestIDOption func(*requestIDConfig)
+
+// requestIDConfig holds the settings of a RequestID middleware.
+type requestIDConfig struct {
+	header   string
+	generate func() string
+	trust    bool
+}
+
+// WithRequestIDHeader sets the header read from the request and echoed in the
+// response (X-Request-ID by default).
+func WithRequestIDHeader(name string) RequestIDOption {
+	return func(c *requestIDConfig) {
+		if name != "" {
+			c.header = http.CanonicalHeaderKey(name)
+		}
+	}
+}
+
+// WithRequestIDGenerator sets the function that creates new IDs. The default
+// returns 26 random base32 characters (crypto/rand.Text).
+func WithRequestIDGenerator(fn func() string) RequestIDOption {
+	return func(c *requestIDConfig) {
+		if fn != nil {
+			c.generate = fn
+		}
+	}
+}
+
+// IgnoreIncomingRequestID always generates a new ID, ignoring the one sent by
+// the client. Use it when the service is not behind a trusted proxy.
+func IgnoreIncomingRequestID() RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.trust = false
+	}
+}
+
+// RequestID returns a middleware that assigns every request an ID. An ID sent
+// by the client in the X-Request-ID header is reused when it is at most 128
+// printable ASCII characters; otherwise a new one is generated. The ID is
+// stored in the request context (see GetRequestID) and echoed in the response
+// header.
+//
+// Once set, the ID is added automatically to problem responses (as the
+// "request_id" member), to the panic logs of transwarp.Recovery and to any slog
+// record logged with the request context through a handler wrapped with
+// NewLogHandler.
+func RequestID(opts ...RequestIDOption) func(http.Handler) http.Handler {
+	cfg := requestIDConfig{header: RequestIDHeader, generate: rand.Text, trust: true}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := ""
+			if cfg.trust {
+				id = r.Header.Get(cfg.header)
+			}
+			if !validRequestID(id) {
+				id = cfg.generate()
+			}
+
+			w.Header().Set(cfg.header, id)
+			r.Header.Set(cfg.header, id)
+			next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// WithRequestID returns a copy of ctx carrying id. It is useful to propagate a
+// request ID to background work or to code outside an HTTP handler.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, router.RequestIDKey, id)
+}
+
+// GetRequestID returns the request ID stored in ctx by RequestID, or an empty
+// string.
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(router.RequestIDKey).(string)
+	return id
+}
+
+// validRequestID reports whether id is non-empty, bounded and printable ASCII,
+// which keeps client-supplied IDs from injecting content into logs or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := range len(id) {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// LogHandler is a slog.Handler that adds request-scoped attributes, such as the
+// request ID, to records logged with a request context (slog.InfoContext and
+// friends). Create it with NewLogHandler.
+type LogHandler struct {
+	next slog.Handler
+}
+
+// NewLogHandler wraps next so that records carry the "request_id" attribute
+// of their context:
+//
+//	slog.SetDefault(slog.New(middleware.NewLogHandler(slog.NewJSONHandler(os.Stdout, nil))))
+func NewLogHandler(next slog.Handler) *LogHandler {
+	return &LogHandler{next: next}
+}
+
+// Enabled reports whether the wrapped handler handles records at level.
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle adds the request ID of ctx, unless the record already has one, and
+// passes the record to the wrapped handler.
+func (h *LogHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if id := GetRequestID(ctx); id != "" && !hasAttr(rec, "request_id") {
+		rec = rec.Clone()
+		rec.AddAttrs(slog.String("request_id", id))
+	}
+	return h.next.Handle(ctx, rec)
+}
+
+// WithAttrs returns a LogHandler wrapping next.WithAttrs(attrs).
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup returns a LogHandler wrapping next.WithGroup(name).
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{next: h.next.WithGroup(name)}
+}
+
+func hasAttr(rec slog.Record, key string) bool {
+	found := false
+	rec.Attrs(func(a slog.Attr) bool {
+		found = a.Key == key
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,108 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/middleware"
+)
+
+// serveRequestID runs a request with the given X-Request-ID through mw and
+// returns the response and the ID seen by the handler.
+func serveRequestID(mw func(http.Handler) http.Handler, incoming string) (*httptest.ResponseRecorder, string) {
+	var seen string
+	h := mw(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		seen = middleware.GetRequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if incoming != "" {
+		req.Header.Set("X-Request-ID", incoming)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr, seen
+}
+
+// TestRequestID verifies that incoming IDs are reused, invalid ones replaced,
+// and that the ID is echoed in the response.
+func TestRequestID(t *testing.T) {
+	mw := middleware.RequestID()
+
+	rr, seen := serveRequestID(mw, "abc-123")
+	if seen != "abc-123" || rr.Header().Get("X-Request-ID") != "abc-123" {
+		t.Errorf("Expected the incoming ID to be reused, got %q / %q", seen, rr.Header().Get("X-Request-ID"))
+	}
+
+	for _, incoming := range []string{"", "bad id\nforged: log", strings.Repeat("x", 129)} {
+		rr, seen := serveRequestID(mw, incoming)
+		if seen == "" || seen == incoming {
+			t.Errorf("Expected a generated ID for %q, got %q", incoming, seen)
+		}
+		if rr.Header().Get("X-Request-ID") != seen {
+			t.Errorf("Response header %q does not match the context ID %q", rr.Header().Get("X-Request-ID"), seen)
+		}
+	}
+}
+
+// TestRequestID_Options verifies the custom header, generator and IgnoreIncomingRequestID.
+func TestRequestID_Options(t *testing.T) {
+	mw := middleware.RequestID(
+		middleware.WithRequestIDHeader("x-correlation-id"),
+		middleware.WithRequestIDGenerator(func() string { return "generated" }),
+		middleware.IgnoreIncomingRequestID(),
+	)
+
+	h := mw(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Correlation-ID", "from-client")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("X-Correlation-ID"); got != "generated" {
+		t.Errorf("Expected the generated ID in X-Correlation-ID, got %q", got)
+	}
+	if rr.Header().Get("X-Request-ID") != "" {
+		t.Error("The default header must not be set when a custom one is configured")
+	}
+}
+
+// TestRequestID_ProblemsAndLogs verifies that problem responses and slog records
+// logged with the request context carry the ID.
+func TestRequestID_ProblemsAndLogs(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(middleware.NewLogHandler(slog.NewJSONHandler(&logs, nil)))
+
+	validate := middleware.ValidateResponse(UserResponse{},
+		middleware.StrictResponses(), middleware.WithResponseLogger(logger))
+	h := middleware.RequestID()(validate(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": "nope"}`))
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	var body map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid problem body: %v", err)
+	}
+	if body["request_id"] != "req-42" {
+		t.Errorf("Expected request_id in the problem, got %s", rr.Body.String())
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("Invalid log record %q: %v", logs.String(), err)
+	}
+	if record["request_id"] != "req-42" {
+		t.Errorf("Expected request_id in the log record, got %s", logs.String())
+	}
+}
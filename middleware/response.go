@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// ResponseOption configures ValidateResponse.
+type ResponseOption func(*responseConfig)
+
+// responseConfig holds the settings of a response validation middleware.
+type responseConfig struct {
+	strict bool
+	logger *slog.Logger
+}
+
+// StrictResponses makes contract violations fail the request with a 500 problem
+// listing the offending fields, instead of only logging them. Intended for
+// development and staging environments.
+func StrictResponses() ResponseOption {
+	return func(c *responseConfig) {
+		c.strict = true
+	}
+}
+
+// WithResponseLogger sets the logger used to report violations (slog.Default by default).
+func WithResponseLogger(l *slog.Logger) ResponseOption {
+	return func(c *responseConfig) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// ValidateResponse returns a middleware that enforces the API contract on the
+// way out. It buffers the handler's response and, for successful (2xx) responses
+// with a body, decodes it into a new instance of T and runs the validation rules.
+// T is a struct, or a slice, array or map of structs for list endpoints, whose
+// elements are validated in turn; other types panic. Violations are logged; with
+// StrictResponses the client gets a 500 problem instead of the invalid payload.
+//
+// Buffering disables streaming for the route, so this middleware is intended for
+// development and staging. ValidateResponse uses the default Validator; see
+// Validator.ValidateResponse for isolated rule sets.
+func ValidateResponse[T any](_ T, opts ...ResponseOption) func(http.Handler) http.Handler {
+	return defaultValidator.responseMiddleware(func() any { return new(T) }, opts)
+}
+
+// ValidateResponse returns a middleware equivalent to the package-level
+// ValidateResponse, bound to this Validator's rules. The dto argument is only
+// used for its type; an untyped nil panics.
+func (v *Validator) ValidateResponse(dto any, opts ...ResponseOption) func(http.Handler) http.Handler {
+	if dto == nil {
+		panic("transwarp: ValidateResponse requires a non-nil DTO")
+	}
+	typ := reflect.TypeOf(dto)
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return v.responseMiddleware(func() any { return reflect.New(typ).Interface() }, opts)
+}
+
+// responseCheck returns the validation run on decoded responses of type typ:
+// structs are validated as a whole, and the elements of lists and maps one by
+// one. It panics for other types, whose rules could never be enforced.
+func (v *Validator) responseCheck(typ reflect.Type) func(ctx context.Context, target any) error {
+	switch typ.Kind() {
+	case reflect.Struct:
+		return v.engine.StructCtx
+	case reflect.Slice, reflect.Array, reflect.Map:
+		elem := typ.Elem()
+		if elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct {
+			return func(ctx context.Context, target any) error {
+				return v.engine.VarCtx(ctx, reflect.ValueOf(target).Elem().Interface(), "dive")
+			}
+		}
+	}
+	panic(fmt.Sprintf("transwarp: ValidateResponse requires a struct, or a slice, array or map of structs, got %s", typ))
+}
+
+// responseMiddleware builds the response validation handler.
+func (v *Validator) responseMiddleware(newTarget func() any, opts []ResponseOption) func(http.Handler) http.Handler {
+	cfg := &responseConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	responseType := reflect.TypeOf(newTarget()).Elem()
+	validate := v.responseCheck(responseType)
+	describe := func(d *router.RouteDescription) {
+		d.Response = responseType
+	}
+
+	return func(next http.Handler) http.Handler {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bw := newBufferedWriter(w)
+			next.ServeHTTP(bw, r)
+
+			if bw.status < 200 || bw.status > 299 || bw.body.Len() == 0 {
+				bw.flush()
+				return
+			}
+
+			var details []ValidationError
+			target := newTarget()
+			if err := json.Unmarshal(bw.body.Bytes(), target); err != nil {
+				details = []ValidationError{{Rule: "json", Message: err.Error()}}
+			} else if err := validate(r.Context(), target); err != nil {
+				details = v.formatter(err, v.translatorFor(r))
+			}
+
+			if details == nil {
+				bw.flush()
+				return
+			}
+
+			logger := cfg.logger
+			if logger == nil {
+				logger = slog.Default()
+			}
+			logger.WarnContext(r.Context(), "response contract violation",
+				"method", r.Method, "path", r.URL.Path, "status", bw.status, "errors", details)
+
+			if !cfg.strict {
+				bw.flush()
+				return
+			}
+			problem.Write(w, r, problem.New(http.StatusInternalServerError).
+				WithDetail("The response does not match the API contract").
+				With("errors", details))
+		})
+		return &describedHandler{Handler: handler, describe: describe}
+	}
+}
@@ -0,0 +1,116 @@
+package middleware_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/middleware"
+)
+
+type UserResponse struct {
+	ID    string `json:"id" validate:"required,uuid"`
+	Email string `json:"email" validate:"required,email"`
+}
+
+// serveResponse runs handler behind mw and returns the recorded response.
+func serveResponse(mw func(http.Handler) http.Handler, status int, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	mw(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	})).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	return rr
+}
+
+// TestValidateResponse_LogsViolations verifies that, by default, invalid payloads
+// are delivered unchanged and reported through the logger.
+func TestValidateResponse_LogsViolations(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	mw := middleware.ValidateResponse(UserResponse{}, middleware.WithResponseLogger(logger))
+
+	body := `{"id": "not-a-uuid", "email": "dev@transwarp.io"}`
+	rr := serveResponse(mw, http.StatusOK, body)
+	if rr.Code != http.StatusOK || rr.Body.String() != body {
+		t.Errorf("Response altered in log mode: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Handler headers lost: %v", rr.Header())
+	}
+	if !strings.Contains(logs.String(), "response contract violation") || !strings.Contains(logs.String(), "uuid") {
+		t.Errorf("Violation not logged: %s", logs.String())
+	}
+}
+
+// TestValidateResponse_Strict verifies that strict mode replaces invalid payloads.
+func TestValidateResponse_Strict(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	mw := middleware.ValidateResponse(UserResponse{}, middleware.StrictResponses(), middleware.WithResponseLogger(logger))
+
+	rr := serveResponse(mw, http.StatusOK, `{"id": "6f1c2a9e-3b1d-4c5e-9f0a-1b2c3d4e5f60"}`)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected problem+json, got %q", ct)
+	}
+	if !strings.Contains(rr.Body.String(), `"field":"email"`) {
+		t.Errorf("Expected the offending field in the problem: %s", rr.Body.String())
+	}
+
+	// Valid payloads and error responses pass through untouched.
+	valid := `{"id": "6f1c2a9e-3b1d-4c5e-9f0a-1b2c3d4e5f60", "email": "dev@transwarp.io"}`
+	if rr := serveResponse(mw, http.StatusOK, valid); rr.Code != http.StatusOK || rr.Body.String() != valid {
+		t.Errorf("Valid response altered: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := serveResponse(mw, http.StatusNotFound, `{"oops": true}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Error response altered: %d", rr.Code)
+	}
+}
+
+// TestValidateResponse_List verifies that the elements of list responses are
+// validated and reported with their index.
+func TestValidateResponse_List(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	mw := middleware.ValidateResponse([]UserResponse{}, middleware.StrictResponses(), middleware.WithResponseLogger(logger))
+
+	valid := `[{"id": "6f1c2a9e-3b1d-4c5e-9f0a-1b2c3d4e5f60", "email": "dev@transwarp.io"}]`
+	if rr := serveResponse(mw, http.StatusOK, valid); rr.Code != http.StatusOK {
+		t.Errorf("Valid list altered: %d %s", rr.Code, rr.Body.String())
+	}
+
+	invalid := `[{"id": "6f1c2a9e-3b1d-4c5e-9f0a-1b2c3d4e5f60", "email": "dev@transwarp.io"}, {"id": "x", "email": ""}]`
+	rr := serveResponse(mw, http.StatusOK, invalid)
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), `"field":"[1].email"`) {
+		t.Errorf("Expected the invalid element to be reported, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	pointers := middleware.NewValidator().ValidateResponse([]*UserResponse{}, middleware.StrictResponses(), middleware.WithResponseLogger(logger))
+	if rr := serveResponse(pointers, http.StatusOK, `[{"id": "x"}]`); rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected pointer elements to be validated, got %d", rr.Code)
+	}
+}
+
+// TestValidateResponse_InvalidDTO verifies that DTOs whose rules cannot be
+// enforced panic when the middleware is built.
+func TestValidateResponse_InvalidDTO(t *testing.T) {
+	for name, build := range map[string]func(){
+		"nil":     func() { middleware.NewValidator().ValidateResponse(nil) },
+		"strings": func() { middleware.ValidateResponse([]string{}) },
+		"scalar":  func() { middleware.ValidateResponse(0) },
+	} {
+		func() {
+			defer func() {
+				if msg, _ := recover().(string); !strings.HasPrefix(msg, "transwarp: ValidateResponse requires") {
+					t.Errorf("%s: unexpected panic %q", name, msg)
+				}
+			}()
+			build()
+		}()
+	}
+}
@@ -0,0 +1,330 @@
+package middleware
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Transformer modifies a field in place before validation runs. field is always
+// settable and never a pointer; param holds the text after "=" in the tag
+// (e.g. "10" for default=10). Returning an error rejects the request with a 422
+// problem that reports the field and the transformer name as the rule.
+type Transformer func(field reflect.Value, param string) error
+
+// StringTransformer adapts a string function into a Transformer for string fields.
+func StringTransformer(fn func(string) string) Transformer {
+	return func(field reflect.Value, _ string) error {
+		if field.Kind() != reflect.String {
+			return fmt.Errorf("requires a string field, got %s", field.Kind())
+		}
+		field.SetString(fn(field.String()))
+		return nil
+	}
+}
+
+// WithTransformer registers a custom "mod" tag on the Validator.
+func WithTransformer(tag string, fn Transformer) ValidatorOption {
+	return func(v *Validator) {
+		v.RegisterTransformer(tag, fn)
+	}
+}
+
+// RegisterTransformer adds (or replaces) a "mod" tag on this Validator only.
+func (v *Validator) RegisterTransformer(tag string, fn Transformer) {
+	v.transformers[tag] = transformer{fn: fn}
+}
+
+// transformer is a registered "mod" tag. check, when set, reports whether the
+// tag (with its param) can apply to fields of a type; it runs when a middleware
+// is built, so misuse fails at startup instead of on every request.
+type transformer struct {
+	fn    Transformer
+	check func(typ reflect.Type, param string) error
+}
+
+// defaultTransformers returns the transformers available out of the box.
+func defaultTransformers() map[string]transformer {
+	stringOnly := func(fn func(string) string) transformer {
+		return transformer{fn: StringTransformer(fn), check: func(typ reflect.Type, _ string) error {
+			if typ.Kind() != reflect.String {
+				return fmt.Errorf("requires a string field, got %s", typ)
+			}
+			return nil
+		}}
+	}
+	return map[string]transformer{
+		"trim":       stringOnly(strings.TrimSpace),
+		"lower":      stringOnly(strings.ToLower),
+		"upper":      stringOnly(strings.ToUpper),
+		"title":      stringOnly(titleCase),
+		"strip_html": stringOnly(stripHTML),
+		"default": {fn: setDefault, check: func(typ reflect.Type, param string) error {
+			return setDefault(reflect.New(typ).Elem(), param)
+		}},
+	}
+}
+
+// checkTransformers verifies the "mod" tags of typ and the types it contains:
+// every tag must be registered and accept the type of its field. Misuse is a
+// programming error, so it panics naming the field.
+func (v *Validator) checkTransformers(typ reflect.Type) {
+	v.checkTransformersOf(typ, typ.String(), make(map[reflect.Type]bool))
+}
+
+func (v *Validator) checkTransformersOf(typ reflect.Type, path string, seen map[reflect.Type]bool) {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || seen[typ] {
+		return
+	}
+	seen[typ] = true
+
+	for i := range typ.NumField() {
+		sf := typ.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fieldPath := path + "." + fieldName(sf)
+		fieldType := sf.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		for tag := range strings.SplitSeq(sf.Tag.Get("mod"), ",") {
+			name, param, _ := strings.Cut(strings.TrimSpace(tag), "=")
+			if name == "" {
+				continue
+			}
+			t, ok := v.transformers[name]
+			if !ok {
+				panic(fmt.Sprintf("transwarp: undefined transformer %q on field %s", name, fieldPath))
+			}
+			if t.check != nil {
+				if err := t.check(fieldType, param); err != nil {
+					panic(fmt.Sprintf("transwarp: transformer %q cannot apply to field %s: %v", name, fieldPath, err))
+				}
+			}
+		}
+		v.checkTransformersOf(sf.Type, fieldPath, seen)
+	}
+}
+
+// transformError reports a transformer that rejected a field.
+type transformError struct {
+	path string
+	tag  string
+	err  error
+}
+
+func (e *transformError) Error() string {
+	return fmt.Sprintf("transform %q on %s: %v", e.tag, e.path, e.err)
+}
+
+// transform applies the "mod" tags of target, which must be a pointer to a struct,
+// descending into nested structs, pointers, slices, arrays and maps.
+func (v *Validator) transform(target any) error {
+	return v.transformValue(reflect.ValueOf(target), "")
+}
+
+// transformValue walks val. ns is the dotted namespace of val (e.g. "items[3]").
+func (v *Validator) transformValue(val reflect.Value, ns string) error {
+	switch val.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if val.IsNil() {
+			return nil
+		}
+		return v.transformValue(val.Elem(), ns)
+
+	case reflect.Slice, reflect.Array:
+		if !hasStructs(val.Type().Elem()) {
+			return nil
+		}
+		for i := range val.Len() {
+			if err := v.transformValue(val.Index(i), fmt.Sprintf("%s[%d]", ns, i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if !hasStructs(val.Type().Elem()) {
+			return nil
+		}
+		iter := val.MapRange()
+		for iter.Next() {
+			// Map values are not addressable: transform a copy and store it back.
+			elem := reflect.New(iter.Value().Type()).Elem()
+			elem.Set(iter.Value())
+			if err := v.transformValue(elem, fmt.Sprintf("%s[%v]", ns, iter.Key())); err != nil {
+				return err
+			}
+			val.SetMapIndex(iter.Key(), elem)
+		}
+
+	case reflect.Struct:
+		typ := val.Type()
+		for i := range typ.NumField() {
+			sf := typ.Field(i)
+			f := val.Field(i)
+			if !sf.IsExported() || !f.CanSet() {
+				continue
+			}
+
+			path := fieldName(sf)
+			if ns != "" {
+				path = ns + "." + path
+			}
+			if tags := sf.Tag.Get("mod"); tags != "" {
+				if err := v.applyTransformers(f, tags, path); err != nil {
+					return err
+				}
+			}
+			if err := v.transformValue(f, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyTransformers runs the comma-separated transformers of a "mod" tag in order.
+func (v *Validator) applyTransformers(field reflect.Value, tags, path string) error {
+	for _, tag := range strings.Split(tags, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(tag), "=")
+		if name == "" {
+			continue
+		}
+		t, ok := v.transformers[name]
+		if !ok {
+			// Only reachable through interface fields, which checkTransformers
+			// cannot see when the middleware is built.
+			panic(fmt.Sprintf("transwarp: undefined transformer %q on field %s", name, path))
+		}
+
+		target := field
+		if target.Kind() == reflect.Pointer {
+			if target.IsNil() {
+				if name != "default" {
+					continue
+				}
+				target.Set(reflect.New(target.Type().Elem()))
+			}
+			target = target.Elem()
+		}
+		if err := t.fn(target, param); err != nil {
+			return &transformError{path: path, tag: name, err: err}
+		}
+	}
+	return nil
+}
+
+// hasStructs reports whether values of typ may contain fields with "mod" tags.
+func hasStructs(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct || typ.Kind() == reflect.Interface
+}
+
+// setDefault assigns param to the field when it holds its zero value.
+func setDefault(field reflect.Value, param string) error {
+	if !field.IsZero() {
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(param)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(param)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(param, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(param, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(param, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("default is not supported for %s fields", field.Kind())
+	}
+	return nil
+}
+
+// titleCase upper-cases the first letter of every word and lower-cases the rest.
+func titleCase(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	start := true
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '-' {
+			start = true
+			b.WriteRune(r)
+			continue
+		}
+		if start {
+			b.WriteRune(unicode.ToUpper(r))
+			start = false
+		} else {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// stripHTML removes HTML tags and comments, keeping the text between them.
+// The result is plain text; it is not a substitute for output escaping.
+func stripHTML(s string) string {
+	if !strings.ContainsRune(s, '<') {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i == -1 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+
+		// A "<" that does not open a tag (e.g. "a < b") is kept as text.
+		next, _ := utf8.DecodeRuneInString(s[1:])
+		if !unicode.IsLetter(next) && next != '/' && next != '!' && next != '?' {
+			b.WriteByte('<')
+			s = s[1:]
+			continue
+		}
+
+		end := ">"
+		if strings.HasPrefix(s, "<!--") {
+			end = "-->"
+		}
+		j := strings.Index(s, end)
+		if j == -1 {
+			// Unterminated tag: drop the remainder.
+			break
+		}
+		s = s[j+len(end):]
+	}
+	return b.String()
+}
@@ -0,0 +1,187 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+type MemberAddress struct {
+	City string `json:"city" mod:"trim,title" validate:"required"`
+}
+
+// MemberRequest exercises built-in transformers on top-level and nested fields.
+type MemberRequest struct {
+	Email     string          `json:"email" mod:"trim,lower" validate:"required,email"`
+	Country   string          `json:"country" mod:"trim,upper" validate:"len=2"`
+	Bio       string          `json:"bio" mod:"strip_html,trim"`
+	PageSize  int             `json:"page_size" mod:"default=10" validate:"min=1,max=100"`
+	Nickname  *string         `json:"nickname" mod:"default=anonymous"`
+	Addresses []MemberAddress `json:"addresses" validate:"dive"`
+}
+
+// serveCapture runs mw and returns the response together with the validated target.
+func serveCapture[T any](t *testing.T, mw func(http.Handler) http.Handler, body string) (*httptest.ResponseRecorder, *T) {
+	t.Helper()
+	var got *T
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	state := &adapter.TranswarpState{Body: []byte(body)}
+	req = req.WithContext(context.WithValue(t.Context(), router.StateKey, state))
+
+	rr := httptest.NewRecorder()
+	mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = r.Context().Value(router.ValidationKey).(*T)
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, req)
+	return rr, got
+}
+
+// TestTransform_BeforeValidation verifies that inputs are normalized after binding
+// and before the validation rules run.
+func TestTransform_BeforeValidation(t *testing.T) {
+	body := `{
+		"email": "  Admin@Transwarp.IO ",
+		"country": " ar",
+		"bio": "<p>Hello <b>world</b></p><script>x</script> 1 < 2",
+		"addresses": [{"city": "  buenos aIRES "}]
+	}`
+
+	rr, got := serveCapture[MemberRequest](t, middleware.Validate(MemberRequest{}), body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	want := MemberRequest{
+		Email:     "admin@transwarp.io",
+		Country:   "AR",
+		Bio:       "Hello worldx 1 < 2",
+		PageSize:  10,
+		Addresses: []MemberAddress{{City: "Buenos Aires"}},
+	}
+	if got.Nickname == nil || *got.Nickname != "anonymous" {
+		t.Errorf("Default not applied to pointer field: %v", got.Nickname)
+	}
+	got.Nickname = nil
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("Unexpected result:\n got  %+v\n want %+v", *got, want)
+	}
+}
+
+// TestTransform_DefaultKeepsValues verifies that default only fills zero values.
+func TestTransform_DefaultKeepsValues(t *testing.T) {
+	rr, got := serveCapture[MemberRequest](t, middleware.Validate(MemberRequest{}),
+		`{"email": "a@b.co", "country": "uy", "page_size": 50, "nickname": "neo"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got.PageSize != 50 || *got.Nickname != "neo" {
+		t.Errorf("Default overwrote provided values: %+v", got)
+	}
+}
+
+type ContactRequest struct {
+	Phone string `json:"phone" mod:"phone" validate:"e164"`
+}
+
+// TestTransform_CustomTransformer verifies custom transformers and the reporting
+// of a rejected transformation.
+func TestTransform_CustomTransformer(t *testing.T) {
+	v := middleware.NewValidator(middleware.WithTransformer("phone", middleware.StringTransformer(func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if (r >= '0' && r <= '9') || r == '+' {
+				return r
+			}
+			return -1
+		}, s)
+	})))
+
+	rr, got := serveCapture[ContactRequest](t, v.Validate(ContactRequest{}), `{"phone": "+54 (11) 5555-1234"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got.Phone != "+541155551234" {
+		t.Errorf("Custom transformer not applied: %q", got.Phone)
+	}
+
+	// The default Validator does not know the custom tag registered above.
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for an undefined transformer")
+		}
+	}()
+	serveCapture[ContactRequest](t, middleware.Validate(ContactRequest{}), `{"phone": "1"}`)
+}
+
+type PagingRequest struct {
+	Limit string `json:"limit" mod:"digits"`
+}
+
+// TestTransform_ErrorProblem verifies that failing transformers produce a 422 problem.
+func TestTransform_ErrorProblem(t *testing.T) {
+	v := middleware.NewValidator(middleware.WithTransformer("digits", func(field reflect.Value, _ string) error {
+		if strings.Trim(field.String(), "0123456789") != "" {
+			return errors.New("only digits are allowed")
+		}
+		return nil
+	}))
+	rr, _ := serveCapture[PagingRequest](t, v.Validate(PagingRequest{}), `{"limit": "many"}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d", rr.Code)
+	}
+
+	var res struct {
+		Errors []middleware.ValidationError `json:"errors"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if len(res.Errors) != 1 || res.Errors[0].Field != "limit" || res.Errors[0].Rule != "digits" {
+		t.Errorf("Unexpected errors: %+v", res.Errors)
+	}
+}
+
+// TestTransform_CheckedAtBuild verifies that misused "mod" tags panic when the
+// middleware is built, naming the field.
+func TestTransform_CheckedAtBuild(t *testing.T) {
+	type nested struct {
+		Count int `json:"count" mod:"trim"`
+	}
+	cases := map[string]struct {
+		build func()
+		field string
+	}{
+		"unknown tag": {func() {
+			middleware.Validate(struct {
+				Name string `json:"name" mod:"shout"`
+			}{})
+		}, ".name"},
+		"string transformer on an int": {func() {
+			middleware.Validate(struct {
+				Items []nested `json:"items"`
+			}{})
+		}, ".items.count"},
+		"invalid default": {func() {
+			middleware.Validate(struct {
+				Limit *int `json:"limit" mod:"default=many"`
+			}{})
+		}, ".limit"},
+	}
+	for name, tc := range cases {
+		func() {
+			defer func() {
+				msg, _ := recover().(string)
+				if !strings.Contains(msg, tc.field) {
+					t.Errorf("%s: expected a panic naming %s, got %q", name, tc.field, msg)
+				}
+			}()
+			tc.build()
+		}()
+	}
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/pt"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	esTranslations "github.com/go-playground/validator/v10/translations/es"
+	ptTranslations "github.com/go-playground/validator/v10/translations/pt"
+)
+
+// defaultLocale is used when the request does not ask for a supported language.
+const defaultLocale = "en"
+
+// TranslationRegistrar registers the built-in messages of a locale on a validation
+// engine. The RegisterDefaultTranslations functions found in
+// github.com/go-playground/validator/v10/translations/* satisfy this signature.
+type TranslationRegistrar func(v *validator.Validate, trans ut.Translator) error
+
+// WithLocale adds a supported language to the Validator. If register is not nil it
+// is used to load the built-in messages for that locale.
+// It panics if the translations cannot be registered, as this is a programming error.
+func WithLocale(tr locales.Translator, register TranslationRegistrar) ValidatorOption {
+	return func(v *Validator) {
+		if err := v.addLocale(tr, register); err != nil {
+			panic(fmt.Sprintf("transwarp: cannot register locale %q: %v", tr.Locale(), err))
+		}
+	}
+}
+
+// WithDefaultLocale sets the locale used when Accept-Language does not match any
+// supported language. The locale must be registered; "en" is used otherwise.
+func WithDefaultLocale(locale string) ValidatorOption {
+	return func(v *Validator) {
+		v.fallbackLocale = strings.ToLower(locale)
+	}
+}
+
+// RegisterTranslation adds (or overrides) the message for a validation tag in the
+// given locale. In text, {0} is replaced by the field name and {1} by the rule
+// parameter, e.g. "{0} debe comenzar con {1}".
+func (v *Validator) RegisterTranslation(tag, locale, text string) error {
+	trans, found := v.uni.GetTranslator(locale)
+	if !found {
+		return fmt.Errorf("transwarp: locale %q is not registered", locale)
+	}
+
+	return v.engine.RegisterTranslation(tag, trans,
+		func(t ut.Translator) error {
+			return t.Add(tag, text, true)
+		},
+		func(t ut.Translator, fe validator.FieldError) string {
+			msg, err := t.T(fe.Tag(), fe.Field(), fe.Param())
+			if err != nil {
+				return fe.Error()
+			}
+			return msg
+		},
+	)
+}
+
+// initTranslations registers the languages supported out of the box.
+func (v *Validator) initTranslations() {
+	v.uni = ut.New(en.New())
+	v.fallbackLocale = defaultLocale
+
+	builtin := []struct {
+		tr       locales.Translator
+		register TranslationRegistrar
+	}{
+		{en.New(), enTranslations.RegisterDefaultTranslations},
+		{es.New(), esTranslations.RegisterDefaultTranslations},
+		{pt.New(), ptTranslations.RegisterDefaultTranslations},
+	}
+	for _, b := range builtin {
+		if err := v.addLocale(b.tr, b.register); err != nil {
+			panic(fmt.Sprintf("transwarp: cannot register locale %q: %v", b.tr.Locale(), err))
+		}
+	}
+}
+
+// addLocale makes a locale available for negotiation and loads its messages.
+func (v *Validator) addLocale(tr locales.Translator, register TranslationRegistrar) error {
+	if err := v.uni.AddTranslator(tr, true); err != nil {
+		return err
+	}
+	if register == nil {
+		return nil
+	}
+	trans, _ := v.uni.GetTranslator(tr.Locale())
+	return register(v.engine, trans)
+}
+
+// translatorFor selects the translator that best matches the request's
+// Accept-Language header, falling back to the default locale.
+func (v *Validator) translatorFor(r *http.Request) ut.Translator {
+	for _, lang := range parseQualityList(r.Header.Get("Accept-Language")) {
+		if lang.q == 0 {
+			continue
+		}
+		if lang.value == "*" {
+			break
+		}
+		for _, candidate := range localeCandidates(lang.value) {
+			if trans, found := v.uni.GetTranslator(candidate); found {
+				return trans
+			}
+		}
+	}
+
+	if trans, found := v.uni.GetTranslator(v.fallbackLocale); found {
+		return trans
+	}
+	return v.uni.GetFallback()
+}
+
+// localeCandidates turns a language tag such as "pt-BR" into the translator keys
+// to try, from most to least specific: "pt_br", "pt".
+func localeCandidates(tag string) []string {
+	normalized := strings.ReplaceAll(tag, "-", "_")
+	base, _, found := strings.Cut(normalized, "_")
+	if !found {
+		return []string{normalized}
+	}
+	return []string{normalized, base}
+}
+
+// translateMessage renders the message of a field error in the language of
+// trans. When that language has no message for the tag, the default locale's
+// message is used (including custom ones added with RegisterTranslation), and
+// then the English built-in message.
+func (v *Validator) translateMessage(fe validator.FieldError, trans ut.Translator) string {
+	if trans != nil {
+		if msg := fe.Translate(trans); msg != fe.Error() {
+			return msg
+		}
+	}
+	if fallback, found := v.uni.GetTranslator(v.fallbackLocale); found && fallback != trans {
+		if msg := fe.Translate(fallback); msg != fe.Error() {
+			return msg
+		}
+	}
+	return createMsgForTag(fe)
+}
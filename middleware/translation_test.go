@@ -0,0 +1,111 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// ProfileRequest is a minimal DTO used to inspect translated messages.
+type ProfileRequest struct {
+	Name string `json:"name" validate:"required"`
+	SKU  string `json:"sku" validate:"sku_format"`
+}
+
+// firstMessage runs the middleware with the given Accept-Language and returns the
+// Content-Language header and the message of the first validation error.
+func firstMessage(t *testing.T, v *middleware.Validator, body, acceptLanguage string) (string, string) {
+	t.Helper()
+	state := &adapter.TranswarpState{Body: []byte(body)}
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Accept-Language", acceptLanguage)
+	req = req.WithContext(context.WithValue(t.Context(), router.StateKey, state))
+
+	rr := httptest.NewRecorder()
+	v.Validate(ProfileRequest{})(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		t.Fatal("Handler should not run for invalid input")
+	})).ServeHTTP(rr, req)
+
+	var response struct {
+		Errors []middleware.ValidationError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || len(response.Errors) == 0 {
+		t.Fatalf("Unexpected response: %s", rr.Body.String())
+	}
+	return rr.Header().Get("Content-Language"), response.Errors[0].Message
+}
+
+func skuRule(fl validator.FieldLevel) bool {
+	return strings.HasPrefix(fl.Field().String(), "TW-")
+}
+
+// TestTranslation_AcceptLanguage verifies that messages follow the negotiated language.
+func TestTranslation_AcceptLanguage(t *testing.T) {
+	v := middleware.NewValidator(middleware.WithRule("sku_format", skuRule))
+
+	cases := []struct {
+		header   string
+		locale   string
+		contains string
+	}{
+		{"es-AR,es;q=0.9,en;q=0.5", "es", "es un campo requerido"},
+		{"pt-BR", "pt", "obrigatório"},
+		{"fr;q=0.9,en;q=0.8", "en", "is a required field"},
+		{"", "en", "is a required field"},
+		{"es;q=0,pt;q=0.1", "pt", "obrigatório"},
+	}
+
+	for _, tc := range cases {
+		locale, msg := firstMessage(t, v, `{"sku": "TW-1"}`, tc.header)
+		if locale != tc.locale {
+			t.Errorf("%q: expected locale %s, got %s", tc.header, tc.locale, locale)
+		}
+		if !strings.Contains(msg, tc.contains) {
+			t.Errorf("%q: expected message containing %q, got %q", tc.header, tc.contains, msg)
+		}
+	}
+}
+
+// TestTranslation_CustomTag verifies translations registered for custom tags and
+// the English fallback for tags without a translation.
+func TestTranslation_CustomTag(t *testing.T) {
+	v := middleware.NewValidator(middleware.WithRule("sku_format", skuRule))
+	if err := v.RegisterTranslation("sku_format", "es", "{0} debe comenzar con TW-"); err != nil {
+		t.Fatalf("RegisterTranslation failed: %v", err)
+	}
+	if err := v.RegisterTranslation("sku_format", "de", "{0} ungültig"); err == nil {
+		t.Error("Expected an error for an unregistered locale")
+	}
+
+	_, msg := firstMessage(t, v, `{"name": "Ana", "sku": "AB-1"}`, "es")
+	if msg != "sku debe comenzar con TW-" {
+		t.Errorf("Unexpected Spanish message: %q", msg)
+	}
+
+	_, msg = firstMessage(t, v, `{"name": "Ana", "sku": "AB-1"}`, "pt")
+	if msg != "Validation failed on rule: sku_format" {
+		t.Errorf("Expected English fallback, got %q", msg)
+	}
+
+	// A message registered for the default locale is used by other languages.
+	if err := v.RegisterTranslation("sku_format", "en", "{0} must start with TW-"); err != nil {
+		t.Fatalf("RegisterTranslation failed: %v", err)
+	}
+	_, msg = firstMessage(t, v, `{"name": "Ana", "sku": "AB-1"}`, "pt")
+	if msg != "sku must start with TW-" {
+		t.Errorf("Expected the registered English message, got %q", msg)
+	}
+	_, msg = firstMessage(t, v, `{"name": "Ana", "sku": "AB-1"}`, "es")
+	if msg != "sku debe comenzar con TW-" {
+		t.Errorf("Unexpected Spanish message: %q", msg)
+	}
+}
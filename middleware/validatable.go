@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/iaconlabs/transwarp/problem"
+)
+
+// Validatable can be implemented by DTOs to run cross-field or business checks
+// after the tag-based rules pass. ctx is the request context, so implementations
+// can honor cancellation and reach request-scoped dependencies.
+//
+// Returning FieldErrors reports specific fields; a *problem.Details is rendered
+// as-is; any other error becomes a single 422 validation error with its message.
+type Validatable interface {
+	Validate(ctx context.Context) error
+}
+
+// FieldErrors is an error listing validation failures, meant to be returned from
+// Validatable implementations.
+type FieldErrors []ValidationError
+
+// Error implements the error interface.
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// WithValidationTimeout bounds the time validation rules may take. When the
+// deadline expires the request is rejected with 503 Service Unavailable instead
+// of reporting the rules interrupted by the timeout as failures.
+func WithValidationTimeout(d time.Duration) ValidatorOption {
+	return func(v *Validator) {
+		v.timeout = d
+	}
+}
+
+// contextDone reports whether validation was interrupted by its context. A
+// validation timeout is answered with 503; when the client went away nothing is
+// written, as nobody is listening.
+func contextDone(ctx context.Context, w http.ResponseWriter, r *http.Request) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	if r.Context().Err() == nil {
+		problem.Write(w, r, problem.New(http.StatusServiceUnavailable).WithDetail("Validation timed out"))
+	}
+	return true
+}
+
+// validatableProblem renders the error returned by a Validatable DTO.
+func validatableProblem(err error) *problem.Details {
+	var p *problem.Details
+	if errors.As(err, &p) {
+		return p
+	}
+	var fields FieldErrors
+	if errors.As(err, &fields) {
+		return ValidationProblem(fields)
+	}
+	return ValidationProblem([]ValidationError{{Message: err.Error()}})
+}
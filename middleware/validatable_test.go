@@ -0,0 +1,95 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/iaconlabs/transwarp/middleware"
+)
+
+type tenantKey struct{}
+
+type InviteRequest struct {
+	Email string `json:"email" validate:"required,email,tenant_unique"`
+}
+
+type BookingRequest struct {
+	Start int `json:"start" validate:"required"`
+	End   int `json:"end" validate:"required"`
+}
+
+// Validate implements middleware.Validatable with a cross-field check.
+func (b *BookingRequest) Validate(_ context.Context) error {
+	if b.End <= b.Start {
+		return middleware.FieldErrors{{Field: "end", Rule: "after_start", Message: "end must be after start"}}
+	}
+	return nil
+}
+
+// TestValidator_ContextRules verifies that context-aware rules receive the request
+// context, including request-scoped values.
+func TestValidator_ContextRules(t *testing.T) {
+	v := middleware.NewValidator(middleware.WithRuleCtx("tenant_unique", func(ctx context.Context, fl validator.FieldLevel) bool {
+		taken, _ := ctx.Value(tenantKey{}).(map[string]bool)
+		return !taken[fl.Field().String()]
+	}))
+
+	mw := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), tenantKey{}, map[string]bool{"taken@acme.io": true})
+			v.Validate(InviteRequest{})(next).ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+
+	if rr, _ := serveCapture[InviteRequest](t, mw, `{"email": "taken@acme.io"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a taken email, got %d", rr.Code)
+	}
+	if rr, _ := serveCapture[InviteRequest](t, mw, `{"email": "free@acme.io"}`); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a free email, got %d", rr.Code)
+	}
+}
+
+// TestValidator_Timeout verifies that slow rules are interrupted and reported as 503.
+func TestValidator_Timeout(t *testing.T) {
+	v := middleware.NewValidator(
+		middleware.WithValidationTimeout(10*time.Millisecond),
+		middleware.WithRuleCtx("tenant_unique", func(ctx context.Context, _ validator.FieldLevel) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(time.Second):
+				return true
+			}
+		}),
+	)
+
+	rr, _ := serveCapture[InviteRequest](t, v.Validate(InviteRequest{}), `{"email": "slow@acme.io"}`)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// TestValidator_Validatable verifies that DTOs can report cross-field errors.
+func TestValidator_Validatable(t *testing.T) {
+	rr, _ := serveCapture[BookingRequest](t, middleware.Validate(BookingRequest{}), `{"start": 5, "end": 3}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d", rr.Code)
+	}
+
+	var res struct {
+		Errors []middleware.ValidationError `json:"errors"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if len(res.Errors) != 1 || res.Errors[0].Field != "end" || res.Errors[0].Rule != "after_start" {
+		t.Errorf("Unexpected errors: %+v", res.Errors)
+	}
+
+	if rr, _ := serveCapture[BookingRequest](t, middleware.Validate(BookingRequest{}), `{"start": 1, "end": 3}`); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rr.Code)
+	}
+}
@@ -4,61 +4,224 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"reflect"
-	"strings"
+	"strconv"
+	"time"
 
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 
 	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/internal/payload"
+	"github.com/iaconlabs/transwarp/problem"
 	"github.com/iaconlabs/transwarp/router"
 )
 
-// Internal singleton instance to allow custom tag registration.
-var defaultValidator = validator.New()
+// Internal default instance backing the package-level Validate and GetValidator helpers.
+var defaultValidator = NewValidator()
 
-// GetValidator returns the shared validator instance used by the Validate middleware.
-// Use this to register custom validation tags or translations.
+// GetValidator returns the validation engine of the default Validator used by the
+// package-level Validate middleware. Use this to register custom validation tags or
+// translations that should apply globally.
 func GetValidator() *validator.Validate {
+	return defaultValidator.Engine()
+}
+
+// Default returns the Validator instance used by the package-level Validate middleware.
+func Default() *Validator {
 	return defaultValidator
 }
 
+// ErrorFormatter converts the error returned by the validation engine into the list
+// of ValidationError values sent to the client. trans is the translator negotiated
+// from the request's Accept-Language header.
+type ErrorFormatter func(err error, trans ut.Translator) []ValidationError
+
+// ValidatorOption configures a Validator during construction.
+type ValidatorOption func(*Validator)
+
+// WithRule registers a custom field-level validation tag on the Validator.
+// It panics if the tag cannot be registered, as this is a programming error.
+func WithRule(tag string, fn validator.Func) ValidatorOption {
+	return func(v *Validator) {
+		if err := v.RegisterRule(tag, fn); err != nil {
+			panic(fmt.Sprintf("transwarp: cannot register rule %q: %v", tag, err))
+		}
+	}
+}
+
+// WithRuleCtx registers a custom field-level validation tag that receives the
+// request context. Use it for rules that perform I/O (e.g. database lookups) so
+// they honor client cancellation and timeouts, or read request-scoped values.
+// It panics if the tag cannot be registered, as this is a programming error.
+func WithRuleCtx(tag string, fn validator.FuncCtx) ValidatorOption {
+	return func(v *Validator) {
+		if err := v.RegisterRuleCtx(tag, fn); err != nil {
+			panic(fmt.Sprintf("transwarp: cannot register rule %q: %v", tag, err))
+		}
+	}
+}
+
+// WithStructRule registers a struct-level validation function for the given types.
+func WithStructRule(fn validator.StructLevelFunc, types ...any) ValidatorOption {
+	return func(v *Validator) {
+		v.RegisterStructRule(fn, types...)
+	}
+}
+
+// WithErrorFormatter replaces the function used to turn validation errors into
+// the ValidationError list of the 422 response.
+func WithErrorFormatter(f ErrorFormatter) ValidatorOption {
+	return func(v *Validator) {
+		if f != nil {
+			v.formatter = f
+		}
+	}
+}
+
+// Validator bundles a validation engine with its own custom rules and error
+// formatting. Each instance is isolated, so different route groups (or tests)
+// can use different rule sets without leaking registrations between them.
+type Validator struct {
+	engine    *validator.Validate
+	formatter ErrorFormatter
+	pathStyle PathStyle
+	sensitive payload.Sensitive
+
+	transformers map[string]transformer
+	timeout      time.Duration
+
+	uni            *ut.UniversalTranslator
+	fallbackLocale string
+}
+
+// NewValidator creates an isolated Validator configured with the given options.
+// English, Spanish and Portuguese messages are available out of the box.
+func NewValidator(opts ...ValidatorOption) *Validator {
+	v := &Validator{
+		engine:       validator.New(),
+		sensitive:    payload.DefaultSensitive(),
+		transformers: defaultTransformers(),
+	}
+	v.formatter = v.formatValidationErrors
+	v.engine.RegisterTagNameFunc(fieldName)
+	v.initTranslations()
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Engine returns the underlying go-playground validation engine.
+func (v *Validator) Engine() *validator.Validate {
+	return v.engine
+}
+
+// RegisterRule adds a custom field-level validation tag to this Validator only.
+func (v *Validator) RegisterRule(tag string, fn validator.Func) error {
+	return v.engine.RegisterValidation(tag, fn)
+}
+
+// RegisterRuleCtx adds a custom context-aware validation tag to this Validator only.
+// The context passed to fn is the request context (see WithValidationTimeout).
+func (v *Validator) RegisterRuleCtx(tag string, fn validator.FuncCtx) error {
+	return v.engine.RegisterValidationCtx(tag, fn)
+}
+
+// RegisterStructRule adds a struct-level validation function for the given types
+// to this Validator only.
+func (v *Validator) RegisterStructRule(fn validator.StructLevelFunc, types ...any) {
+	v.engine.RegisterStructValidation(fn, types...)
+}
+
 // ValidationError represents a specific validation failure for a field.
 // It is intended to be returned as part of a structured JSON response.
 type ValidationError struct {
-	// Field is the name of the struct field that failed validation (json tag preferred).
+	// Field is the path of the field that failed validation, built from json tag names
+	// (e.g., "email", "items[3].price" or "/items/3/price"; see PathStyle).
 	Field string `json:"field"`
 	// Rule is the name of the validator tag that was violated (e.g., "required", "email").
 	Rule string `json:"rule"`
+	// Param is the parameter of the violated rule (e.g., "5" for "min=5").
+	Param string `json:"param,omitempty"`
+	// Value is the rejected value. Sensitive fields are redacted; composite values
+	// and errors without a single rejected value report null.
+	Value any `json:"value"`
 	// Message is a human-readable description of the error.
 	Message string `json:"message"`
 }
 
 // Validate returns a middleware that performs hybrid binding and validation.
-// It unmarshals the JSON body into a new instance of T and maps path parameters
-// using the "param" struct tag. If validation fails, it returns a 422 Unprocessable Entity
-// with detailed error information. If successful, the validated data is stored
+// It unmarshals the JSON body into a new instance of T, maps path parameters
+// using the "param" struct tag and the verified claims of the caller (see
+// middleware/auth) using the "claim" struct tag. Fields are then normalized by
+// their "mod" tags (trim, lower, upper, title, strip_html, default=<value> or
+// custom transformers, see RegisterTransformer). Rules receive the request
+// context, and DTOs implementing Validatable are checked last. If validation
+// fails, it returns a 422 Unprocessable Entity problem (RFC 9457) listing the
+// failures in its "errors" member. If successful, the validated data is stored
 // in the request context under router.ValidationKey.
-func Validate[T any](_ T) func(http.Handler) http.Handler {
+//
+// Decoding can be tightened per route with DecodeOption values such as
+// DisallowUnknownFields, MaxBodyBytes or RejectDuplicateKeys.
+//
+// Validate uses the default Validator; see Validator.Validate for isolated rule sets.
+func Validate[T any](_ T, opts ...DecodeOption) func(http.Handler) http.Handler {
+	return defaultValidator.middleware(func() any { return new(T) }, newDecodeConfig(opts))
+}
+
+// Validate returns a middleware equivalent to the package-level Validate, but
+// bound to this Validator's rules and error formatter. The dto argument is only
+// used for its type; a pointer to a fresh value of that type is stored in the
+// request context under router.ValidationKey. A typed nil pointer is accepted;
+// an untyped nil panics.
+func (v *Validator) Validate(dto any, opts ...DecodeOption) func(http.Handler) http.Handler {
+	if dto == nil {
+		panic("transwarp: Validate requires a non-nil DTO")
+	}
+	typ := reflect.TypeOf(dto)
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return v.middleware(func() any { return reflect.New(typ).Interface() }, newDecodeConfig(opts))
+}
+
+// middleware builds the binding and validation handler. newTarget must return a
+// pointer to a zero value of the destination type.
+func (v *Validator) middleware(newTarget func() any, decoding *decodeConfig) func(http.Handler) http.Handler {
+	dtoType := reflect.TypeOf(newTarget()).Elem()
+	v.checkTransformers(dtoType)
+	describe := func(d *router.RouteDescription) {
+		d.Request = dtoType
+		d.AddError(http.StatusBadRequest, http.StatusUnprocessableEntity)
+		if decoding.maxBytes > 0 {
+			d.AddError(http.StatusRequestEntityTooLarge)
+		}
+		if v.timeout > 0 {
+			d.AddError(http.StatusServiceUnavailable)
+		}
+	}
+
 	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// 1. Retrieve the Transwarp state injected by the adapter.
 			state, ok := r.Context().Value(router.StateKey).(*adapter.TranswarpState)
 			if !ok {
-				http.Error(w, "Transwarp state not found", http.StatusInternalServerError)
+				problem.Write(w, r, problem.New(http.StatusInternalServerError).WithDetail("Transwarp state not found"))
 				return
 			}
 
-			// 2. Create a new instance of the target type T.
-			target := new(T)
+			// 2. Create a new instance of the target type.
+			target := newTarget()
 
 			// 3. BINDING: Priority 1 - JSON Body.
 			if len(state.Body) > 0 {
-				if err := json.Unmarshal(state.Body, target); err != nil {
-					sendJSONError(w, "Invalid JSON format", http.StatusBadRequest)
+				if err := decoding.decode(state.Body, target); err != nil {
+					problem.Write(w, r, v.decodeProblem(err))
 					return
 				}
 			}
@@ -66,18 +229,62 @@ func Validate[T any](_ T) func(http.Handler) http.Handler {
 			// 4. BINDING: Priority 2 - Path Parameters (Mapped via "param" tags).
 			mapPathParams(target, state.Params)
 
-			// 5. VALIDATION: Execute rules from go-playground/validator.
-			if err := defaultValidator.Struct(target); err != nil {
-				details := formatValidationErrors(err)
-				sendDetailedError(w, details)
+			// 5. BINDING: Priority 3 - Verified Claims (Mapped via "claim" tags).
+			mapClaims(target, r.Context().Value(router.ClaimsKey))
+
+			// 6. TRANSFORMATION & VALIDATION: "mod" tags, rules and Validatable (see check).
+			if !v.check(w, r, target) {
 				return
 			}
 
-			// 6. INJECTION: Store the clean, validated data in the context.
+			// 7. INJECTION: Store the clean, validated data in the context.
 			ctx := context.WithValue(r.Context(), router.ValidationKey, target)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
+		return &describedHandler{Handler: handler, describe: describe}
+	}
+}
+
+// check normalizes and validates a bound target, writing the error response and
+// returning false when the request must be rejected.
+func (v *Validator) check(w http.ResponseWriter, r *http.Request, target any) bool {
+	// Transformation: Normalize fields decorated with "mod" tags.
+	if err := v.transform(target); err != nil {
+		problem.Write(w, r, ValidationProblem(v.transformErrors(err)))
+		return false
+	}
+
+	vctx := r.Context()
+	if v.timeout > 0 {
+		var cancel context.CancelFunc
+		vctx, cancel = context.WithTimeout(vctx, v.timeout)
+		defer cancel()
+	}
+
+	// Validation: Execute rules from go-playground/validator with the request context.
+	if err := v.engine.StructCtx(vctx, target); err != nil {
+		if contextDone(vctx, w, r) {
+			return false
+		}
+		trans := v.translatorFor(r)
+		details := v.formatter(err, trans)
+		w.Header().Set("Content-Language", trans.Locale())
+		problem.Write(w, r, ValidationProblem(details))
+		return false
+	}
+
+	// Cross-field validation: DTOs may implement Validatable.
+	if dto, ok := target.(Validatable); ok {
+		if err := dto.Validate(vctx); err != nil {
+			if contextDone(vctx, w, r) {
+				return false
+			}
+			problem.Write(w, r, validatableProblem(err))
+			return false
+		}
 	}
+
+	return true
 }
 
 // mapPathParams uses reflection to populate struct fields decorated with the "param" tag
@@ -100,8 +307,82 @@ func mapPathParams(target any, params map[string]string) {
 	}
 }
 
-// formatValidationErrors converts internal validator errors into a slice of ValidationError.
-func formatValidationErrors(err error) []ValidationError {
+// claimSource is implemented by the claims of the authenticated caller stored
+// under router.ClaimsKey (auth.Claims in middleware/auth).
+type claimSource interface {
+	Claim(name string) (any, bool)
+}
+
+// mapClaims populates struct fields decorated with the "claim" tag using the
+// verified claims of the caller. These fields are never taken from the body:
+// they are reset first, so a client cannot impersonate another user by sending
+// the value itself.
+func mapClaims(target any, source any) {
+	claims, _ := source.(claimSource)
+	val := reflect.ValueOf(target).Elem()
+	typ := val.Type()
+
+	for i := range typ.NumField() {
+		name := typ.Field(i).Tag.Get("claim")
+		f := val.Field(i)
+		if name == "" || !f.CanSet() {
+			continue
+		}
+		f.SetZero()
+		if claims == nil {
+			continue
+		}
+		if claim, exists := claims.Claim(name); exists {
+			setClaim(f, claim)
+		}
+	}
+}
+
+// setClaim stores a JSON-decoded claim in f when the types are compatible.
+// Numbers are also accepted by string fields, and arrays of strings by
+// []string fields.
+func setClaim(f reflect.Value, claim any) {
+	switch c := claim.(type) {
+	case string:
+		if f.Kind() == reflect.String {
+			f.SetString(c)
+		}
+	case bool:
+		if f.Kind() == reflect.Bool {
+			f.SetBool(c)
+		}
+	case float64:
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(strconv.FormatFloat(c, 'f', -1, 64))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if c == math.Trunc(c) && !f.OverflowInt(int64(c)) {
+				f.SetInt(int64(c))
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if c >= 0 && c == math.Trunc(c) && !f.OverflowUint(uint64(c)) {
+				f.SetUint(uint64(c))
+			}
+		case reflect.Float32, reflect.Float64:
+			f.SetFloat(c)
+		}
+	case []any:
+		if f.Type() != reflect.TypeFor[[]string]() {
+			return
+		}
+		list := make([]string, 0, len(c))
+		for _, item := range c {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		f.Set(reflect.ValueOf(list))
+	}
+}
+
+// formatValidationErrors converts internal validator errors into a slice of ValidationError,
+// with messages rendered in the language of trans.
+func (v *Validator) formatValidationErrors(err error, trans ut.Translator) []ValidationError {
 	var errs []ValidationError
 	var vErrors validator.ValidationErrors
 
@@ -109,15 +390,30 @@ func formatValidationErrors(err error) []ValidationError {
 	if errors.As(err, &vErrors) {
 		for _, vErr := range vErrors {
 			errs = append(errs, ValidationError{
-				Field:   strings.ToLower(vErr.Field()),
+				Field:   v.formatPath(vErr),
 				Rule:    vErr.Tag(),
-				Message: createMsgForTag(vErr),
+				Param:   vErr.Param(),
+				Value:   v.rejectedValue(vErr),
+				Message: v.translateMessage(vErr, trans),
 			})
 		}
 	}
 	return errs
 }
 
+// transformErrors reports a rejected transformation in the ValidationError format.
+func (v *Validator) transformErrors(err error) []ValidationError {
+	var te *transformError
+	if !errors.As(err, &te) {
+		return []ValidationError{{Message: err.Error()}}
+	}
+	return []ValidationError{{
+		Field:   v.renderPath(te.path),
+		Rule:    te.tag,
+		Message: te.err.Error(),
+	}}
+}
+
 // createMsgForTag generates a professional error message based on the failed validation tag.
 func createMsgForTag(v validator.FieldError) string {
 	switch v.Tag() {
@@ -134,19 +430,10 @@ func createMsgForTag(v validator.FieldError) string {
 	}
 }
 
-// sendJSONError sends a simple structured JSON error message.
-func sendJSONError(w http.ResponseWriter, msg string, code int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
-}
-
-// sendDetailedError sends a 422 response containing a list of validation errors.
-func sendDetailedError(w http.ResponseWriter, errors []ValidationError) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusUnprocessableEntity)
-	_ = json.NewEncoder(w).Encode(map[string]any{
-		"status": "error",
-		"errors": errors,
-	})
+// ValidationProblem builds the 422 problem returned when validation fails. The
+// failures are listed in the "errors" extension member.
+func ValidationProblem(errs []ValidationError) *problem.Details {
+	return problem.New(http.StatusUnprocessableEntity).
+		WithDetail("The request contains invalid fields").
+		With("errors", errs)
 }
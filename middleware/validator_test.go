@@ -6,8 +6,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+
 	"github.com/iaconlabs/transwarp/adapter"
 	"github.com/iaconlabs/transwarp/middleware"
 	"github.com/iaconlabs/transwarp/router"
@@ -99,3 +103,93 @@ func TestValidate_ValidationError(t *testing.T) {
 		t.Errorf("Expected 3 validation errors, got %d", len(errList))
 	}
 }
+
+// CouponRequest uses a custom "coupon" tag whose meaning depends on the Validator instance.
+type CouponRequest struct {
+	Code string `json:"code" validate:"required,coupon"`
+}
+
+// serveWithState executes mw against a request carrying the given Transwarp state.
+func serveWithState(t *testing.T, mw func(http.Handler) http.Handler, state *adapter.TranswarpState) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(state.Body))
+	req = req.WithContext(context.WithValue(t.Context(), router.StateKey, state))
+
+	rr := httptest.NewRecorder()
+	mw(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, req)
+	return rr
+}
+
+// TestValidator_IsolatedRules verifies that rules registered on one Validator
+// do not leak into another instance.
+func TestValidator_IsolatedRules(t *testing.T) {
+	strict := middleware.NewValidator(middleware.WithRule("coupon", func(fl validator.FieldLevel) bool {
+		return strings.HasPrefix(fl.Field().String(), "TW-")
+	}))
+	lenient := middleware.NewValidator(middleware.WithRule("coupon", func(_ validator.FieldLevel) bool {
+		return true
+	}))
+
+	body := []byte(`{"code": "SUMMER"}`)
+
+	rr := serveWithState(t, strict.Validate(CouponRequest{}), &adapter.TranswarpState{Body: body})
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Strict validator: expected 422, got %d", rr.Code)
+	}
+
+	rr = serveWithState(t, lenient.Validate(CouponRequest{}), &adapter.TranswarpState{Body: body})
+	if rr.Code != http.StatusOK {
+		t.Errorf("Lenient validator: expected 200, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// TestValidator_NilDTO verifies that a nil DTO panics when the middleware is
+// built, while a typed nil pointer is accepted.
+func TestValidator_NilDTO(t *testing.T) {
+	type NoteRequest struct {
+		Text string `json:"text" validate:"required"`
+	}
+
+	v := middleware.NewValidator()
+	rr := serveWithState(t, v.Validate((*NoteRequest)(nil)), &adapter.TranswarpState{Body: []byte(`{"text": "hi"}`)})
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected a typed nil pointer to bind, got %d", rr.Code)
+	}
+
+	defer func() {
+		if msg, _ := recover().(string); msg != "transwarp: Validate requires a non-nil DTO" {
+			t.Errorf("Unexpected panic: %q", msg)
+		}
+	}()
+	v.Validate(nil)
+}
+
+// TestValidator_StructRuleAndFormatter verifies struct-level rules and custom error formatting.
+func TestValidator_StructRuleAndFormatter(t *testing.T) {
+	type Range struct {
+		From int `json:"from"`
+		To   int `json:"to"`
+	}
+
+	v := middleware.NewValidator(
+		middleware.WithStructRule(func(sl validator.StructLevel) {
+			rg, _ := sl.Current().Interface().(Range)
+			if rg.From > rg.To {
+				sl.ReportError(rg.To, "To", "To", "gtefrom", "")
+			}
+		}, Range{}),
+		middleware.WithErrorFormatter(func(_ error, _ ut.Translator) []middleware.ValidationError {
+			return []middleware.ValidationError{{Field: "range", Rule: "custom", Message: "bad range"}}
+		}),
+	)
+
+	rr := serveWithState(t, v.Validate(Range{}), &adapter.TranswarpState{Body: []byte(`{"from": 5, "to": 1}`)})
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"rule":"custom"`) {
+		t.Errorf("Custom formatter not applied. Body: %s", rr.Body.String())
+	}
+}
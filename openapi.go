@@ -0,0 +1,48 @@
+package transwarp
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/iaconlabs/transwarp/openapi"
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// ServeOpenAPI registers a GET route at path that serves the OpenAPI document of
+// every route registered through this instance and its groups. The document is
+// generated on the first request, so routes added after this call are included;
+// the document route itself is left out.
+func (t *Transwarp) ServeOpenAPI(path string, info openapi.Info) {
+	t.GET(path, t.openAPIHandler(info, t.fullPath(path)))
+}
+
+// openAPIHandler returns a handler that generates the document once and serves
+// it, leaving out the GET routes at the exclude paths.
+func (t *Transwarp) openAPIHandler(info openapi.Info, exclude ...string) http.HandlerFunc {
+	var (
+		once sync.Once
+		body []byte
+		err  error
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			var routes []router.Route
+			for _, route := range t.Routes() {
+				if route.Method != http.MethodGet || !slices.Contains(exclude, route.Path) {
+					routes = append(routes, route)
+				}
+			}
+			body, err = json.Marshal(openapi.Generate(info, routes))
+		})
+		if err != nil {
+			problem.Write(w, r, problem.New(http.StatusInternalServerError).WithDetail(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}
+}
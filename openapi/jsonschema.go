@@ -0,0 +1,391 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/iaconlabs/transwarp/internal/payload"
+	"github.com/iaconlabs/transwarp/middleware"
+)
+
+// maxSchemaDepth bounds $ref and combinator recursion on malicious or cyclic input.
+const maxSchemaDepth = 64
+
+// sensitiveFields lists the names whose rejected values are redacted, as in
+// middleware.Validate.
+var sensitiveFields = payload.DefaultSensitive()
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// schemaCheck validates a decoded JSON value (maps, slices, strings, bools,
+// json.Number and nil) against the JSON Schema subset used by OpenAPI 3.0 and
+// 3.1, collecting failures in the middleware.ValidationError format.
+type schemaCheck struct {
+	spec *Spec
+	errs []middleware.ValidationError
+}
+
+// fail records a violation at path.
+func (c *schemaCheck) fail(path, rule, param string, value any, msg string) {
+	if path == "" {
+		path = "body"
+	}
+	switch value.(type) {
+	case map[string]any, []any:
+		// Composite values are omitted, as in middleware.Validate.
+		value = nil
+	}
+	if value != nil && sensitiveFields.Match(leafName(path)) {
+		value = payload.Redacted
+	}
+	c.errs = append(c.errs, middleware.ValidationError{Field: path, Rule: rule, Param: param, Value: value, Message: msg})
+}
+
+// valid reports whether value satisfies schema, without recording failures.
+func (c *schemaCheck) valid(schema, value any, depth int) bool {
+	sub := &schemaCheck{spec: c.spec}
+	sub.validate(schema, value, "", depth)
+	return len(sub.errs) == 0
+}
+
+// validate checks value against schema; path is the dotted location of value.
+func (c *schemaCheck) validate(schemaValue, value any, path string, depth int) {
+	if depth > maxSchemaDepth {
+		return
+	}
+	schema, ok := c.spec.resolve(schemaValue).(map[string]any)
+	if !ok {
+		// true, {} or an unresolvable value accept anything; false rejects.
+		if b, isBool := schemaValue.(bool); isBool && !b {
+			c.fail(path, "not", "", value, "No value is allowed here")
+		}
+		return
+	}
+
+	if value == nil && schema["nullable"] == true {
+		return
+	}
+	if !c.checkType(schema, value, path) {
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok && !containsJSON(enum, value) {
+		c.fail(path, "oneof", joinValues(enum), value, "Must be one of: "+joinValues(enum))
+	}
+	if constant, ok := schema["const"]; ok && !payload.Equal(constant, value) {
+		c.fail(path, "eq", fmt.Sprint(constant), value, fmt.Sprintf("Must be equal to %v", constant))
+	}
+
+	switch v := value.(type) {
+	case string:
+		c.checkString(schema, v, path)
+	case json.Number:
+		c.checkNumber(schema, v, path)
+	case []any:
+		c.checkArray(schema, v, path, depth)
+	case map[string]any:
+		c.checkObject(schema, v, path, depth)
+	}
+	c.checkCombinators(schema, value, path, depth)
+}
+
+// checkType verifies the "type" keyword, which may be a string or a list (3.1).
+func (c *schemaCheck) checkType(schema map[string]any, value any, path string) bool {
+	var types []string
+	switch t := schema["type"].(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+	default:
+		return true
+	}
+	for _, t := range types {
+		if matchesType(t, value) {
+			return true
+		}
+	}
+	expected := strings.Join(types, " or ")
+	c.fail(path, "type", expected, value, "Expected "+expected)
+	return false
+}
+
+func matchesType(typ string, value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return typ == "null"
+	case bool:
+		return typ == "boolean"
+	case string:
+		return typ == "string"
+	case []any:
+		return typ == "array"
+	case map[string]any:
+		return typ == "object"
+	case json.Number:
+		if typ == "number" {
+			return true
+		}
+		if typ != "integer" {
+			return false
+		}
+		if _, err := v.Int64(); err == nil {
+			return true
+		}
+		f, err := v.Float64()
+		return err == nil && f == math.Trunc(f)
+	default:
+		return false
+	}
+}
+
+func (c *schemaCheck) checkString(schema map[string]any, s, path string) {
+	length := utf8.RuneCountInString(s)
+	if n, ok := number(schema["minLength"]); ok && float64(length) < n {
+		c.fail(path, "min", formatNumber(n), s, fmt.Sprintf("Must be at least %s characters long", formatNumber(n)))
+	}
+	if n, ok := number(schema["maxLength"]); ok && float64(length) > n {
+		c.fail(path, "max", formatNumber(n), s, fmt.Sprintf("Must be at most %s characters long", formatNumber(n)))
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if re := c.spec.pattern(pattern); re != nil && !re.MatchString(s) {
+			c.fail(path, "pattern", pattern, s, "Must match the pattern "+pattern)
+		}
+	}
+	if format, ok := schema["format"].(string); ok && !validFormat(format, s) {
+		c.fail(path, format, "", s, "Must be a valid "+format)
+	}
+}
+
+// validFormat checks the common string formats; unknown formats are accepted.
+func validFormat(format, s string) bool {
+	switch format {
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "uuid":
+		return uuidPattern.MatchString(s)
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	case "ipv6":
+		ip := net.ParseIP(s)
+		return ip != nil && strings.Contains(s, ":")
+	default:
+		return true
+	}
+}
+
+func (c *schemaCheck) checkNumber(schema map[string]any, num json.Number, path string) {
+	f, err := num.Float64()
+	if err != nil {
+		return
+	}
+	value := any(num)
+
+	// OpenAPI 3.0 expresses exclusive bounds as booleans next to minimum/maximum.
+	if n, ok := number(schema["minimum"]); ok {
+		if schema["exclusiveMinimum"] == true {
+			if f <= n {
+				c.fail(path, "gt", formatNumber(n), value, "Must be greater than "+formatNumber(n))
+			}
+		} else if f < n {
+			c.fail(path, "min", formatNumber(n), value, "Must be greater than or equal to "+formatNumber(n))
+		}
+	}
+	if n, ok := number(schema["maximum"]); ok {
+		if schema["exclusiveMaximum"] == true {
+			if f >= n {
+				c.fail(path, "lt", formatNumber(n), value, "Must be less than "+formatNumber(n))
+			}
+		} else if f > n {
+			c.fail(path, "max", formatNumber(n), value, "Must be less than or equal to "+formatNumber(n))
+		}
+	}
+	if n, ok := number(schema["exclusiveMinimum"]); ok && f <= n {
+		c.fail(path, "gt", formatNumber(n), value, "Must be greater than "+formatNumber(n))
+	}
+	if n, ok := number(schema["exclusiveMaximum"]); ok && f >= n {
+		c.fail(path, "lt", formatNumber(n), value, "Must be less than "+formatNumber(n))
+	}
+	if n, ok := number(schema["multipleOf"]); ok && n > 0 {
+		if q := f / n; math.Abs(q-math.Round(q)) > 1e-9 {
+			c.fail(path, "multiple_of", formatNumber(n), value, "Must be a multiple of "+formatNumber(n))
+		}
+	}
+}
+
+func (c *schemaCheck) checkArray(schema map[string]any, items []any, path string, depth int) {
+	if n, ok := number(schema["minItems"]); ok && float64(len(items)) < n {
+		c.fail(path, "min", formatNumber(n), nil, fmt.Sprintf("Must contain at least %s items", formatNumber(n)))
+	}
+	if n, ok := number(schema["maxItems"]); ok && float64(len(items)) > n {
+		c.fail(path, "max", formatNumber(n), nil, fmt.Sprintf("Must contain at most %s items", formatNumber(n)))
+	}
+	if schema["uniqueItems"] == true {
+	outer:
+		for i := range items {
+			for j := range i {
+				if payload.Equal(items[i], items[j]) {
+					c.fail(path, "unique", "", nil, "Items must be unique")
+					break outer
+				}
+			}
+		}
+	}
+	if itemSchema, ok := schema["items"]; ok {
+		for i, item := range items {
+			c.validate(itemSchema, item, fmt.Sprintf("%s[%d]", path, i), depth+1)
+		}
+	}
+}
+
+func (c *schemaCheck) checkObject(schema map[string]any, obj map[string]any, path string, depth int) {
+	props, _ := schema["properties"].(map[string]any)
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, item := range required {
+			name, _ := item.(string)
+			if _, present := obj[name]; present || name == "" {
+				continue
+			}
+			// Read-only members are sent by the server, never by clients.
+			if prop, ok := c.spec.resolve(props[name]).(map[string]any); ok && prop["readOnly"] == true {
+				continue
+			}
+			c.fail(joinPath(path, name), "required", "", nil, "This field is required")
+		}
+	}
+	if n, ok := number(schema["minProperties"]); ok && float64(len(obj)) < n {
+		c.fail(path, "min", formatNumber(n), nil, fmt.Sprintf("Must contain at least %s members", formatNumber(n)))
+	}
+	if n, ok := number(schema["maxProperties"]); ok && float64(len(obj)) > n {
+		c.fail(path, "max", formatNumber(n), nil, fmt.Sprintf("Must contain at most %s members", formatNumber(n)))
+	}
+
+	additional, hasAdditional := schema["additionalProperties"]
+	for _, name := range sortedKeys(obj) {
+		child := joinPath(path, name)
+		if propSchema, ok := props[name]; ok {
+			c.validate(propSchema, obj[name], child, depth+1)
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if allowed, isBool := additional.(bool); isBool {
+			if !allowed {
+				c.fail(child, "unknown_field", "", nil, "Unknown field")
+			}
+			continue
+		}
+		c.validate(additional, obj[name], child, depth+1)
+	}
+}
+
+func (c *schemaCheck) checkCombinators(schema map[string]any, value any, path string, depth int) {
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			c.validate(sub, value, path, depth+1)
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if c.valid(sub, value, depth+1) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			c.fail(path, "any_of", "", value, "Does not match any of the allowed schemas")
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		matches := 0
+		for _, sub := range oneOf {
+			if c.valid(sub, value, depth+1) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			c.fail(path, "one_of", "", value, "Must match exactly one of the allowed schemas")
+		}
+	}
+	if not, ok := schema["not"]; ok && c.valid(not, value, depth+1) {
+		c.fail(path, "not", "", value, "Matches a disallowed schema")
+	}
+}
+
+// joinPath appends a member name to a dotted path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// leafName returns the member name a dotted path ends with, e.g. "password"
+// for "users[0].password" or "token" for "query.token".
+func leafName(path string) string {
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		path = path[i+1:]
+	}
+	name, _, _ := strings.Cut(path, "[")
+	return name
+}
+
+// number reads a numeric keyword.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func containsJSON(values []any, value any) bool {
+	for _, v := range values {
+		if payload.Equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinValues(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, " ")
+}
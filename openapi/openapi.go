@@ -0,0 +1,287 @@
+// Package openapi generates OpenAPI 3.1 documents from the routes registered
+// through Transwarp, using the DTO types attached by documented middlewares
+// such as middleware.Validate. For spec-first services it also loads existing
+// OpenAPI 3 documents (see Load) to validate requests against them.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.1.0"
+
+// Document is an OpenAPI 3.1 document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components,omitzero"`
+}
+
+// Info provides metadata about the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server describes a base URL of the API.
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations available on a path, keyed by lowercase method.
+type PathItem map[string]*Operation
+
+// Operation describes a single API operation on a path.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	// Security lists the alternative security requirements of the operation.
+	// Each maps security scheme names to the permissions (scopes or roles) the
+	// caller must hold.
+	Security []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter describes a path parameter.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describes the body accepted by an operation.
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType pairs a media type with the schema of its payload.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds reusable schemas referenced with "#/components/schemas/<name>"
+// and the security schemes named by operations.
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how callers authenticate.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Generate builds the OpenAPI document describing routes. Path parameters come
+// from ":name" and "*name" segments, request and response schemas from the
+// DTOs attached by documented middlewares (see router.RouteDescriber), and
+// security requirements from the authentication and authorization middlewares
+// of the middleware/auth package.
+func Generate(info Info, routes []router.Route) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+	}
+	g := newGenerator()
+
+	for _, route := range routes {
+		path, params := convertPath(route.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(route.Method)] = g.operation(route, params)
+	}
+
+	doc.Components.Schemas = g.schemas
+	doc.Components.SecuritySchemes = g.securitySchemes
+	return doc
+}
+
+// operation describes a single route.
+func (g *generator) operation(route router.Route, params []string) *Operation {
+	desc := route.Describe()
+	op := &Operation{
+		OperationID: operationID(route.Method, route.Path),
+		Responses:   make(map[string]*Response),
+	}
+
+	var dto reflect.Type
+	if desc.Request != nil {
+		dto = indirect(desc.Request)
+	}
+
+	for _, name := range params {
+		schema := &Schema{Type: "string"}
+		if field, ok := paramField(dto, name); ok {
+			schema = g.fieldSchema(field)
+		}
+		op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+
+	if dto != nil && hasBody(route.Method) {
+		contentTypes := desc.RequestContentTypes
+		if len(contentTypes) == 0 {
+			contentTypes = []string{"application/json"}
+		}
+		op.RequestBody = &RequestBody{Required: true, Content: make(map[string]*MediaType)}
+		for _, ct := range contentTypes {
+			op.RequestBody.Content[ct] = &MediaType{Schema: g.bodySchema(dto, ct)}
+		}
+	}
+
+	// Handlers choose their own success status; 200 is documented.
+	success := &Response{Description: http.StatusText(http.StatusOK)}
+	if desc.Response != nil {
+		success.Content = map[string]*MediaType{"application/json": {Schema: g.schemaFor(desc.Response)}}
+	}
+	op.Responses[strconv.Itoa(http.StatusOK)] = success
+
+	for _, status := range desc.Errors {
+		op.Responses[strconv.Itoa(status)] = g.problemResponse(status)
+	}
+	op.Security = g.security(desc)
+	return op
+}
+
+// security returns the security requirements of a route: every scheme of its
+// authentication middlewares, each with the permissions the route requires.
+func (g *generator) security(desc router.RouteDescription) []map[string][]string {
+	if len(desc.Security) == 0 {
+		return nil
+	}
+	requirement := make(map[string][]string, len(desc.Security))
+	for _, s := range desc.Security {
+		requirement[s.Name] = append([]string{}, desc.Permissions...)
+		if g.securitySchemes == nil {
+			g.securitySchemes = make(map[string]*SecurityScheme)
+		}
+		g.securitySchemes[s.Name] = &SecurityScheme{
+			Type:         s.Type,
+			Scheme:       s.Scheme,
+			BearerFormat: s.BearerFormat,
+			Name:         s.Param,
+			In:           s.In,
+		}
+	}
+	return []map[string][]string{requirement}
+}
+
+// bodySchema returns the schema of a request body for the given media type.
+// JSON Patch documents are arrays of operations rather than the resource itself.
+func (g *generator) bodySchema(dto reflect.Type, contentType string) *Schema {
+	switch contentType {
+	case "application/json-patch+json":
+		return g.jsonPatchSchema()
+	case "application/merge-patch+json":
+		// A merge patch is a partial resource: nothing is required.
+		partial := *g.structSchema(dto)
+		partial.Required = nil
+		partial.Description = "JSON Merge Patch (RFC 7396) of the resource"
+		return &partial
+	default:
+		return g.schemaFor(dto)
+	}
+}
+
+// problemResponse describes an error response. 422 responses list the
+// validation failures; every other status uses the plain problem schema.
+func (g *generator) problemResponse(status int) *Response {
+	schema := g.problemSchema()
+	if status == http.StatusUnprocessableEntity {
+		schema = g.validationProblemSchema()
+	}
+	return &Response{
+		Description: http.StatusText(status),
+		Content:     map[string]*MediaType{"application/problem+json": {Schema: schema}},
+	}
+}
+
+// convertPath turns a Transwarp path into an OpenAPI template and returns the
+// parameter names in order: "/users/:id" becomes "/users/{id}", "/files/*path"
+// becomes "/files/{path}", and "/docs/:id.json" becomes "/docs/{id}.json".
+func convertPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if seg == "" || (seg[0] != ':' && seg[0] != '*') {
+			continue
+		}
+		name, suffix := seg[1:], ""
+		if seg[0] == ':' {
+			if dot := strings.IndexByte(name, '.'); dot != -1 {
+				name, suffix = name[:dot], name[dot:]
+			}
+		}
+		if name == "" {
+			name = "path"
+		}
+		params = append(params, name)
+		segments[i] = "{" + name + "}" + suffix
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID builds a stable identifier such as "getUsersById" from a route.
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" {
+			continue
+		}
+		if seg[0] == ':' || seg[0] == '*' {
+			b.WriteString("By")
+			seg = seg[1:]
+		}
+		upper := true
+		for _, r := range seg {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				upper = true
+				continue
+			}
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// hasBody reports whether requests with method carry a body.
+func hasBody(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,239 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/middleware/auth"
+	"github.com/iaconlabs/transwarp/openapi"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+type Address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type CreateCustomer struct {
+	OrgID   int      `param:"org_id" validate:"required,min=1"`
+	Name    string   `json:"name" validate:"required,min=2,max=50"`
+	Email   string   `json:"email" validate:"required,email"`
+	Plan    string   `json:"plan" validate:"oneof=free pro"`
+	Seats   int      `json:"seats" validate:"gte=1,lte=100"`
+	Tags    []string `json:"tags" validate:"max=5,dive,min=3"`
+	Address *Address `json:"address"`
+	Secret  string   `json:"-"`
+}
+
+type Customer struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Referrer *Customer `json:"referrer,omitempty"`
+}
+
+// generate builds the document and returns it as generic JSON for assertions.
+func generate(t *testing.T, routes ...router.Route) map[string]any {
+	t.Helper()
+	raw, err := json.Marshal(openapi.Generate(openapi.Info{Title: "Test", Version: "1.0.0"}, routes))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	return doc
+}
+
+// lookup follows a path of object keys and array indexes through decoded JSON.
+func lookup(t *testing.T, v any, keys ...any) any {
+	t.Helper()
+	for _, key := range keys {
+		switch k := key.(type) {
+		case string:
+			obj, ok := v.(map[string]any)
+			if !ok {
+				t.Fatalf("Expected an object at %q, got %T", k, v)
+			}
+			v = obj[k]
+		case int:
+			arr, ok := v.([]any)
+			if !ok || k >= len(arr) {
+				t.Fatalf("Expected an array with index %d, got %v", k, v)
+			}
+			v = arr[k]
+		}
+	}
+	return v
+}
+
+// TestGenerate_ValidatedRoute verifies parameters, the request body schema built
+// from json and validate tags, and the documented error responses.
+func TestGenerate_ValidatedRoute(t *testing.T) {
+	doc := generate(t, router.Route{
+		Method:      http.MethodPost,
+		Path:        "/orgs/:org_id/customers",
+		Middlewares: []func(http.Handler) http.Handler{middleware.Validate(CreateCustomer{})},
+	})
+
+	if doc["openapi"] != openapi.Version {
+		t.Errorf("Expected openapi %s, got %v", openapi.Version, doc["openapi"])
+	}
+
+	op := lookup(t, doc, "paths", "/orgs/{org_id}/customers", "post")
+	if got := lookup(t, op, "operationId"); got != "postOrgsByOrgIdCustomers" {
+		t.Errorf("Unexpected operationId: %v", got)
+	}
+
+	param := lookup(t, op, "parameters", 0)
+	if lookup(t, param, "name") != "org_id" || lookup(t, param, "in") != "path" || lookup(t, param, "required") != true {
+		t.Errorf("Unexpected path parameter: %v", param)
+	}
+	if lookup(t, param, "schema", "type") != "integer" || lookup(t, param, "schema", "minimum") != 1.0 {
+		t.Errorf("Expected the parameter schema from the param field, got %v", lookup(t, param, "schema"))
+	}
+
+	ref := lookup(t, op, "requestBody", "content", "application/json", "schema", "$ref")
+	if ref != "#/components/schemas/CreateCustomer" {
+		t.Fatalf("Expected a component reference, got %v", ref)
+	}
+
+	schema := lookup(t, doc, "components", "schemas", "CreateCustomer")
+	props := lookup(t, schema, "properties").(map[string]any)
+	for _, excluded := range []string{"OrgID", "org_id", "Secret"} {
+		if _, ok := props[excluded]; ok {
+			t.Errorf("Property %q should not be part of the body", excluded)
+		}
+	}
+
+	required, _ := json.Marshal(lookup(t, schema, "required"))
+	if string(required) != `["name","email"]` {
+		t.Errorf("Unexpected required list: %s", required)
+	}
+
+	checks := []struct {
+		keys []any
+		want any
+	}{
+		{[]any{"name", "minLength"}, 2.0},
+		{[]any{"name", "maxLength"}, 50.0},
+		{[]any{"email", "format"}, "email"},
+		{[]any{"plan", "enum", 1}, "pro"},
+		{[]any{"seats", "minimum"}, 1.0},
+		{[]any{"seats", "maximum"}, 100.0},
+		{[]any{"tags", "maxItems"}, 5.0},
+		{[]any{"tags", "items", "minLength"}, 3.0},
+		{[]any{"address", "$ref"}, "#/components/schemas/Address"},
+	}
+	for _, c := range checks {
+		if got := lookup(t, props, c.keys...); got != c.want {
+			t.Errorf("%v: expected %v, got %v", c.keys, c.want, got)
+		}
+	}
+
+	for _, status := range []string{"400", "422"} {
+		if _, ok := lookup(t, op, "responses").(map[string]any)[status]; !ok {
+			t.Errorf("Expected a %s response", status)
+		}
+	}
+	if got := lookup(t, op, "responses", "422", "content", "application/problem+json", "schema", "$ref"); got != "#/components/schemas/ValidationProblem" {
+		t.Errorf("Expected the validation problem schema for 422, got %v", got)
+	}
+	if lookup(t, doc, "components", "schemas", "ValidationError", "properties", "field") == nil {
+		t.Error("Expected the ValidationError component")
+	}
+}
+
+// TestGenerate_ResponseAndRecursion verifies response schemas and that
+// self-referencing types become a single component.
+func TestGenerate_ResponseAndRecursion(t *testing.T) {
+	doc := generate(t, router.Route{
+		Method:      http.MethodGet,
+		Path:        "/customers/:id",
+		Middlewares: []func(http.Handler) http.Handler{middleware.ValidateResponse(Customer{})},
+	})
+
+	op := lookup(t, doc, "paths", "/customers/{id}", "get")
+	if got := lookup(t, op, "responses", "200", "content", "application/json", "schema", "$ref"); got != "#/components/schemas/Customer" {
+		t.Errorf("Expected the response component, got %v", got)
+	}
+	if got := lookup(t, op, "parameters", 0, "schema", "type"); got != "string" {
+		t.Errorf("Parameters without a DTO field should be strings, got %v", got)
+	}
+	if got := lookup(t, doc, "components", "schemas", "Customer", "properties", "referrer", "$ref"); got != "#/components/schemas/Customer" {
+		t.Errorf("Expected a recursive reference, got %v", got)
+	}
+	if _, ok := lookup(t, op, "responses").(map[string]any)["422"]; ok {
+		t.Error("A route without request validation should not document 422")
+	}
+}
+
+// TestGenerate_PatchAndWildcards verifies patch media types and path templates.
+func TestGenerate_PatchAndWildcards(t *testing.T) {
+	load := func(*http.Request) (*Customer, error) { return &Customer{}, nil }
+	doc := generate(t,
+		router.Route{
+			Method:      http.MethodPatch,
+			Path:        "/customers/:id",
+			Middlewares: []func(http.Handler) http.Handler{middleware.ValidatePatch(load)},
+		},
+		router.Route{Method: http.MethodGet, Path: "/files/*path"},
+		router.Route{Method: http.MethodGet, Path: "/reports/:id.json"},
+	)
+
+	content := lookup(t, doc, "paths", "/customers/{id}", "patch", "requestBody", "content")
+	if got := lookup(t, content, "application/json-patch+json", "schema", "$ref"); got != "#/components/schemas/JSONPatch" {
+		t.Errorf("Expected the JSON Patch schema, got %v", got)
+	}
+	if got := lookup(t, content, "application/merge-patch+json", "schema", "type"); got != "object" {
+		t.Errorf("Expected an inline merge patch schema, got %v", got)
+	}
+	if _, ok := lookup(t, doc, "paths", "/customers/{id}", "patch", "responses").(map[string]any)["409"]; !ok {
+		t.Error("Expected a 409 response for failed test operations")
+	}
+
+	for _, path := range []string{"/files/{path}", "/reports/{id}.json"} {
+		if lookup(t, doc, "paths", path, "get") == nil {
+			t.Errorf("Expected path %s", path)
+		}
+	}
+}
+
+// TestGenerate_Security verifies the security requirements and schemes
+// documented by the auth middlewares.
+func TestGenerate_Security(t *testing.T) {
+	doc := generate(t,
+		router.Route{Method: http.MethodDelete, Path: "/admin/users/:id", Middlewares: []func(http.Handler) http.Handler{
+			auth.JWT(auth.NewKeySet()), auth.Require("admin:write"),
+		}},
+		router.Route{Method: http.MethodGet, Path: "/reports", Middlewares: []func(http.Handler) http.Handler{
+			auth.APIKey(auth.StaticAPIKeys(nil), auth.WithAPIKeyHeader("X-Token")),
+		}},
+		router.Route{Method: http.MethodGet, Path: "/status"},
+	)
+
+	op := lookup(t, doc, "paths", "/admin/users/{id}", "delete")
+	requirement, _ := json.Marshal(lookup(t, op, "security"))
+	if string(requirement) != `[{"bearerAuth":["admin:write"]}]` {
+		t.Errorf("Unexpected security requirement %s", requirement)
+	}
+	if _, ok := lookup(t, op, "responses").(map[string]any)["403"]; !ok {
+		t.Error("Expected a 403 response")
+	}
+	requirement, _ = json.Marshal(lookup(t, doc, "paths", "/reports", "get", "security"))
+	if string(requirement) != `[{"apiKeyAuth":[]}]` {
+		t.Errorf("Unexpected security requirement %s", requirement)
+	}
+	if lookup(t, doc, "paths", "/status", "get", "security") != nil {
+		t.Error("Routes without authentication must not document security")
+	}
+
+	schemes := lookup(t, doc, "components", "securitySchemes")
+	if lookup(t, schemes, "bearerAuth", "scheme") != "bearer" || lookup(t, schemes, "bearerAuth", "bearerFormat") != "JWT" {
+		t.Errorf("Unexpected bearer scheme %v", lookup(t, schemes, "bearerAuth"))
+	}
+	if lookup(t, schemes, "apiKeyAuth", "in") != "header" || lookup(t, schemes, "apiKeyAuth", "name") != "X-Token" {
+		t.Errorf("Unexpected API key scheme %v", lookup(t, schemes, "apiKeyAuth"))
+	}
+}
@@ -0,0 +1,390 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema (draft 2020-12) used by generated documents.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// generator converts Go types into schemas, collecting named structs as components.
+type generator struct {
+	schemas         map[string]*Schema
+	names           map[reflect.Type]string
+	securitySchemes map[string]*SecurityScheme
+}
+
+func newGenerator() *generator {
+	return &generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// indirect dereferences pointer types.
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// schemaFor returns the schema of t. Named structs are referenced as components.
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	t = indirect(t)
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: g.ref(t)}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	default:
+		// Interfaces and other kinds accept any JSON value.
+		return &Schema{}
+	}
+}
+
+// ref registers a named struct as a component and returns its reference.
+func (g *generator) ref(t reflect.Type) string {
+	g.structSchema(t)
+	return "#/components/schemas/" + g.names[t]
+}
+
+// structSchema builds the object schema of a struct. Named structs are stored as
+// components, so recursive types terminate.
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	if name, ok := g.names[t]; ok {
+		return g.schemas[name]
+	}
+
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	if t.Name() != "" {
+		name := g.componentName(t)
+		g.names[t] = name
+		g.schemas[name] = schema
+	}
+	g.addFields(schema, t)
+	return schema
+}
+
+// addFields adds the JSON members of t to schema, flattening embedded structs.
+func (g *generator) addFields(schema *Schema, t reflect.Type) {
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		jsonName, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+		if sf.Anonymous && jsonName == "" {
+			if et := indirect(sf.Type); et.Kind() == reflect.Struct {
+				g.addFields(schema, et)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		// Fields bound only from the path are documented as parameters, and
+		// fields bound from the caller's claims are not part of the body.
+		if jsonName == "" && (sf.Tag.Get("param") != "" || sf.Tag.Get("claim") != "") {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = sf.Name
+		}
+
+		fieldSchema, required := g.fieldSchemaRequired(sf)
+		schema.Properties[jsonName] = fieldSchema
+		if required {
+			schema.Required = append(schema.Required, jsonName)
+		}
+	}
+}
+
+// fieldSchema returns the schema of a struct field, constrained by its validate tag.
+func (g *generator) fieldSchema(sf reflect.StructField) *Schema {
+	schema, _ := g.fieldSchemaRequired(sf)
+	return schema
+}
+
+// fieldSchemaRequired is fieldSchema, also reporting whether the field is required.
+func (g *generator) fieldSchemaRequired(sf reflect.StructField) (*Schema, bool) {
+	schema := g.schemaFor(sf.Type)
+	required := applyRules(schema, indirect(sf.Type), sf.Tag.Get("validate"))
+	return schema, required
+}
+
+// applyRules maps validate rules onto schema and reports whether "required" is
+// present. Rules after "dive" apply to the items of slices and maps.
+func applyRules(schema *Schema, t reflect.Type, tag string) bool {
+	if tag == "" || tag == "-" {
+		return false
+	}
+	rules, rest, dive := strings.Cut(tag, ",dive")
+	if dive {
+		rest = strings.TrimPrefix(rest, ",")
+		switch {
+		case schema.Items != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+			applyRules(schema.Items, indirect(t.Elem()), rest)
+		case schema.AdditionalProperties != nil && t.Kind() == reflect.Map:
+			applyRules(schema.AdditionalProperties, indirect(t.Elem()), rest)
+		}
+	}
+	// Constraints cannot be added next to a $ref.
+	if schema.Ref != "" {
+		return strings.Contains(","+rules+",", ",required,")
+	}
+
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "url", "uri", "http_url":
+			schema.Format = "uri"
+		case "uuid", "uuid4":
+			schema.Format = "uuid"
+		case "datetime":
+			schema.Format = "date-time"
+		case "ip", "ipv4":
+			schema.Format = "ipv4"
+		case "ipv6":
+			schema.Format = "ipv6"
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, enumValue(schema.Type, value))
+			}
+		case "min", "gte":
+			setBound(schema, param, true, false)
+		case "max", "lte":
+			setBound(schema, param, false, false)
+		case "gt":
+			setBound(schema, param, true, true)
+		case "lt":
+			setBound(schema, param, false, true)
+		case "len":
+			setBound(schema, param, true, false)
+			setBound(schema, param, false, false)
+		}
+	}
+	return required
+}
+
+// setBound applies a lower (min) or upper bound according to the schema type:
+// length for strings, item count for arrays and value for numbers.
+func setBound(schema *Schema, param string, lower, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	count := int(n)
+
+	switch schema.Type {
+	case "string":
+		if exclusive {
+			return
+		}
+		if lower {
+			schema.MinLength = &count
+		} else {
+			schema.MaxLength = &count
+		}
+	case "array", "object":
+		if exclusive {
+			return
+		}
+		if lower {
+			schema.MinItems = &count
+		} else {
+			schema.MaxItems = &count
+		}
+	case "integer", "number":
+		switch {
+		case lower && exclusive:
+			schema.ExclusiveMinimum = &n
+		case lower:
+			schema.Minimum = &n
+		case exclusive:
+			schema.ExclusiveMaximum = &n
+		default:
+			schema.Maximum = &n
+		}
+	}
+}
+
+// enumValue converts a oneof value to the JSON type of the schema.
+func enumValue(typ, value string) any {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+// paramField finds the field of dto bound to the path parameter name.
+func paramField(dto reflect.Type, name string) (reflect.StructField, bool) {
+	if dto == nil || dto.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := range dto.NumField() {
+		if sf := dto.Field(i); sf.Tag.Get("param") == name {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// componentName returns a unique component key for t, qualifying it with the
+// package name when another type already uses the same name.
+func (g *generator) componentName(t reflect.Type) string {
+	name := sanitizeName(t.Name())
+	if _, taken := g.schemas[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	if idx := strings.LastIndexByte(pkg, '/'); idx != -1 {
+		pkg = pkg[idx+1:]
+	}
+	qualified := sanitizeName(pkg + "." + t.Name())
+	for i := 2; ; i++ {
+		if _, taken := g.schemas[qualified]; !taken {
+			return qualified
+		}
+		qualified = sanitizeName(pkg+"."+t.Name()) + strconv.Itoa(i)
+	}
+}
+
+// sanitizeName keeps the characters allowed in component keys (generic type
+// names contain brackets and import paths).
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// problemSchema registers the RFC 9457 problem schema.
+func (g *generator) problemSchema() *Schema {
+	if _, ok := g.schemas["Problem"]; !ok {
+		g.schemas["Problem"] = &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"type":     {Type: "string", Format: "uri-reference"},
+				"title":    {Type: "string"},
+				"status":   {Type: "integer"},
+				"detail":   {Type: "string"},
+				"instance": {Type: "string", Format: "uri-reference"},
+			},
+		}
+	}
+	return &Schema{Ref: "#/components/schemas/Problem"}
+}
+
+// validationProblemSchema registers the 422 problem returned by middleware.Validate.
+func (g *generator) validationProblemSchema() *Schema {
+	if _, ok := g.schemas["ValidationProblem"]; !ok {
+		g.schemas["ValidationError"] = &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"field":   {Type: "string"},
+				"rule":    {Type: "string"},
+				"param":   {Type: "string"},
+				"value":   {},
+				"message": {Type: "string"},
+			},
+			Required: []string{"field", "rule", "message"},
+		}
+		g.schemas["ValidationProblem"] = &Schema{
+			AllOf: []*Schema{
+				g.problemSchema(),
+				{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"errors": {Type: "array", Items: &Schema{Ref: "#/components/schemas/ValidationError"}},
+					},
+				},
+			},
+		}
+	}
+	return &Schema{Ref: "#/components/schemas/ValidationProblem"}
+}
+
+// jsonPatchSchema registers the RFC 6902 JSON Patch document schema.
+func (g *generator) jsonPatchSchema() *Schema {
+	if _, ok := g.schemas["JSONPatch"]; !ok {
+		g.schemas["JSONPatch"] = &Schema{
+			Type: "array",
+			Items: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"op":    {Type: "string", Enum: []any{"add", "remove", "replace", "move", "copy", "test"}},
+					"path":  {Type: "string"},
+					"from":  {Type: "string"},
+					"value": {},
+				},
+				Required: []string{"op", "path"},
+			},
+		}
+	}
+	return &Schema{Ref: "#/components/schemas/JSONPatch"}
+}
@@ -0,0 +1,453 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// ErrInvalidSpec is returned by Load and Parse for documents that are not
+// usable OpenAPI 3 specifications.
+var ErrInvalidSpec = errors.New("invalid OpenAPI specification")
+
+// Spec is an existing OpenAPI 3.0 or 3.1 document loaded to validate requests
+// against it (see Spec.Validate) and to compare it with the registered routes
+// (see Spec.Check).
+type Spec struct {
+	doc        map[string]any
+	basePath   string
+	operations []*specOperation
+	patterns   sync.Map // map[string]*regexp.Regexp
+}
+
+// specOperation is an operation of the document with its parameters resolved.
+type specOperation struct {
+	method   string
+	path     string
+	segments []pathSegment
+	params   []specParam
+	body     *specBody
+}
+
+// specParam is a path, query, header or cookie parameter.
+type specParam struct {
+	name     string
+	in       string
+	required bool
+	explode  bool
+	schema   any
+}
+
+// specBody is the request body of an operation, keyed by media type.
+type specBody struct {
+	required bool
+	content  map[string]any
+}
+
+// pathSegment is a segment of a path template: a literal, or a parameter with
+// optional literal text around it ("{id}.json").
+type pathSegment struct {
+	literal string
+	param   string
+	prefix  string
+	suffix  string
+}
+
+// Load reads an OpenAPI 3 document in YAML or JSON format from path.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse builds a Spec from an OpenAPI 3 document in YAML or JSON format. Only
+// local references ("#/components/...") are supported. The base path of the
+// first server URL (e.g. "/v1" in "https://api.example.com/v1") prefixes every path.
+func Parse(data []byte) (*Spec, error) {
+	var raw any
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.UseNumber()
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSpec, err)
+		}
+	} else if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSpec, err)
+	}
+
+	doc, ok := normalize(raw).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: the document is not an object", ErrInvalidSpec)
+	}
+	if version, _ := doc["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("%w: unsupported openapi version %q", ErrInvalidSpec, version)
+	}
+
+	s := &Spec{doc: doc, basePath: serverBasePath(doc)}
+	if err := s.checkRefs(doc, "#"); err != nil {
+		return nil, err
+	}
+	if err := s.loadOperations(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// normalize converts YAML and JSON values into the types produced by
+// encoding/json with UseNumber.
+func normalize(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			val[k] = normalize(item)
+		}
+		return val
+	case map[any]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[fmt.Sprint(k)] = normalize(item)
+		}
+		return out
+	case []any:
+		for i, item := range val {
+			val[i] = normalize(item)
+		}
+		return val
+	case int:
+		return json.Number(strconv.Itoa(val))
+	case int64:
+		return json.Number(strconv.FormatInt(val, 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(val, 10))
+	case float64:
+		return json.Number(strconv.FormatFloat(val, 'g', -1, 64))
+	case time.Time:
+		return val.Format(time.RFC3339)
+	default:
+		return val
+	}
+}
+
+// serverBasePath returns the path of the first server URL without a trailing slash.
+func serverBasePath(doc map[string]any) string {
+	servers, _ := doc["servers"].([]any)
+	if len(servers) == 0 {
+		return ""
+	}
+	server, _ := servers[0].(map[string]any)
+	raw, _ := server["url"].(string)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// checkRefs verifies that every "$ref" in v points inside the document.
+func (s *Spec) checkRefs(v any, at string) error {
+	switch val := v.(type) {
+	case map[string]any:
+		if ref, ok := val["$ref"].(string); ok {
+			if _, err := s.lookup(ref); err != nil {
+				return fmt.Errorf("%w: %s: %w", ErrInvalidSpec, at, err)
+			}
+		}
+		for k, item := range val {
+			if err := s.checkRefs(item, at+"/"+k); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for i, item := range val {
+			if err := s.checkRefs(item, at+"/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookup returns the value a local reference points to.
+func (s *Spec) lookup(ref string) (any, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported reference %q: only local references are allowed", ref)
+	}
+	var node any = s.doc
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable reference %q", ref)
+		}
+		if node, ok = obj[token]; !ok {
+			return nil, fmt.Errorf("unresolvable reference %q", ref)
+		}
+	}
+	return node, nil
+}
+
+// resolve follows "$ref" chains. References were verified by Parse.
+func (s *Spec) resolve(v any) any {
+	for range maxSchemaDepth {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return v
+		}
+		ref, ok := obj["$ref"].(string)
+		if !ok {
+			return v
+		}
+		v, _ = s.lookup(ref)
+	}
+	return v
+}
+
+// pattern returns the compiled form of a "pattern" keyword, or nil if invalid.
+func (s *Spec) pattern(expr string) *regexp.Regexp {
+	if cached, ok := s.patterns.Load(expr); ok {
+		re, _ := cached.(*regexp.Regexp)
+		return re
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		re = nil
+	}
+	s.patterns.Store(expr, re)
+	return re
+}
+
+var specMethods = []string{
+	http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+	http.MethodOptions, http.MethodHead, http.MethodPatch, http.MethodTrace,
+}
+
+// loadOperations indexes the operations of the document with their parameters
+// (path-level ones merged with the operation's) and request bodies resolved.
+func (s *Spec) loadOperations() error {
+	paths, _ := s.doc["paths"].(map[string]any)
+	for _, path := range sortedKeys(paths) {
+		item, _ := s.resolve(paths[path]).(map[string]any)
+		shared, err := s.parameters(item["parameters"])
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidSpec, path, err)
+		}
+
+		for _, method := range specMethods {
+			raw, ok := item[strings.ToLower(method)].(map[string]any)
+			if !ok {
+				continue
+			}
+			own, err := s.parameters(raw["parameters"])
+			if err != nil {
+				return fmt.Errorf("%w: %s %s: %w", ErrInvalidSpec, method, path, err)
+			}
+
+			op := &specOperation{method: method, path: path, segments: parseTemplate(path)}
+			// Operation parameters override path-level ones with the same name and location.
+			for _, p := range shared {
+				if !slices.ContainsFunc(own, func(o specParam) bool { return o.name == p.name && o.in == p.in }) {
+					op.params = append(op.params, p)
+				}
+			}
+			op.params = append(op.params, own...)
+
+			if body, ok := s.resolve(raw["requestBody"]).(map[string]any); ok {
+				content, _ := body["content"].(map[string]any)
+				required, _ := body["required"].(bool)
+				op.body = &specBody{required: required, content: content}
+			}
+			s.operations = append(s.operations, op)
+		}
+	}
+	return nil
+}
+
+// parameters resolves a list of parameter objects.
+func (s *Spec) parameters(v any) ([]specParam, error) {
+	list, _ := v.([]any)
+	params := make([]specParam, 0, len(list))
+	for _, item := range list {
+		p, ok := s.resolve(item).(map[string]any)
+		if !ok {
+			return nil, errors.New("parameter is not an object")
+		}
+		name, _ := p["name"].(string)
+		in, _ := p["in"].(string)
+		if name == "" || in == "" {
+			return nil, errors.New("parameter without name or location")
+		}
+		required, _ := p["required"].(bool)
+		style, _ := p["style"].(string)
+		explode, hasExplode := p["explode"].(bool)
+		if !hasExplode {
+			// Form style (the query and cookie default) explodes arrays by default.
+			explode = style == "form" || (style == "" && (in == "query" || in == "cookie"))
+		}
+		params = append(params, specParam{
+			name:     name,
+			in:       in,
+			required: required || in == "path",
+			explode:  explode,
+			schema:   p["schema"],
+		})
+	}
+	return params, nil
+}
+
+// parseTemplate splits a path template into segments.
+func parseTemplate(path string) []pathSegment {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	segments := make([]pathSegment, len(parts))
+	for i, part := range parts {
+		open, end := strings.IndexByte(part, '{'), strings.IndexByte(part, '}')
+		if open == -1 || end < open {
+			segments[i] = pathSegment{literal: part}
+			continue
+		}
+		segments[i] = pathSegment{param: part[open+1 : end], prefix: part[:open], suffix: part[end+1:]}
+	}
+	return segments
+}
+
+// match finds the operation for a request and returns the raw values of its path
+// parameters. Values bound by the adapter (Transwarp route params) take
+// precedence over the ones cut from the URL. Among several templates, the one
+// with more literal segments wins.
+func (s *Spec) match(r *http.Request, routeParams map[string]string) (*specOperation, map[string]string) {
+	path := r.URL.Path
+	if s.basePath != "" {
+		if path != s.basePath && !strings.HasPrefix(path, s.basePath+"/") {
+			return nil, nil
+		}
+		path = strings.TrimPrefix(path, s.basePath)
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	var (
+		best       *specOperation
+		bestValues map[string]string
+		bestScore  = -1
+	)
+	for _, op := range s.operations {
+		if op.method != r.Method || len(op.segments) != len(parts) {
+			continue
+		}
+		values, score, ok := op.matchSegments(parts, routeParams)
+		if ok && score > bestScore {
+			best, bestValues, bestScore = op, values, score
+		}
+	}
+	return best, bestValues
+}
+
+// matchSegments compares the request segments with the template. The score is
+// the number of literal segments.
+func (op *specOperation) matchSegments(parts []string, routeParams map[string]string) (map[string]string, int, bool) {
+	values := make(map[string]string)
+	score := 0
+	for i, seg := range op.segments {
+		if seg.param == "" {
+			if seg.literal != parts[i] {
+				return nil, 0, false
+			}
+			score++
+			continue
+		}
+		part := parts[i]
+		if !strings.HasPrefix(part, seg.prefix) || !strings.HasSuffix(part, seg.suffix) || len(part) < len(seg.prefix)+len(seg.suffix) {
+			return nil, 0, false
+		}
+		value := part[len(seg.prefix) : len(part)-len(seg.suffix)]
+		if bound, ok := routeParams[seg.param]; ok {
+			if bound != value {
+				return nil, 0, false
+			}
+		}
+		values[seg.param] = value
+	}
+	return values, score, true
+}
+
+// Report lists the differences between a specification and the registered routes.
+type Report struct {
+	// Unimplemented lists operations of the specification without a registered
+	// route, as "METHOD /path/{param}".
+	Unimplemented []string
+	// Undocumented lists registered routes missing from the specification.
+	Undocumented []string
+}
+
+// OK reports whether the routes and the specification match.
+func (r *Report) OK() bool {
+	return len(r.Unimplemented) == 0 && len(r.Undocumented) == 0
+}
+
+// String formats the report for logs.
+func (r *Report) String() string {
+	if r.OK() {
+		return "routes match the specification"
+	}
+	var b strings.Builder
+	for _, op := range r.Unimplemented {
+		b.WriteString("unimplemented operation: " + op + "\n")
+	}
+	for _, route := range r.Undocumented {
+		b.WriteString("undocumented route: " + route + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Check compares the specification with the registered routes (see
+// Transwarp.Routes), typically at startup. Parameter names are ignored, so
+// "/users/:id" matches "/users/{userId}".
+func (s *Spec) Check(routes []router.Route) *Report {
+	registered := make(map[string]bool)
+	report := &Report{}
+	for _, route := range routes {
+		path, _ := convertPath(route.Path)
+		key := route.Method + " " + templateKey(path)
+		if registered[key] {
+			continue
+		}
+		registered[key] = true
+		if !slices.ContainsFunc(s.operations, func(op *specOperation) bool {
+			return op.method+" "+templateKey(s.basePath+op.path) == key
+		}) {
+			report.Undocumented = append(report.Undocumented, route.Method+" "+route.Path)
+		}
+	}
+	for _, op := range s.operations {
+		if !registered[op.method+" "+templateKey(s.basePath+op.path)] {
+			report.Unimplemented = append(report.Unimplemented, op.method+" "+s.basePath+op.path)
+		}
+	}
+	return report
+}
+
+var templateParam = regexp.MustCompile(`\{[^}]*\}`)
+
+// templateKey replaces parameter names so templates can be compared.
+func templateKey(path string) string {
+	return templateParam.ReplaceAllString(path, "{}")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
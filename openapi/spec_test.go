@@ -0,0 +1,355 @@
+package openapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/openapi"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+const petstore = `
+openapi: 3.1.0
+info:
+  title: Pets
+  version: 1.0.0
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /pets:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          schema: {type: integer, minimum: 1, maximum: 100}
+        - name: tags
+          in: query
+          schema: {type: array, items: {type: string}, maxItems: 2}
+      responses:
+        "200": {description: OK}
+    post:
+      parameters:
+        - $ref: "#/components/parameters/Tenant"
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/NewPet"}
+      responses:
+        "201": {description: Created}
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+        schema: {type: string, format: uuid}
+    get:
+      responses:
+        "200": {description: OK}
+    delete:
+      responses:
+        "204": {description: Deleted}
+components:
+  parameters:
+    Tenant:
+      name: X-Tenant
+      in: header
+      required: true
+      schema: {type: string, pattern: "^[a-z]+$"}
+  schemas:
+    NewPet:
+      type: object
+      required: [id, name, kind]
+      additionalProperties: false
+      properties:
+        id: {type: string, readOnly: true}
+        name: {type: string, minLength: 2}
+        kind: {type: string, enum: [cat, dog]}
+        birth: {type: string, format: date}
+        owner: {type: [string, "null"], format: email}
+        vaccines:
+          type: array
+          items:
+            type: object
+            required: [name]
+            properties:
+              name: {type: string}
+              dose: {type: number, exclusiveMinimum: 0}
+`
+
+// serveSpec sends a request through the spec middleware. params simulates the
+// route parameters bound by an adapter; nil sends the request without state.
+func serveSpec(t *testing.T, spec *openapi.Spec, req *http.Request, params map[string]string) (*httptest.ResponseRecorder, bool) {
+	t.Helper()
+	if params != nil {
+		var body []byte
+		if req.Body != nil {
+			body = readAll(t, req)
+		}
+		state := &adapter.TranswarpState{Params: params, Body: body}
+		req = req.WithContext(context.WithValue(t.Context(), router.StateKey, state))
+	}
+
+	reached := false
+	rr := httptest.NewRecorder()
+	spec.Validate()(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, req)
+	return rr, reached
+}
+
+func readAll(t *testing.T, req *http.Request) []byte {
+	t.Helper()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("Reading body failed: %v", err)
+	}
+	return body
+}
+
+func parseSpec(t *testing.T) *openapi.Spec {
+	t.Helper()
+	spec, err := openapi.Parse([]byte(petstore))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return spec
+}
+
+// fieldsOf decodes the "errors" member of a validation problem into field/rule pairs.
+func fieldsOf(t *testing.T, rr *httptest.ResponseRecorder) map[string]string {
+	t.Helper()
+	var body struct {
+		Errors []middleware.ValidationError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid problem body %q: %v", rr.Body.String(), err)
+	}
+	fields := make(map[string]string)
+	for _, e := range body.Errors {
+		fields[e.Field] = e.Rule
+	}
+	return fields
+}
+
+// TestSpecValidate_Body verifies schema validation of JSON bodies, including
+// references, read-only members, enums, nested arrays and unknown members.
+func TestSpecValidate_Body(t *testing.T) {
+	spec := parseSpec(t)
+
+	valid := `{"name":"Tom","kind":"cat","birth":"2020-02-01","owner":null,"vaccines":[{"name":"rabies","dose":1.5}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/pets", strings.NewReader(valid))
+	req.Header.Set("X-Tenant", "acme")
+	if rr, reached := serveSpec(t, spec, req, map[string]string{}); !reached {
+		t.Fatalf("Expected a valid request to pass, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	invalid := `{"name":"T","kind":"bird","birth":"01/02/2020","owner":"nobody","vaccines":[{"dose":0}],"color":"red"}`
+	req = httptest.NewRequest(http.MethodPost, "/v1/pets", strings.NewReader(invalid))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant", "ACME")
+	rr, reached := serveSpec(t, spec, req, map[string]string{})
+	if reached || rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d", rr.Code)
+	}
+
+	want := map[string]string{
+		"name":             "min",
+		"kind":             "oneof",
+		"birth":            "date",
+		"owner":            "email",
+		"vaccines[0].name": "required",
+		"vaccines[0].dose": "gt",
+		"color":            "unknown_field",
+		"header.X-Tenant":  "pattern",
+	}
+	got := fieldsOf(t, rr)
+	for field, rule := range want {
+		if got[field] != rule {
+			t.Errorf("Expected %s to fail %q, got %q", field, rule, got[field])
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("Unexpected errors: %v", got)
+	}
+}
+
+// TestSpecValidate_Redaction verifies that rejected values of sensitive
+// members are redacted, as in middleware.Validate.
+func TestSpecValidate_Redaction(t *testing.T) {
+	spec, err := openapi.Parse([]byte(`
+openapi: 3.1.0
+info: {title: Accounts, version: 1.0.0}
+paths:
+  /accounts:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                user: {type: string, minLength: 3}
+                password: {type: string, minLength: 12}
+      responses:
+        "201": {description: Created}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/accounts", strings.NewReader(`{"user":"al","password":"hunter2"}`))
+	rr, _ := serveSpec(t, spec, req, map[string]string{})
+	if strings.Contains(rr.Body.String(), "hunter2") {
+		t.Errorf("Expected the password to be redacted, got %s", rr.Body.String())
+	}
+
+	var body struct {
+		Errors []middleware.ValidationError `json:"errors"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &body)
+	values := make(map[string]any)
+	for _, e := range body.Errors {
+		values[e.Field] = e.Value
+	}
+	if values["password"] != "[REDACTED]" || values["user"] != "al" {
+		t.Errorf("Unexpected rejected values %v", values)
+	}
+}
+
+// TestSpecValidate_Params verifies parameter coercion and matching through the
+// route params bound by the adapter.
+func TestSpecValidate_Params(t *testing.T) {
+	spec := parseSpec(t)
+
+	rr, reached := serveSpec(t, spec, httptest.NewRequest(http.MethodGet, "/v1/pets?limit=10&tags=a&tags=b", nil), map[string]string{})
+	if !reached {
+		t.Fatalf("Expected valid query parameters to pass, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr, _ = serveSpec(t, spec, httptest.NewRequest(http.MethodGet, "/v1/pets?limit=abc&tags=a,b,c", nil), map[string]string{})
+	got := fieldsOf(t, rr)
+	if got["query.limit"] != "type" || got["query.tags"] != "max" {
+		t.Errorf("Unexpected query errors: %v", got)
+	}
+
+	rr, _ = serveSpec(t, spec, httptest.NewRequest(http.MethodGet, "/v1/pets?limit=0", nil), nil)
+	if fieldsOf(t, rr)["query.limit"] != "min" {
+		t.Errorf("Expected the minimum to be enforced without adapter state: %s", rr.Body.String())
+	}
+
+	rr, _ = serveSpec(t, spec, httptest.NewRequest(http.MethodDelete, "/v1/pets/42", nil), map[string]string{"petId": "42"})
+	if fieldsOf(t, rr)["path.petId"] != "uuid" {
+		t.Errorf("Expected the path parameter to be validated: %s", rr.Body.String())
+	}
+
+	id := "3f2b8a1e-4c5d-4e6f-8a9b-0c1d2e3f4a5b"
+	if _, reached := serveSpec(t, spec, httptest.NewRequest(http.MethodGet, "/v1/pets/"+id, nil), map[string]string{"petId": id}); !reached {
+		t.Error("Expected a valid path parameter to pass")
+	}
+}
+
+// TestSpecValidate_Rejections covers the non-422 outcomes and unmatched requests.
+func TestSpecValidate_Rejections(t *testing.T) {
+	spec := parseSpec(t)
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"malformed JSON", "application/json", `{"name":`, http.StatusBadRequest},
+		{"unsupported media type", "text/plain", "name=Tom", http.StatusUnsupportedMediaType},
+		{"missing required body", "application/json", "", http.StatusUnprocessableEntity},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/pets", strings.NewReader(c.body))
+			req.Header.Set("Content-Type", c.contentType)
+			req.Header.Set("X-Tenant", "acme")
+			rr, reached := serveSpec(t, spec, req, map[string]string{})
+			if reached || rr.Code != c.status {
+				t.Errorf("Expected %d, got %d: %s", c.status, rr.Code, rr.Body.String())
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Expected a problem response, got %s", ct)
+			}
+		})
+	}
+
+	if _, reached := serveSpec(t, spec, httptest.NewRequest(http.MethodGet, "/health", nil), map[string]string{}); !reached {
+		t.Error("Requests outside the specification should pass through")
+	}
+}
+
+// TestSpecCheck verifies the startup report comparing routes and operations.
+func TestSpecCheck(t *testing.T) {
+	spec := parseSpec(t)
+	report := spec.Check([]router.Route{
+		{Method: http.MethodGet, Path: "/v1/pets"},
+		{Method: http.MethodPost, Path: "/v1/pets"},
+		{Method: http.MethodGet, Path: "/v1/pets/:id"},
+		{Method: http.MethodGet, Path: "/v1/health"},
+	})
+
+	if report.OK() {
+		t.Fatal("Expected differences")
+	}
+	if len(report.Unimplemented) != 1 || report.Unimplemented[0] != "DELETE /v1/pets/{petId}" {
+		t.Errorf("Unexpected unimplemented operations: %v", report.Unimplemented)
+	}
+	if len(report.Undocumented) != 1 || report.Undocumented[0] != "GET /v1/health" {
+		t.Errorf("Unexpected undocumented routes: %v", report.Undocumented)
+	}
+	if !strings.Contains(report.String(), "undocumented route: GET /v1/health") {
+		t.Errorf("Unexpected report text: %s", report)
+	}
+}
+
+// TestLoad verifies loading JSON documents and rejecting unusable ones.
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "spec.json")
+	doc := `{
+	"openapi": "3.0.3",
+	"info": {"title": "T", "version": "1"},
+	"paths": {"/items": {"post": {"requestBody": {"content": {"application/json": {"schema": {
+		"type": "object",
+		"properties": {"price": {"type": "number", "minimum": 0, "exclusiveMinimum": true}, "note": {"type": "string", "nullable": true}}
+	}}}}}}}
+}`
+	if err := os.WriteFile(file, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := openapi.Load(file)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"price":0,"note":null}`))
+	rr, _ := serveSpec(t, spec, req, map[string]string{})
+	if got := fieldsOf(t, rr); got["price"] != "gt" || len(got) != 1 {
+		t.Errorf("Expected only the OpenAPI 3.0 exclusive minimum to fail, got %v", got)
+	}
+
+	for name, content := range map[string]string{
+		"swagger 2":        `{"swagger": "2.0"}`,
+		"external ref":     "openapi: 3.1.0\npaths:\n  /a:\n    $ref: 'other.yaml#/paths/a'\n",
+		"broken local ref": "openapi: 3.1.0\ncomponents:\n  schemas:\n    A: {$ref: '#/components/schemas/B'}\n",
+	} {
+		if _, err := openapi.Parse([]byte(content)); !errors.Is(err, openapi.ErrInvalidSpec) {
+			t.Errorf("%s: expected ErrInvalidSpec, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/internal/payload"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// Validate returns a middleware that checks requests against the specification.
+// Each request is matched to an operation by method and path (preferring the
+// path parameters bound by the adapter), then its path, query, header and cookie
+// parameters and its JSON body are validated with the operation's schemas.
+//
+// Failures are reported like middleware.Validate: a 422 problem listing them in
+// its "errors" member, with parameters named "<in>.<name>" (e.g. "query.limit")
+// and body members by path (e.g. "items[0].price"). Malformed JSON gets 400 and
+// undeclared media types 415. Requests that match no operation pass through;
+// use Check to find routes missing from the specification.
+func (s *Spec) Validate() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var routeParams map[string]string
+			state, hasState := r.Context().Value(router.StateKey).(*adapter.TranswarpState)
+			if hasState && state != nil {
+				routeParams = state.Params
+			}
+
+			op, pathValues := s.match(r, routeParams)
+			if op == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			check := &schemaCheck{spec: s}
+			for _, p := range op.params {
+				s.checkParam(check, r, p, pathValues)
+			}
+
+			if op.body != nil {
+				body, err := payload.Body(r)
+				if err != nil {
+					problem.Write(w, r, problem.New(http.StatusBadRequest).WithDetail("Could not read the request body"))
+					return
+				}
+				if p := s.checkBody(check, r, op.body, body); p != nil {
+					problem.Write(w, r, p)
+					return
+				}
+			}
+
+			if len(check.errs) > 0 {
+				problem.Write(w, r, middleware.ValidationProblem(check.errs))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkParam validates one parameter.
+func (s *Spec) checkParam(check *schemaCheck, r *http.Request, p specParam, pathValues map[string]string) {
+	var raw []string
+	switch p.in {
+	case "path":
+		if v, ok := pathValues[p.name]; ok {
+			raw = []string{v}
+		}
+	case "query":
+		raw = r.URL.Query()[p.name]
+	case "header":
+		raw = r.Header.Values(p.name)
+	case "cookie":
+		if c, err := r.Cookie(p.name); err == nil {
+			raw = []string{c.Value}
+		}
+	}
+
+	field := p.in + "." + p.name
+	if len(raw) == 0 {
+		if p.required {
+			check.fail(field, "required", "", nil, "This parameter is required")
+		}
+		return
+	}
+	check.validate(p.schema, s.coerce(p, raw), field, 0)
+}
+
+// coerce converts the raw values of a parameter into the JSON value described
+// by its schema, so "42" is validated as a number and "a,b" as an array. Values
+// that cannot be converted are kept as strings and fail the type check.
+func (s *Spec) coerce(p specParam, raw []string) any {
+	schema, _ := s.resolve(p.schema).(map[string]any)
+	if schemaType(schema) != "array" {
+		return scalar(schema, raw[0])
+	}
+
+	values := raw
+	if !p.explode || len(raw) == 1 {
+		values = nil
+		for _, v := range raw {
+			values = append(values, strings.Split(v, ",")...)
+		}
+	}
+	itemSchema, _ := s.resolve(schema["items"]).(map[string]any)
+	items := make([]any, len(values))
+	for i, v := range values {
+		items[i] = scalar(itemSchema, v)
+	}
+	return items
+}
+
+// scalar converts a single raw value according to the schema type.
+func scalar(schema map[string]any, raw string) any {
+	switch schemaType(schema) {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			return json.Number(raw)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+// schemaType returns the first non-null type of a schema.
+func schemaType(schema map[string]any) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []any:
+		for _, item := range t {
+			if s, ok := item.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// checkBody validates the request body. It returns a problem for bodies that
+// cannot be validated at all (unsupported media type, malformed JSON).
+func (s *Spec) checkBody(check *schemaCheck, r *http.Request, spec *specBody, body []byte) *problem.Details {
+	if len(body) == 0 {
+		if spec.required {
+			check.fail("body", "required", "", nil, "A request body is required")
+		}
+		return nil
+	}
+
+	mediaType := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		parsed, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return problem.New(http.StatusUnsupportedMediaType).WithDetail("Invalid Content-Type header")
+		}
+		mediaType = parsed
+	}
+
+	media, ok := contentFor(spec.content, mediaType)
+	if !ok {
+		return problem.New(http.StatusUnsupportedMediaType).
+			WithDetail("Unsupported media type "+mediaType).
+			With("accepted", sortedKeys(spec.content))
+	}
+	if !isJSON(mediaType) {
+		// Only JSON bodies are validated against their schema.
+		return nil
+	}
+
+	var value any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return problem.New(http.StatusBadRequest).WithDetail("Invalid JSON format")
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return problem.New(http.StatusBadRequest).WithDetail("Invalid JSON format")
+	}
+
+	if obj, ok := media.(map[string]any); ok {
+		check.validate(obj["schema"], value, "", 0)
+	}
+	return nil
+}
+
+// contentFor finds the media type object for mediaType, honoring ranges such
+// as "application/*" and "*/*".
+func contentFor(content map[string]any, mediaType string) (any, bool) {
+	if media, ok := content[mediaType]; ok {
+		return media, true
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	if media, ok := content[major+"/*"]; ok {
+		return media, true
+	}
+	media, ok := content["*/*"]
+	return media, ok
+}
+
+// isJSON reports whether mediaType is JSON or a +json structured syntax.
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,138 @@
+package transwarp_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/openapi"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// stubRouter es un adaptador mínimo que solo guarda los handlers GET por ruta,
+// suficiente para probar el registro de rutas sin depender de un framework.
+type stubRouter struct {
+	prefix string
+	gets   map[string]http.Handler
+}
+
+func newStubRouter() *stubRouter {
+	return &stubRouter{gets: map[string]http.Handler{}}
+}
+
+func (s *stubRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	if h, ok := s.gets[r.URL.Path]; ok {
+		h.ServeHTTP(w, r)
+		return
+	}
+	// Rutas comodín "/prefijo/*nombre": el resto de la ruta va al parámetro.
+	for pattern, h := range s.gets {
+		base, name, ok := strings.Cut(pattern, "/*")
+		if ok && strings.HasPrefix(r.URL.Path, base+"/") {
+			h.ServeHTTP(w, transwarp.SetStateValue(r, name, strings.TrimPrefix(r.URL.Path, base+"/")))
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (s *stubRouter) GET(path string, h http.HandlerFunc, _ ...func(http.Handler) http.Handler) {
+	s.gets[s.prefix+path] = h
+}
+func (s *stubRouter) POST(string, http.HandlerFunc, ...func(http.Handler) http.Handler)    {}
+func (s *stubRouter) PUT(string, http.HandlerFunc, ...func(http.Handler) http.Handler)     {}
+func (s *stubRouter) DELETE(string, http.HandlerFunc, ...func(http.Handler) http.Handler)  {}
+func (s *stubRouter) OPTIONS(string, http.HandlerFunc, ...func(http.Handler) http.Handler) {}
+func (s *stubRouter) ANY(string, http.HandlerFunc, ...func(http.Handler) http.Handler)     {}
+func (s *stubRouter) Handle(string, string, http.Handler, ...func(http.Handler) http.Handler) {
+}
+func (s *stubRouter) HandleFunc(string, string, http.HandlerFunc, ...func(http.Handler) http.Handler) {
+}
+func (s *stubRouter) Use(...func(http.Handler) http.Handler) {}
+func (s *stubRouter) Param(r *http.Request, key string) string {
+	state, _ := transwarp.RequestState(r)
+	if state == nil {
+		return ""
+	}
+	return state.Params[key]
+}
+func (s *stubRouter) Engine() any { return s }
+func (s *stubRouter) Group(prefix string) router.Router {
+	return &stubRouter{prefix: s.prefix + prefix, gets: s.gets}
+}
+
+type CreateOrder struct {
+	Item     string `json:"item" validate:"required"`
+	Quantity int    `json:"quantity" validate:"min=1"`
+}
+
+// TestRoutesRegistry verifica que las rutas de los grupos se registren con el
+// prefijo completo y con los middlewares del grupo.
+func TestRoutesRegistry(t *testing.T) {
+	tw := transwarp.New(newStubRouter())
+	noop := func(http.ResponseWriter, *http.Request) {}
+	mw := func(next http.Handler) http.Handler { return next }
+
+	tw.GET("/health", noop)
+	api := tw.Group("/api/v1/")
+	api.Use(mw)
+	api.POST("/orders", noop, middleware.Validate(CreateOrder{}))
+
+	routes := tw.Routes()
+	if len(routes) != 2 {
+		t.Fatalf("Se esperaban 2 rutas, obtenidas %d", len(routes))
+	}
+	if routes[1].Method != http.MethodPost || routes[1].Path != "/api/v1/orders" {
+		t.Errorf("Ruta inesperada: %s %s", routes[1].Method, routes[1].Path)
+	}
+	if len(routes[1].Middlewares) != 2 {
+		t.Errorf("Se esperaban los middlewares del grupo y de la ruta, obtenidos %d", len(routes[1].Middlewares))
+	}
+	if routes[0].Path != "/health" || len(routes[0].Middlewares) != 0 {
+		t.Errorf("Los middlewares del grupo no deberían afectar a rutas externas: %+v", routes[0])
+	}
+}
+
+// TestServeOpenAPI verifica que el documento se sirva en la ruta configurada,
+// incluya rutas registradas después y excluya su propia ruta.
+func TestServeOpenAPI(t *testing.T) {
+	tw := transwarp.New(newStubRouter())
+	tw.ServeOpenAPI("/openapi.json", openapi.Info{Title: "Orders", Version: "1.0.0"})
+	tw.POST("/orders", func(http.ResponseWriter, *http.Request) {}, middleware.Validate(CreateOrder{}))
+
+	rr := httptest.NewRecorder()
+	tw.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Esperado 200, obtenido %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type inesperado: %s", ct)
+	}
+
+	var doc openapi.Document
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("El documento no es JSON válido: %v", err)
+	}
+	if doc.Info.Title != "Orders" {
+		t.Errorf("Título inesperado: %s", doc.Info.Title)
+	}
+	if _, ok := doc.Paths["/openapi.json"]; ok {
+		t.Error("La ruta del documento no debería aparecer en él")
+	}
+	item, ok := doc.Paths["/orders"]
+	if !ok || (*item)["post"] == nil || (*item)["post"].RequestBody == nil {
+		t.Fatalf("Falta la operación POST /orders con su cuerpo: %+v", doc.Paths)
+	}
+	if doc.Components.Schemas["CreateOrder"] == nil {
+		t.Error("Falta el esquema CreateOrder en components")
+	}
+}
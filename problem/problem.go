@@ -0,0 +1,221 @@
+// Package problem implements RFC 9457 Problem Details for HTTP APIs
+// (application/problem+json), the error format used across Transwarp.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// ContentType is the media type of a Problem Details JSON document.
+const ContentType = "application/problem+json"
+
+// DefaultType is the problem type used when no specific type URI is provided.
+// Per RFC 9457, its title should match the HTTP status phrase.
+const DefaultType = "about:blank"
+
+// Details is an RFC 9457 problem object. It implements error, so handlers can
+// return it and have it rendered with the original status (see HandlerFunc).
+type Details struct {
+	// Type is a URI reference identifying the problem type.
+	Type string
+	// Title is a short, human-readable summary of the problem type.
+	Title string
+	// Status is the HTTP status code generated by the origin server.
+	Status int
+	// Detail is a human-readable explanation specific to this occurrence.
+	Detail string
+	// Instance is a URI reference identifying this specific occurrence.
+	Instance string
+	// Extensions holds additional members serialized next to the standard ones.
+	Extensions map[string]any
+}
+
+// New creates a problem for the given status, titled with the standard status text.
+func New(status int) *Details {
+	return &Details{
+		Type:   DefaultType,
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+}
+
+// WithType sets the problem type URI and its title.
+func (d *Details) WithType(typeURI, title string) *Details {
+	d.Type = typeURI
+	d.Title = title
+	return d
+}
+
+// WithDetail sets the occurrence-specific explanation.
+func (d *Details) WithDetail(detail string) *Details {
+	d.Detail = detail
+	return d
+}
+
+// WithInstance sets the URI identifying this occurrence.
+func (d *Details) WithInstance(instance string) *Details {
+	d.Instance = instance
+	return d
+}
+
+// With adds an extension member. Names of standard members are ignored on output.
+func (d *Details) With(key string, value any) *Details {
+	if d.Extensions == nil {
+		d.Extensions = make(map[string]any)
+	}
+	d.Extensions[key] = value
+	return d
+}
+
+// Error implements the error interface.
+func (d *Details) Error() string {
+	if d.Detail != "" {
+		return fmt.Sprintf("%d %s: %s", d.Status, d.Title, d.Detail)
+	}
+	return fmt.Sprintf("%d %s", d.Status, d.Title)
+}
+
+// MarshalJSON flattens extension members next to the standard members.
+func (d *Details) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, len(d.Extensions)+5)
+	maps.Copy(out, d.Extensions)
+
+	typ := d.Type
+	if typ == "" {
+		typ = DefaultType
+	}
+	out["type"] = typ
+	if d.Title != "" {
+		out["title"] = d.Title
+	}
+	if d.Status != 0 {
+		out["status"] = d.Status
+	}
+	if d.Detail != "" {
+		out["detail"] = d.Detail
+	}
+	if d.Instance != "" {
+		out["instance"] = d.Instance
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads standard members and keeps every other member as an extension.
+func (d *Details) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	standard := map[string]any{
+		"type": &d.Type, "title": &d.Title, "status": &d.Status,
+		"detail": &d.Detail, "instance": &d.Instance,
+	}
+	for key, value := range raw {
+		if dst, ok := standard[key]; ok {
+			// RFC 9457: members with an unexpected type must be ignored.
+			_ = json.Unmarshal(value, dst)
+			continue
+		}
+		var ext any
+		if err := json.Unmarshal(value, &ext); err != nil {
+			return err
+		}
+		if d.Extensions == nil {
+			d.Extensions = make(map[string]any)
+		}
+		d.Extensions[key] = ext
+	}
+	return nil
+}
+
+// Renderer writes a problem to the client. Swap it with SetRenderer to use a
+// different error format across all Transwarp components.
+type Renderer func(w http.ResponseWriter, r *http.Request, p *Details)
+
+var renderer atomic.Pointer[Renderer]
+
+// SetRenderer replaces the renderer used by Write. Passing nil restores JSONRenderer.
+func SetRenderer(fn Renderer) {
+	if fn == nil {
+		renderer.Store(nil)
+		return
+	}
+	renderer.Store(&fn)
+}
+
+// JSONRenderer is the default renderer, emitting application/problem+json.
+func JSONRenderer(w http.ResponseWriter, _ *http.Request, p *Details) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// Write renders p with the configured renderer. When p has no instance, the
+// request path is used. When the request carries an ID (see
+// middleware.RequestID), it is added as the "request_id" extension member.
+func Write(w http.ResponseWriter, r *http.Request, p *Details) {
+	// Work on a shallow copy so shared problem values are never mutated.
+	out := *p
+	if out.Instance == "" && r != nil && r.URL != nil {
+		out.Instance = r.URL.Path
+	}
+	if r != nil {
+		if id, _ := r.Context().Value(router.RequestIDKey).(string); id != "" && out.Extensions["request_id"] == nil {
+			out.Extensions = maps.Clone(out.Extensions)
+			if out.Extensions == nil {
+				out.Extensions = make(map[string]any, 1)
+			}
+			out.Extensions["request_id"] = id
+		}
+	}
+	if out.Status == 0 {
+		out.Status = http.StatusInternalServerError
+	}
+
+	if fn := renderer.Load(); fn != nil {
+		(*fn)(w, r, &out)
+		return
+	}
+	JSONRenderer(w, r, &out)
+}
+
+// From converts an arbitrary error into a problem. Errors that are (or wrap) a
+// *Details are returned as-is; anything else becomes a generic 500 so internal
+// messages are never leaked to the client.
+func From(err error) *Details {
+	var p *Details
+	if errors.As(err, &p) {
+		return p
+	}
+	return New(http.StatusInternalServerError)
+}
+
+// HandlerFunc adapts a handler that returns an error. Returned errors are
+// rendered with From and Write.
+func HandlerFunc(fn func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := fn(w, r); err != nil {
+			Write(w, r, From(err))
+		}
+	}
+}
+
+// NotFound replies with a 404 problem. Adapters use it for unmatched routes.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(http.StatusNotFound))
+}
+
+// MethodNotAllowed replies with a 405 problem. Adapters use it when the path
+// exists for other methods; they are responsible for setting the Allow header.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(http.StatusMethodNotAllowed))
+}
@@ -0,0 +1,113 @@
+package problem_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
+)
+
+// TestDetails_MarshalFlattensExtensions verifies the RFC 9457 wire format.
+func TestDetails_MarshalFlattensExtensions(t *testing.T) {
+	p := problem.New(http.StatusConflict).
+		WithDetail("Email already registered").
+		With("email", "admin@transwarp.io").
+		With("status", "ignored")
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var out map[string]any
+	_ = json.Unmarshal(data, &out)
+	if out["type"] != "about:blank" || out["title"] != "Conflict" || out["status"] != float64(409) {
+		t.Errorf("Standard members not serialized correctly: %s", data)
+	}
+	if out["email"] != "admin@transwarp.io" {
+		t.Errorf("Extension member missing: %s", data)
+	}
+
+	var back problem.Details
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if back.Status != http.StatusConflict || back.Extensions["email"] != "admin@transwarp.io" {
+		t.Errorf("Round trip lost data: %+v", back)
+	}
+}
+
+// TestHandlerFunc_RendersReturnedProblems verifies that handlers can return problems
+// and that unknown errors never leak their message.
+func TestHandlerFunc_RendersReturnedProblems(t *testing.T) {
+	h := problem.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) error {
+		if r.URL.Path == "/teapot" {
+			return fmt.Errorf("wrapped: %w", problem.New(http.StatusTeapot).WithDetail("short and stout"))
+		}
+		return errors.New("database password is hunter2")
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/teapot", nil))
+	if rec.Code != http.StatusTeapot || rec.Header().Get("Content-Type") != problem.ContentType {
+		t.Errorf("Unexpected response: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	var p problem.Details
+	_ = json.Unmarshal(rec.Body.Bytes(), &p)
+	if p.Instance != "/teapot" || p.Detail != "short and stout" {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/boom", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", rec.Code)
+	}
+	if body := rec.Body.String(); !json.Valid(rec.Body.Bytes()) || strings.Contains(body, "hunter2") {
+		t.Errorf("Internal error leaked or invalid body: %s", body)
+	}
+}
+
+// TestSetRenderer verifies that the error format can be swapped globally.
+func TestSetRenderer(t *testing.T) {
+	problem.SetRenderer(func(w http.ResponseWriter, _ *http.Request, p *problem.Details) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(p.Status)
+		_, _ = w.Write([]byte(p.Title))
+	})
+	t.Cleanup(func() { problem.SetRenderer(nil) })
+
+	rec := httptest.NewRecorder()
+	problem.NotFound(rec, httptest.NewRequest(http.MethodGet, "/x", nil))
+	if rec.Body.String() != "Not Found" || rec.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Custom renderer not used: %q", rec.Body.String())
+	}
+}
+
+// TestWrite_RequestID verifies that the request ID is added without mutating the
+// shared problem value.
+func TestWrite_RequestID(t *testing.T) {
+	shared := problem.New(http.StatusTooManyRequests).With("retry", 3)
+
+	req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	req = req.WithContext(context.WithValue(req.Context(), router.RequestIDKey, "req-1"))
+	rr := httptest.NewRecorder()
+	problem.Write(rr, req, shared)
+
+	var out map[string]any
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	if out["request_id"] != "req-1" || out["retry"] != float64(3) {
+		t.Errorf("Expected request_id and the original extensions: %s", rr.Body.String())
+	}
+	if _, ok := shared.Extensions["request_id"]; ok {
+		t.Error("Write mutated the shared problem")
+	}
+}
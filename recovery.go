@@ -5,11 +5,15 @@ import (
 	log "log/slog"
 	"net/http"
 	"runtime/debug"
+
+	"github.com/iaconlabs/transwarp/problem"
+	"github.com/iaconlabs/transwarp/router"
 )
 
 // Recovery returns a middleware that recovers from panics, logs the error,
-// and returns an Internal Server Error (500) to the client.
-// If stack is true, it includes the stack trace in the log and response.
+// and returns an Internal Server Error (500) problem to the client.
+// If stack is true, it includes the stack trace in the log and in the problem detail.
+// The log record carries the request ID set by middleware.RequestID, if any.
 func Recovery(stack bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -19,16 +23,17 @@ func Recovery(stack bool) func(http.Handler) http.Handler {
 					if stack {
 						message = fmt.Sprintf("%s\n\n%s", message, string(debug.Stack()))
 					}
-					log.Info(message)
-
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusInternalServerError)
+					if id, _ := r.Context().Value(router.RequestIDKey).(string); id != "" {
+						log.InfoContext(r.Context(), message, "request_id", id)
+					} else {
+						log.InfoContext(r.Context(), message)
+					}
 
-					body := `{"error": "Internal Server Error"}`
+					p := problem.New(http.StatusInternalServerError)
 					if stack {
-						body = fmt.Sprintf(`{"error": %q}`, message)
+						p.WithDetail(message)
 					}
-					_, _ = w.Write([]byte(body))
+					problem.Write(w, r, p)
 				}
 			}()
 			next.ServeHTTP(w, r)
@@ -0,0 +1,66 @@
+package transwarp_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iaconlabs/transwarp"
+	"github.com/iaconlabs/transwarp/middleware"
+	"github.com/iaconlabs/transwarp/problem"
+)
+
+// TestRecoveryProblem verifica que un panic se traduce en un problem+json 500
+// sin exponer el mensaje cuando stack es false.
+func TestRecoveryProblem(t *testing.T) {
+	h := transwarp.Recovery(false)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("secret failure")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Esperado 500, obtenido %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != problem.ContentType {
+		t.Errorf("Content-Type inesperado: %s", ct)
+	}
+
+	var p problem.Details
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("Cuerpo inválido: %v", err)
+	}
+	if p.Detail != "" || p.Instance != "/panic" {
+		t.Errorf("Problem inesperado: %+v", p)
+	}
+}
+
+// TestRecoveryRequestID verifica que el log del panic y el problem incluyen el
+// ID de la petición asignado por middleware.RequestID.
+func TestRecoveryRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer slog.SetDefault(previous)
+
+	h := middleware.RequestID()(transwarp.Recovery(false)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set("X-Request-ID", "req-7")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if !strings.Contains(logs.String(), `"request_id":"req-7"`) {
+		t.Errorf("El log no incluye el request_id: %s", logs.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"request_id":"req-7"`) {
+		t.Errorf("El problem no incluye el request_id: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,116 @@
+package router
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+)
+
+// Route describes a route registered through Transwarp. Path uses Transwarp
+// syntax (":id", "*path") and includes group prefixes; Middlewares holds the
+// group and route middlewares in execution order.
+type Route struct {
+	Method      string
+	Path        string
+	Middlewares []func(http.Handler) http.Handler
+}
+
+// RouteDescription collects the documentation contributed by a route's
+// middlewares (request and response types, error statuses...). It is consumed
+// by generators such as the openapi package.
+type RouteDescription struct {
+	// Request is the DTO type bound from the request body and path parameters.
+	Request reflect.Type
+	// RequestContentTypes lists the accepted body media types. Empty means application/json.
+	RequestContentTypes []string
+	// Response is the type of the successful response body.
+	Response reflect.Type
+	// Errors lists the error statuses the middlewares may respond with.
+	Errors []int
+	// Security lists the authentication schemes the middlewares require, all of
+	// which must succeed.
+	Security []SecurityScheme
+	// Permissions lists the permissions (roles or scopes) the caller must hold.
+	Permissions []string
+	// Rules describes the other authorization checks of the route, such as
+	// resource-level rules on path parameters.
+	Rules []string
+	// Public marks a route explicitly open to anonymous callers.
+	Public bool
+}
+
+// SecurityScheme describes how an authentication middleware identifies
+// callers, in OpenAPI terms.
+type SecurityScheme struct {
+	// Name identifies the scheme, e.g. "bearerAuth".
+	Name string
+	// Type is "http" or "apiKey".
+	Type string
+	// Scheme is the HTTP authentication scheme of http schemes ("basic", "bearer").
+	Scheme string
+	// BearerFormat hints at the format of bearer tokens, e.g. "JWT".
+	BearerFormat string
+	// In ("header" or "query") and Param locate the key of apiKey schemes.
+	In    string
+	Param string
+}
+
+// RouteDescriber is implemented by the handlers that documented middlewares
+// (e.g. middleware.Validate) return, so tools can inspect a route without
+// executing it.
+type RouteDescriber interface {
+	DescribeRoute(d *RouteDescription)
+}
+
+// Describe builds the description of the route by wrapping a no-op handler with
+// each middleware and asking the results that implement RouteDescriber.
+func (r Route) Describe() RouteDescription {
+	var d RouteDescription
+	noop := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	for _, mw := range r.Middlewares {
+		if describer, ok := mw(noop).(RouteDescriber); ok {
+			describer.DescribeRoute(&d)
+		}
+	}
+	return d
+}
+
+// AddError records an error status once.
+func (d *RouteDescription) AddError(statuses ...int) {
+	for _, status := range statuses {
+		if !slices.Contains(d.Errors, status) {
+			d.Errors = append(d.Errors, status)
+		}
+	}
+}
+
+// AddSecurity records an authentication scheme once, by name.
+func (d *RouteDescription) AddSecurity(s SecurityScheme) {
+	if !slices.ContainsFunc(d.Security, func(other SecurityScheme) bool { return other.Name == s.Name }) {
+		d.Security = append(d.Security, s)
+	}
+}
+
+// AddPermission records required permissions once.
+func (d *RouteDescription) AddPermission(permissions ...string) {
+	for _, p := range permissions {
+		if !slices.Contains(d.Permissions, p) {
+			d.Permissions = append(d.Permissions, p)
+		}
+	}
+}
+
+// AddRule records an authorization rule once.
+func (d *RouteDescription) AddRule(rules ...string) {
+	for _, rule := range rules {
+		if !slices.Contains(d.Rules, rule) {
+			d.Rules = append(d.Rules, rule)
+		}
+	}
+}
+
+// Authorized reports whether the route declares an authorization policy:
+// required permissions, rules, or an explicit Public mark.
+func (d *RouteDescription) Authorized() bool {
+	return d.Public || len(d.Permissions) > 0 || len(d.Rules) > 0
+}
@@ -12,7 +12,8 @@ type ctxKey string
 const (
 	// ParamsKey is used to store and retrieve path parameters from the request context.
 	ParamsKey ctxKey = "___transwarp_params___"
-	// RouteKey identifies the current route pattern being executed.
+	// RouteKey identifies the current route pattern being executed (a string in
+	// Transwarp syntax, e.g. "/users/:id"). It mirrors TranswarpState.Route.
 	RouteKey ctxKey = "___transwarp_route___"
 	// NextKey stores the next [http.Handler] in the middleware chain.
 	NextKey ctxKey = "___transwarp_next___"
@@ -22,6 +23,13 @@ const (
 	StateKey ctxKey = "___transwarp_state___"
 	// ValidationKey is used to store validated data structures after middleware processing.
 	ValidationKey ctxKey = "___transwarp_validator_key___"
+	// RequestIDKey stores the request ID (a string) set by middleware.RequestID.
+	RequestIDKey ctxKey = "___transwarp_request_id___"
+	// ClaimsKey stores the verified claims of the authenticated caller, set by
+	// the middleware/auth package (auth.Claims).
+	ClaimsKey ctxKey = "___transwarp_claims___"
+	// CSRFKey stores the CSRF token of the request, set by middleware.CSRF.
+	CSRFKey ctxKey = "___transwarp_csrf___"
 )
 
 // Router defines the contract that every web framework adapter must implement.
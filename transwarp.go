@@ -5,6 +5,7 @@ package transwarp
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/iaconlabs/transwarp/adapter"
 	"github.com/iaconlabs/transwarp/router"
@@ -15,24 +16,84 @@ var _ router.Router = &Transwarp{}
 
 // Transwarp is the primary entry point for the library, wrapping a RouterAdapter
 // to provide a consistent API regardless of the underlying web engine.
+// It also keeps a registry of every route registered through it (see Routes).
 type Transwarp struct {
-	adapter router.Router
+	adapter     router.Router
+	prefix      string
+	middlewares []func(http.Handler) http.Handler
+	routes      *[]router.Route
+	pre         *preChain
+}
+
+// preChain holds the middlewares that run before routing (see Pre), shared by
+// an instance and its groups.
+type preChain struct {
+	root    http.Handler // the adapter of the root instance
+	mws     []func(http.Handler) http.Handler
+	handler http.Handler
 }
 
 // New creates a new Transwarp instance using the provided adapter.
 func New(adapter router.Router) *Transwarp {
 	return &Transwarp{
 		adapter: adapter,
+		routes:  &[]router.Route{},
+		pre:     &preChain{root: adapter},
 	}
 }
 
-// ServeHTTP dispatches the request to the underlying adapter.
+// Routes returns the routes registered through this instance and all its groups,
+// in registration order.
+func (t *Transwarp) Routes() []router.Route {
+	return append([]router.Route(nil), (*t.routes)...)
+}
+
+// fullPath joins the group prefix and path.
+func (t *Transwarp) fullPath(path string) string {
+	full := t.prefix + "/" + strings.TrimPrefix(path, "/")
+	return strings.ReplaceAll(full, "//", "/")
+}
+
+// record adds a route to the shared registry.
+func (t *Transwarp) record(method, path string, mws []func(http.Handler) http.Handler) {
+	full := t.fullPath(path)
+
+	stack := make([]func(http.Handler) http.Handler, 0, len(t.middlewares)+len(mws))
+	stack = append(stack, t.middlewares...)
+	stack = append(stack, mws...)
+
+	*t.routes = append(*t.routes, router.Route{Method: method, Path: full, Middlewares: stack})
+}
+
+// ServeHTTP runs the Pre middlewares, if any, and dispatches the request to the
+// underlying adapter.
 func (t *Transwarp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if t.pre != nil && t.pre.handler != nil {
+		t.pre.handler.ServeHTTP(w, r)
+		return
+	}
 	t.adapter.ServeHTTP(w, r)
 }
 
+// Pre adds middlewares that run for every request before routing, even when no
+// route matches. They suit concerns such as CORS preflight requests, which must
+// be answered whether or not an OPTIONS route exists. Pre middlewares run before
+// the adapter builds the request state, so path parameters and the matched route
+// are not available to them. Pre middlewares are shared with every group and
+// must be added before the server starts.
+func (t *Transwarp) Pre(mws ...func(http.Handler) http.Handler) {
+	t.pre.mws = append(t.pre.mws, mws...)
+
+	h := t.pre.root
+	for i := len(t.pre.mws) - 1; i >= 0; i-- {
+		h = t.pre.mws[i](h)
+	}
+	t.pre.handler = h
+}
+
 // GET registers a GET route through the adapter.
 func (t *Transwarp) GET(path string, h http.HandlerFunc, m ...func(http.Handler) http.Handler) {
+	t.record(http.MethodGet, path, m)
 	t.adapter.GET(path, h, m...)
 }
 
@@ -43,32 +104,47 @@ func (t *Transwarp) Param(r *http.Request, key string) string {
 
 // POST registers a POST route through the adapter.
 func (t *Transwarp) POST(path string, h http.HandlerFunc, mws ...func(http.Handler) http.Handler) {
+	t.record(http.MethodPost, path, mws)
 	t.adapter.POST(path, h, mws...)
 }
 
 // PUT registers a PUT route through the adapter.
 func (t *Transwarp) PUT(path string, h http.HandlerFunc, mws ...func(http.Handler) http.Handler) {
+	t.record(http.MethodPut, path, mws)
 	t.adapter.PUT(path, h, mws...)
 }
 
 // DELETE registers a DELETE route through the adapter.
 func (t *Transwarp) DELETE(path string, h http.HandlerFunc, mws ...func(http.Handler) http.Handler) {
+	t.record(http.MethodDelete, path, mws)
 	t.adapter.DELETE(path, h, mws...)
 }
 
 // OPTIONS registers an OPTIONS route through the adapter.
 func (t *Transwarp) OPTIONS(path string, h http.HandlerFunc, mws ...func(http.Handler) http.Handler) {
+	t.record(http.MethodOptions, path, mws)
 	t.adapter.OPTIONS(path, h, mws...)
 }
 
 // Use adds middlewares to the internal adapter.
 func (t *Transwarp) Use(mws ...func(http.Handler) http.Handler) {
+	t.middlewares = append(t.middlewares, mws...)
 	t.adapter.Use(mws...)
 }
 
-// Group creates a new prefixed group using the adapter's implementation.
+// Group creates a new prefixed group using the adapter's implementation. The
+// returned router is a *Transwarp sharing this instance's route registry.
 func (t *Transwarp) Group(prefix string) router.Router {
-	return t.adapter.Group(prefix)
+	full := t.prefix + "/" + strings.Trim(prefix, "/")
+	full = strings.ReplaceAll(full, "//", "/")
+
+	return &Transwarp{
+		adapter:     t.adapter.Group(prefix),
+		prefix:      strings.TrimSuffix(full, "/"),
+		middlewares: append([]func(http.Handler) http.Handler{}, t.middlewares...),
+		routes:      t.routes,
+		pre:         t.pre,
+	}
 }
 
 // Engine returns the raw underlying web engine.
@@ -78,15 +154,24 @@ func (t *Transwarp) Engine() any {
 
 // Handle registers the handler for the given pattern
 func (t *Transwarp) Handle(method, pattern string, handler http.Handler, mws ...func(http.Handler) http.Handler) {
+	t.record(method, pattern, mws)
 	t.adapter.Handle(method, pattern, handler, mws...)
 }
 
 // HandleFunc register an ordinary function as an HTTP handler for a specific path in a web server
 func (t *Transwarp) HandleFunc(method, path string, h http.HandlerFunc, mws ...func(http.Handler) http.Handler) {
+	t.record(method, path, mws)
 	t.adapter.HandleFunc(method, path, h, mws...)
 }
 
+// ANY registers the route for GET, POST, PUT, DELETE, PATCH and OPTIONS through the adapter.
 func (t *Transwarp) ANY(path string, h http.HandlerFunc, mws ...func(http.Handler) http.Handler) {
+	for _, method := range []string{
+		http.MethodGet, http.MethodPost, http.MethodPut,
+		http.MethodDelete, http.MethodPatch, http.MethodOptions,
+	} {
+		t.record(method, path, mws)
+	}
 	t.adapter.ANY(path, h, mws...)
 }
 
@@ -97,6 +182,14 @@ func RequestState(r *http.Request) (*adapter.TranswarpState, bool) {
 	return state, ok
 }
 
+// RoutePattern devuelve el patrón de la ruta que atendió la petición, en sintaxis
+// Transwarp e incluyendo el prefijo del grupo (por ejemplo "/api/users/:id"), o ""
+// si ninguna ruta coincidió. Es la etiqueta adecuada para métricas, trazas y logs,
+// ya que no depende de los valores concretos de los parámetros.
+func RoutePattern(r *http.Request) string {
+	return adapter.RoutePattern(r)
+}
+
 func SetStateValue(r *http.Request, key, value string) *http.Request {
 	state, ok := r.Context().Value(router.StateKey).(*adapter.TranswarpState)
 
@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"github.com/iaconlabs/transwarp"
+	"github.com/iaconlabs/transwarp/adapter"
+	"github.com/iaconlabs/transwarp/middleware"
 )
 
 // TestInitialStateCreation verifica que si el request no tiene estado,
@@ -171,3 +173,52 @@ func TestDeleteStateNoContext(t *testing.T) {
 		t.Error("No debería haberse creado un estado solo por intentar borrar una llave")
 	}
 }
+
+// TestRoutePattern verifica que RoutePattern devuelve la ruta registrada por el
+// adaptador y una cadena vacía cuando ninguna ruta coincidió.
+func TestRoutePattern(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/users/7", nil)
+	if got := transwarp.RoutePattern(req); got != "" {
+		t.Errorf("Sin estado se esperaba una ruta vacía, obtenido %q", got)
+	}
+
+	state := &adapter.TranswarpState{Params: map[string]string{"id": "7"}, Route: "/api/users/:id", Prefix: "/api"}
+	req = req.WithContext(adapter.WithState(req.Context(), state))
+	if got := transwarp.RoutePattern(req); got != "/api/users/:id" {
+		t.Errorf("Esperado /api/users/:id, obtenido %q", got)
+	}
+}
+
+// TestPre verifica que los middlewares Pre se ejecutan antes del enrutado: una
+// petición preflight se responde aunque no exista ninguna ruta OPTIONS, y los
+// grupos comparten la misma cadena.
+func TestPre(t *testing.T) {
+	stub := newStubRouter()
+	app := transwarp.New(stub)
+	api := app.Group("/api")
+	api.GET("/users", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	app.Pre(middleware.CORS(middleware.CORSConfig{AllowOrigins: []string{"https://app.example.com"}}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/users", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rr := httptest.NewRecorder()
+	api.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Se esperaba 204 para el preflight, obtenido %d", rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin incorrecto: %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr = httptest.NewRecorder()
+	app.ServeHTTP(rr, req)
+	if rr.Body.String() != "ok" || rr.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Errorf("La petición real debería llegar al handler con cabeceras CORS: %d %q %v", rr.Code, rr.Body.String(), rr.Header())
+	}
+}